@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/soishi1/toylisp/evaluator"
+	"github.com/soishi1/toylisp/parser"
+	"github.com/soishi1/toylisp/sexpressions"
+	"github.com/soishi1/toylisp/tokenizer"
+)
+
+// runCheck tokenizes, parses and checks the files at paths without evaluating
+// them, and prints diagnostics as file:line:col: message. It returns false if
+// there are any errors. Warnings are printed but don't make it fail.
+func runCheck(paths []string) bool {
+	ok := true
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			ok = false
+			continue
+		}
+		for _, d := range checkSource(string(src)) {
+			if d.warning {
+				fmt.Fprintf(os.Stderr, "%s:%v: warning: %s\n", path, d.pos, d.message)
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "%s:%v: %s\n", path, d.pos, d.message)
+			ok = false
+		}
+	}
+	return ok
+}
+
+type diagnostic struct {
+	// pos is where the problem is in the source.
+	pos     sexpressions.Pos
+	message string
+	// warning is true if the problem doesn't stop the program from running.
+	warning bool
+}
+
+// checkSource returns the problems in src. Top-level forms are checked
+// independently so that one mistake doesn't hide the others.
+func checkSource(src string) []diagnostic {
+	tokens, err := tokenizer.Tokenize(src)
+	if err != nil {
+		d := diagnostic{message: err.Error()}
+		var tokenizeErr *tokenizer.Error
+		if errors.As(err, &tokenizeErr) {
+			d.pos = sexpressions.Pos{Line: tokenizeErr.Line, Column: tokenizeErr.Column}
+		}
+		return []diagnostic{d}
+	}
+	var diagnostics []diagnostic
+	for _, form := range splitForms(tokens) {
+		first := tokens[form.start]
+		pos := sexpressions.Pos{Line: first.Line, Column: first.Column}
+		if form.err != "" {
+			diagnostics = append(diagnostics, diagnostic{pos: pos, message: form.err})
+			continue
+		}
+		sexps, err := parser.Parse(tokens[form.start:form.end])
+		if err != nil {
+			diagnostics = append(diagnostics, diagnostic{pos: pos, message: err.Error()})
+			continue
+		}
+		for _, sexp := range sexps {
+			warnings, err := evaluator.Lint(sexp)
+			for _, w := range warnings {
+				diagnostics = append(diagnostics, diagnostic{pos: w.Pos, message: w.Message, warning: true})
+			}
+			if err == nil {
+				continue
+			}
+			d := diagnostic{pos: pos, message: err.Error()}
+			var positioned *evaluator.PositionError
+			if errors.As(err, &positioned) {
+				d.pos, d.message = positioned.Pos, positioned.Err.Error()
+			}
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	return diagnostics
+}
+
+// form is tokens[start:end] of a top-level form, or an error found while
+// splitting them.
+type form struct {
+	start, end int
+	err        string
+}
+
+// splitForms splits tokens into top-level forms by balancing parens.
+func splitForms(tokens []*tokenizer.Token) []form {
+	var forms []form
+	depth := 0
+	start := -1
+	for i, t := range tokens {
+		switch t.Type {
+		case tokenizer.Space, tokenizer.Comment:
+			continue
+		case tokenizer.OpenParen, tokenizer.OpenVector:
+			depth++
+		case tokenizer.CloseParen:
+			depth--
+		}
+		if start < 0 {
+			start = i
+		}
+		if depth < 0 {
+			forms = append(forms, form{start: i, end: i + 1, err: "unexpected )"})
+			depth = 0
+			start = -1
+			continue
+		}
+		if depth == 0 && t.Type != tokenizer.Quote {
+			forms = append(forms, form{start: start, end: i + 1})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		forms = append(forms, form{start: start, end: len(tokens), err: "unmatched ("})
+	}
+	return forms
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/soishi1/toylisp/evaluator"
+)
+
+// command is a REPL command such as :quit, which is handled outside the
+// evaluator.
+type command struct {
+	name  string
+	usage string
+	// run runs the command, and returns true if the REPL should exit. It may
+	// replace *env. builtins are the names bound by NewEnv.
+	run func(env **evaluator.Env, builtins map[string]bool, args []string) (quit bool)
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: ":env", usage: ":env [all]  list variables defined in this session, or all variables", run: runEnv},
+		{name: ":load", usage: ":load FILE  evaluate FILE", run: runLoad},
+		{name: ":help", usage: ":help [NAME]  show this help, or describe NAME", run: runHelp},
+		{name: ":reset", usage: ":reset  discard all definitions and start over from the rc file", run: runReset},
+		{name: ":quit", usage: ":quit  exit the REPL", run: runQuit},
+	}
+}
+
+// findCommand returns the command that line invokes. Lines that don't start
+// with a command name are left to the evaluator, so that keywords can still
+// be evaluated.
+func findCommand(line string) (*command, bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, false
+	}
+	for _, cmd := range commands {
+		if cmd.name == fields[0] {
+			return cmd, true
+		}
+	}
+	return nil, false
+}
+
+func commandArgs(line string) []string {
+	return strings.Fields(line)[1:]
+}
+
+func runEnv(env **evaluator.Env, builtins map[string]bool, args []string) bool {
+	all := len(args) > 0 && args[0] == "all"
+	for _, name := range (*env).Names() {
+		if builtins[name] && !all {
+			continue
+		}
+		value, _ := (*env).Lookup(name)
+		fmt.Printf("%s = %v\n", name, value)
+	}
+	return false
+}
+
+func runLoad(env **evaluator.Env, builtins map[string]bool, args []string) bool {
+	if len(args) != 1 {
+		fmt.Println("usage: :load FILE")
+		return false
+	}
+	value, err := (*env).LoadFile(args[0])
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+	printValue(value)
+	return false
+}
+
+// specialFormUsages describe the syntax of special forms for :help.
+var specialFormUsages = map[string]string{
+	"if":             "(if cond then [else])",
+	"set":            "(set name value)",
+	"quote":          "(quote x)",
+	"lambda":         "(lambda (param ... [&key key ...] [. rest]) body ...)",
+	"try":            "(try body ... (catch [type ...] (var) handler ...) ...)",
+	"unwind-protect": "(unwind-protect body cleanup ...)",
+	"parameterize":   "(parameterize ((param value) ...) body ...)",
+	"defstruct":      "(defstruct name field ...)",
+	"module":         "(module name body ...)",
+	"export":         "(export name ...)",
+	"import":         "(import name)",
+}
+
+func runHelp(env **evaluator.Env, builtins map[string]bool, args []string) bool {
+	if len(args) == 0 {
+		fmt.Println("Commands:")
+		for _, cmd := range commands {
+			fmt.Println("  " + cmd.usage)
+		}
+		return false
+	}
+	name := args[0]
+	if usage, ok := specialFormUsages[name]; ok {
+		fmt.Printf("%s is a special form: %s\n", name, usage)
+		return false
+	}
+	value, ok := (*env).Lookup(name)
+	if !ok {
+		fmt.Printf("%s is not defined\n", name)
+		return false
+	}
+	if builtins[name] {
+		fmt.Printf("%s is a builtin: %v\n", name, value)
+		return false
+	}
+	fmt.Printf("%s = %v\n", name, value)
+	return false
+}
+
+func runReset(env **evaluator.Env, builtins map[string]bool, args []string) bool {
+	*env = evaluator.NewEnv()
+	loadRCFile(*env)
+	return false
+}
+
+func runQuit(env **evaluator.Env, builtins map[string]bool, args []string) bool {
+	return true
+}
+
+// commandNames returns the names of REPL commands in order.
+func commandNames() []string {
+	names := make([]string, len(commands))
+	for i := range commands {
+		names[i] = commands[i].name
+	}
+	sort.Strings(names)
+	return names
+}
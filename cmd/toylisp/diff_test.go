@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		a, b, want string
+	}{
+		{a: "a\nb\n", b: "a\nb\n", want: ""},
+		{
+			a:    "a\nb\nc\n",
+			b:    "a\nx\nc\n",
+			want: "--- a\n+++ b\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n",
+		},
+		{
+			a:    "",
+			b:    "a\n",
+			want: "--- a\n+++ b\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			a:    "a",
+			b:    "a\n",
+			want: "--- a\n+++ b\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+a\n",
+		},
+		// Changes far apart are in separate hunks.
+		{
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			b:    "0\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n13\n",
+			want: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+0\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+13\n",
+		},
+		// Changes close together share a hunk.
+		{
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n",
+			b:    "0\n2\n3\n4\n5\n6\n7\n9\n",
+			want: "--- a\n+++ b\n@@ -1,8 +1,8 @@\n-1\n+0\n 2\n 3\n 4\n 5\n 6\n 7\n-8\n+9\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := unifiedDiff("a", "b", tt.a, tt.b); got != tt.want {
+			t.Errorf("unifiedDiff(%q, %q) =\n%s\nwant\n%s", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// TestDiffLines checks that applying the edits to a gives b.
+func TestDiffLines(t *testing.T) {
+	tests := [][2]string{
+		{"abcabba", "cbabac"},
+		{"", "abc"},
+		{"abc", ""},
+		{"xaxbxc", "abc"},
+	}
+	for _, tt := range tests {
+		a, b := strings.Split(tt[0], ""), strings.Split(tt[1], "")
+		var gotA, gotB []string
+		for _, e := range diffLines(a, b) {
+			if e.op != '+' {
+				gotA = append(gotA, e.line)
+			}
+			if e.op != '-' {
+				gotB = append(gotB, e.line)
+			}
+		}
+		if strings.Join(gotA, "") != tt[0] || strings.Join(gotB, "") != tt[1] {
+			t.Errorf("diffLines(%q, %q) gives %q and %q", tt[0], tt[1], gotA, gotB)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/soishi1/toylisp/evaluator"
+	"github.com/soishi1/toylisp/parser"
+	"github.com/soishi1/toylisp/tokenizer"
+)
+
+// dump prints the tokens and/or the AST of the file at path without
+// evaluating it.
+func dump(path string, tokens, ast bool) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	toks, err := tokenizer.Tokenize(string(src))
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if tokens {
+		for _, t := range toks {
+			if t.Type == tokenizer.Space {
+				continue
+			}
+			fmt.Printf("%s:%d:%d\t%v\t%q\n", path, t.Line, t.Column, t.Type, t.Str)
+		}
+	}
+	if !ast {
+		return nil
+	}
+	sexps, err := parser.Parse(toks)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	for _, sexp := range sexps {
+		if err := evaluator.DumpAST(os.Stdout, sexp); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return nil
+}
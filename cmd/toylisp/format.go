@@ -0,0 +1,218 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/soishi1/toylisp/parser"
+	"github.com/soishi1/toylisp/tokenizer"
+)
+
+// runFmt formats the files given in args, or stdin if there are none. By
+// default the result is printed. It returns false on errors.
+func runFmt(args []string) bool {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := fs.Bool("w", false, "write the result to the files instead of printing it")
+	diff := fs.Bool("d", false, "print diffs instead of the result")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return false
+		}
+		formatted, err := format(string(src))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "<stdin>: %v\n", err)
+			return false
+		}
+		fmt.Print(formatted)
+		return true
+	}
+
+	ok := true
+	for _, path := range fs.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			ok = false
+			continue
+		}
+		formatted, err := format(string(src))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			ok = false
+			continue
+		}
+		switch {
+		case *write:
+			if formatted != string(src) {
+				if err := os.WriteFile(path, []byte(formatted), 0644); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					ok = false
+				}
+			}
+		case *diff:
+			fmt.Print(unifiedDiff(path, path+" (formatted)", string(src), formatted))
+		default:
+			fmt.Print(formatted)
+		}
+	}
+	return ok
+}
+
+// indentWidth is the number of spaces the bodies of special forms are
+// indented relative to their open paren.
+const indentWidth = 2
+
+// bodyForms are the special forms whose lines after the first are indented
+// by indentWidth as bodies rather than aligned with their first argument.
+var bodyForms = map[string]bool{
+	"define": true, "set": true, "lambda": true, "let": true, "if": true, "do": true,
+	"dotimes": true, "dolist": true, "when": true, "unless": true,
+	"case": true, "try": true, "catch": true, "unwind-protect": true,
+	"parameterize": true, "with-lock": true, "module": true,
+	"defstruct": true, "select": true, "delay": true,
+}
+
+// opener is an unclosed paren seen by format.
+type opener struct {
+	// col and line are the column and line of the paren, and width is the
+	// length of its token.
+	col, line, width int
+	// head is the first element if it is a symbol.
+	head string
+	// elements is the number of elements seen so far.
+	elements int
+	// argCol is the column of the second element if it is on the line of the
+	// paren, or -1.
+	argCol int
+}
+
+// indent returns the indent of a line inside o that starts with t. Bodies of
+// special forms are indented by indentWidth, arguments of other calls are
+// aligned with the first argument on the line of the paren or indented by
+// indentWidth if there is none, and elements of data are aligned with the
+// first element.
+func (o *opener) indent(t *tokenizer.Token) int {
+	switch {
+	case t.Type == tokenizer.CloseParen:
+		return o.col
+	case bodyForms[o.head]:
+		return o.col + indentWidth
+	case o.argCol >= 0:
+		return o.argCol
+	case o.head != "":
+		return o.col + indentWidth
+	}
+	return o.col + o.width
+}
+
+// format returns src with canonical spacing and indentation. It works on
+// tokens rather than s-expressions so that comments are kept, and keeps the
+// line breaks of src except that blank lines are collapsed into one and
+// close parens are moved to the end of the preceding line.
+func format(src string) (string, error) {
+	tokens, err := tokenizer.Tokenize(src)
+	if err != nil {
+		return "", err
+	}
+	if _, err := parser.Parse(tokens); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	// line and col are where the next character is written.
+	line, col := 0, 0
+	write := func(s string) {
+		b.WriteString(s)
+		if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+			line += strings.Count(s, "\n")
+			col = len(s) - i - 1
+		} else {
+			col += len(s)
+		}
+	}
+	var openers []*opener
+	atLineStart := true
+	var prev *tokenizer.Token
+	for i, t := range tokens {
+		if t.Type == tokenizer.Space {
+			next := nextNonSpace(tokens, i)
+			newlines := strings.Count(t.Str, "\n")
+			switch {
+			case next == nil:
+			case newlines > 0 && (next.Type != tokenizer.CloseParen || isLineComment(prev)):
+				if newlines > 2 {
+					newlines = 2
+				}
+				if !atLineStart {
+					write(strings.Repeat("\n", newlines))
+				}
+				atLineStart = true
+			case prev == nil || atLineStart || opensGroup(prev) || next.Type == tokenizer.CloseParen:
+			default:
+				write(" ")
+			}
+			continue
+		}
+		var top *opener
+		if n := len(openers); n > 0 {
+			top = openers[n-1]
+		}
+		if atLineStart {
+			if top != nil && prev != nil {
+				write(strings.Repeat(" ", top.indent(t)))
+			}
+			atLineStart = false
+		}
+		// A quoted element starts at its quote.
+		startsElement := t.Type != tokenizer.CloseParen && t.Type != tokenizer.Comment &&
+			(prev == nil || prev.Type != tokenizer.Quote)
+		if top != nil && startsElement {
+			top.elements++
+			switch {
+			case top.elements == 1 && t.Type == tokenizer.Symbol && top.width == 1:
+				top.head = t.Str
+			case top.elements == 2 && line == top.line:
+				top.argCol = col
+			}
+		}
+		switch t.Type {
+		case tokenizer.OpenParen, tokenizer.OpenVector:
+			openers = append(openers, &opener{col: col, line: line, width: len(t.Str), argCol: -1})
+		case tokenizer.CloseParen:
+			openers = openers[:len(openers)-1]
+		}
+		write(t.Str)
+		prev = t
+	}
+	if prev == nil {
+		return "", nil
+	}
+	write("\n")
+	return b.String(), nil
+}
+
+func nextNonSpace(tokens []*tokenizer.Token, i int) *tokenizer.Token {
+	for _, t := range tokens[i+1:] {
+		if t.Type != tokenizer.Space {
+			return t
+		}
+	}
+	return nil
+}
+
+// opensGroup reports whether t is directly followed by its contents without
+// a space, as in (x, #(x, and 'x.
+func opensGroup(t *tokenizer.Token) bool {
+	return t.Type == tokenizer.OpenParen || t.Type == tokenizer.OpenVector || t.Type == tokenizer.Quote
+}
+
+func isLineComment(t *tokenizer.Token) bool {
+	return t != nil && t.Type == tokenizer.Comment && strings.HasPrefix(t.Str, ";")
+}
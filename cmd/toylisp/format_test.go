@@ -0,0 +1,75 @@
+package main
+
+import "testing"
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{src: "", want: ""},
+		{src: "(add   1\t2)", want: "(add 1 2)\n"},
+		{src: "( list 1 2 )", want: "(list 1 2)\n"},
+		{src: "(f 1)\n\n\n\n(g 2)\n", want: "(f 1)\n\n(g 2)\n"},
+		{src: "(f 1\n)", want: "(f 1)\n"},
+		{src: "'( a  b)", want: "'(a b)\n"},
+		{src: "(f 1 ; one\n)", want: "(f 1 ; one\n)\n"},
+		// Bodies of special forms are indented by two spaces.
+		{
+			src:  "(define (f x)\n(add x 1))",
+			want: "(define (f x)\n  (add x 1))\n",
+		},
+		{
+			src:  "(lambda (x)\n      (print x)\n x)",
+			want: "(lambda (x)\n  (print x)\n  x)\n",
+		},
+		{
+			src:  "(let ((a 1)\n(b 2))\n(add a b))",
+			want: "(let ((a 1)\n      (b 2))\n  (add a b))\n",
+		},
+		{
+			src:  "(let loop ((i 0))\n(when (< i 3)\n(loop (add i 1))))",
+			want: "(let loop ((i 0))\n  (when (< i 3)\n    (loop (add i 1))))\n",
+		},
+		{
+			src:  "(if (f x)\n1\n2)",
+			want: "(if (f x)\n  1\n  2)\n",
+		},
+		// Arguments of other calls are aligned with the first one.
+		{
+			src:  "(foo (f x)\n1\n2)",
+			want: "(foo (f x)\n     1\n     2)\n",
+		},
+		{
+			src:  "(define x (list 1\n2))",
+			want: "(define x (list 1\n                2))\n",
+		},
+		{
+			src:  "(f\n1)",
+			want: "(f\n  1)\n",
+		},
+		{
+			src:  "#(1\n2)",
+			want: "#(1\n  2)\n",
+		},
+	}
+	for _, tt := range tests {
+		got, err := format(tt.src)
+		if err != nil {
+			t.Errorf("format(%q) failed: %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("format(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+		// Formatting is idempotent.
+		if again, err := format(got); err != nil || again != got {
+			t.Errorf("format(%q) = %q, %v, want %q", got, again, err, got)
+		}
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	if _, err := format("(f 1"); err == nil {
+		t.Error("format of unbalanced parens succeeded")
+	}
+}
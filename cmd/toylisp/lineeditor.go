@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/soishi1/toylisp/evaluator"
+)
+
+// lineReader reads lines of REPL input.
+type lineReader interface {
+	// ReadLine shows prompt and returns the next line without the newline.
+	ReadLine(prompt string) (string, error)
+}
+
+// newLineReader returns a line editor if stdin is a terminal, and a plain
+// line reader otherwise. Both read through stdin, the reader of os.Stdin
+// shared with the input primitives, so that neither loses input the other
+// has buffered.
+func newLineReader(stdin *bufio.Reader, complete func(prefix string) []string) lineReader {
+	if isTerminal(int(os.Stdin.Fd())) {
+		return &lineEditor{
+			in:       stdin,
+			out:      os.Stdout,
+			fd:       int(os.Stdin.Fd()),
+			complete: complete,
+		}
+	}
+	return &plainReader{in: stdin}
+}
+
+type plainReader struct {
+	in *bufio.Reader
+}
+
+func (r *plainReader) ReadLine(prompt string) (string, error) {
+	line, err := r.in.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return "", io.EOF
+	}
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
+
+// lineEditor reads lines from a terminal in raw mode, and completes the word
+// before the cursor when Tab is pressed.
+type lineEditor struct {
+	in  *bufio.Reader
+	out io.Writer
+	fd  int
+	// complete returns candidates that start with prefix.
+	complete func(prefix string) []string
+}
+
+const (
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyBackspace = 8
+	keyTab       = 9
+	keyLF        = 10
+	keyCR        = 13
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+func (le *lineEditor) ReadLine(prompt string) (string, error) {
+	restore, err := makeRaw(le.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	fmt.Fprint(le.out, prompt)
+	var line []byte
+	for {
+		b, err := le.in.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case keyCR, keyLF:
+			fmt.Fprint(le.out, "\n")
+			return string(line), nil
+		case keyCtrlD:
+			if len(line) == 0 {
+				fmt.Fprint(le.out, "\n")
+				return "", io.EOF
+			}
+		case keyCtrlC:
+			// Discard the line being edited.
+			fmt.Fprint(le.out, "^C\n", prompt)
+			line = nil
+		case keyBackspace, keyDelete:
+			if len(line) > 0 {
+				_, size := utf8.DecodeLastRune(line)
+				line = line[:len(line)-size]
+				fmt.Fprint(le.out, "\b \b")
+			}
+		case keyTab:
+			line = le.completeLine(prompt, line)
+		case keyEscape:
+			le.skipEscapeSequence()
+		default:
+			if b >= ' ' || b >= utf8.RuneSelf {
+				line = append(line, b)
+				le.out.Write([]byte{b})
+			}
+		}
+	}
+}
+
+// skipEscapeSequence discards escape sequences such as arrow keys, which
+// aren't supported.
+func (le *lineEditor) skipEscapeSequence() {
+	b, err := le.in.ReadByte()
+	if err != nil || b != '[' {
+		return
+	}
+	for {
+		b, err := le.in.ReadByte()
+		if err != nil || (b >= 0x40 && b <= 0x7e) {
+			return
+		}
+	}
+}
+
+// completeLine completes the word at the end of line. If there are several
+// candidates, it inserts their common prefix and lists them.
+func (le *lineEditor) completeLine(prompt string, line []byte) []byte {
+	start := strings.LastIndexAny(string(line), " \t()'") + 1
+	prefix := string(line[start:])
+	candidates := le.complete(prefix)
+	if len(candidates) == 0 {
+		return line
+	}
+	common := commonPrefix(candidates)
+	if len(common) > len(prefix) {
+		rest := common[len(prefix):]
+		fmt.Fprint(le.out, rest)
+		return append(line, rest...)
+	}
+	if len(candidates) > 1 {
+		fmt.Fprintf(le.out, "\n%s\n%s%s", strings.Join(candidates, " "), prompt, line)
+	}
+	return line
+}
+
+func commonPrefix(strs []string) string {
+	prefix := strs[0]
+	for _, s := range strs[1:] {
+		for !strings.HasPrefix(s, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// completeSymbol returns REPL commands, special forms, and names bound in env
+// that start with prefix.
+func completeSymbol(env *evaluator.Env, prefix string) []string {
+	var candidates []string
+	seen := make(map[string]bool)
+	names := append(commandNames(), evaluator.SpecialForms()...)
+	for _, name := range append(names, env.Names()...) {
+		if strings.HasPrefix(name, prefix) && !seen[name] {
+			seen[name] = true
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
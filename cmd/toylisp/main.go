@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/soishi1/toylisp"
+	"github.com/soishi1/toylisp/evaluator"
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+var (
+	expr        = flag.String("e", "", "evaluate `expressions`, print the result and exit")
+	debugOutput = flag.Bool("debug", false, "print tokens and s-expressions in the REPL before results")
+	// prompt and continuationPrompt override *prompt* and
+	// *continuation-prompt* set by the rc file.
+	prompt             = flag.String("prompt", defaultPrompt, "REPL `prompt`")
+	continuationPrompt = flag.String("continuation-prompt", defaultContinuationPrompt, "REPL `prompt` for continued lines of multi-line forms")
+	dumpTokens         = flag.Bool("dump-tokens", false, "print the tokens of the script instead of running it")
+	dumpAST            = flag.Bool("dump-ast", false, "print the compiled AST of the script instead of running it")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [-e expressions] [script.lisp [args...]]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s check file.lisp...\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s fmt [-w | -d] [file.lisp...]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s version\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	switch flag.Arg(0) {
+	case "check":
+		if !runCheck(flag.Args()[1:]) {
+			os.Exit(1)
+		}
+		return
+	case "fmt":
+		if !runFmt(flag.Args()[1:]) {
+			os.Exit(1)
+		}
+		return
+	case "version":
+		printVersion()
+		return
+	}
+
+	if *dumpTokens || *dumpAST {
+		if flag.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "-dump-tokens and -dump-ast require a script")
+			os.Exit(2)
+		}
+		if err := dump(flag.Arg(0), *dumpTokens, *dumpAST); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	in := toylisp.New()
+	if *expr != "" {
+		value, err := in.EvalString(*expr)
+		if err != nil {
+			exit(err)
+		}
+		printValue(value)
+		return
+	}
+	if flag.NArg() > 0 {
+		if err := runScript(in, flag.Arg(0), flag.Args()[1:]); err != nil {
+			exit(err)
+		}
+		return
+	}
+	os.Exit(repl(in.Env()))
+}
+
+// exit exits with the code passed to the exit primitive if err is returned
+// by it, and otherwise prints err and exits with 1.
+func exit(err error) {
+	var exitErr *toylisp.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.Code)
+	}
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+// runScript evaluates the file at path with args bound to *args* as a list of
+// strings.
+func runScript(in *toylisp.Interpreter, path string, args []string) error {
+	argSExps := make([]*sexpressions.SExp, len(args))
+	for i := range args {
+		argSExps[i] = sexpressions.NewString(args[i])
+	}
+	in.Env().Set("*args*", evaluator.NewValue(sexpressions.NewList(argSExps...)))
+	in.SetCommandLine(append([]string{path}, args...))
+	_, err := in.EvalFile(path)
+	return err
+}
+
+// printVersion prints the interpreter version along with the Go version and
+// the VCS revision the binary was built from, if known.
+func printVersion() {
+	fmt.Printf("toylisp %s %s", evaluator.Version, runtime.Version())
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				fmt.Printf(" %s", s.Value)
+			}
+		}
+	}
+	fmt.Println()
+}
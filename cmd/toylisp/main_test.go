@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain runs main instead of the tests when the test binary is run by
+// runToylisp, so that the tests can check the exit codes and output of whole
+// command lines.
+func TestMain(m *testing.M) {
+	if os.Getenv("TOYLISP_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runToylisp runs the command with args and stdin, and returns its output
+// and exit code.
+func runToylisp(t *testing.T, stdin string, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	// HOME is a new directory so that no rc file is loaded.
+	cmd.Env = append(os.Environ(), "TOYLISP_TEST_MAIN=1", "HOME="+t.TempDir())
+	cmd.Stdin = strings.NewReader(stdin)
+	var out, errOut bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return out.String(), errOut.String(), code
+}
+
+// writeFile writes src to a file named name in a temporary directory and
+// returns its path.
+func writeFile(t *testing.T, name, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(src), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCommandLine(t *testing.T) {
+	script := writeFile(t, "script.lisp", "(print *args*)\n(exit 3)\n")
+	failing := writeFile(t, "failing.lisp", "(car 1)\n")
+	unbalanced := writeFile(t, "unbalanced.lisp", "(f 1\n")
+	unused := writeFile(t, "unused.lisp", "(define f (lambda (x y) x))\n")
+	tests := []struct {
+		name     string
+		args     []string
+		stdin    string
+		wantOut  string
+		wantErr  string
+		wantCode int
+	}{
+		{name: "eval", args: []string{"-e", "(add 1 2)"}, wantOut: "3\n"},
+		{name: "eval error", args: []string{"-e", "(car 1)"}, wantErr: "car", wantCode: 1},
+		{name: "script", args: []string{script, "a", "b"}, wantOut: "(\"a\" \"b\")", wantCode: 3},
+		{name: "script error", args: []string{failing}, wantErr: "car", wantCode: 1},
+		{name: "repl", stdin: "(define x 2)\n(add x\n 1)\n", wantOut: "3\n"},
+		{name: "repl env", stdin: "(define x 2)\n:env\n", wantOut: "x = 2\n"},
+		{name: "repl quit", stdin: ":quit\n(exit 4)\n"},
+		{name: "unknown flag", args: []string{"-bogus"}, wantErr: "usage:", wantCode: 2},
+		{name: "dump without script", args: []string{"-dump-tokens"}, wantErr: "require a script", wantCode: 2},
+		{name: "dump tokens", args: []string{"-dump-tokens", script}, wantOut: "*args*"},
+		{name: "check", args: []string{"check", unbalanced}, wantErr: unbalanced + ":1:1: unmatched (", wantCode: 1},
+		{name: "check ok", args: []string{"check", script}},
+		{name: "check warning", args: []string{"check", unused}, wantErr: unused + ":1:22: warning: parameter y is never used"},
+		{name: "fmt stdin", args: []string{"fmt"}, stdin: "( add  1 2 )", wantOut: "(add 1 2)\n"},
+		{name: "version", args: []string{"version"}, wantOut: "toylisp "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, code := runToylisp(t, tt.stdin, tt.args...)
+			if code != tt.wantCode {
+				t.Errorf("exit code = %v, want %v; stderr: %s", code, tt.wantCode, stderr)
+			}
+			if !strings.Contains(stdout, tt.wantOut) {
+				t.Errorf("stdout = %q, want it to contain %q", stdout, tt.wantOut)
+			}
+			if !strings.Contains(stderr, tt.wantErr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFmtCommand(t *testing.T) {
+	src := "(define (f x)\n(add x 1))\n"
+	formatted := "(define (f x)\n  (add x 1))\n"
+	path := writeFile(t, "f.lisp", src)
+
+	stdout, stderr, code := runToylisp(t, "", "fmt", "-d", path)
+	want := "--- " + path + "\n+++ " + path + " (formatted)\n@@ -1,2 +1,2 @@\n (define (f x)\n-(add x 1))\n+  (add x 1))\n"
+	if code != 0 || stdout != want {
+		t.Errorf("fmt -d = %q, %v, %s, want %q", stdout, code, stderr, want)
+	}
+
+	stdout, _, _ = runToylisp(t, "", "fmt", path)
+	if stdout != formatted {
+		t.Errorf("fmt = %q, want %q", stdout, formatted)
+	}
+
+	if _, stderr, code := runToylisp(t, "", "fmt", "-w", path); code != 0 {
+		t.Fatalf("fmt -w failed with %v: %s", code, stderr)
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != formatted {
+		t.Errorf("file after fmt -w = %q, %v, want %q", b, err, formatted)
+	}
+	if stdout, _, _ := runToylisp(t, "", "fmt", "-d", path); stdout != "" {
+		t.Errorf("fmt -d of a formatted file = %q, want none", stdout)
+	}
+
+	bad := writeFile(t, "bad.lisp", "(f 1\n")
+	if _, stderr, code := runToylisp(t, "", "fmt", bad); code != 1 || !strings.Contains(stderr, bad) {
+		t.Errorf("fmt of a bad file = %v, %q, want 1 and an error", code, stderr)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/soishi1/toylisp/evaluator"
+	"github.com/soishi1/toylisp/parser"
+	"github.com/soishi1/toylisp/printer"
+	"github.com/soishi1/toylisp/sexpressions"
+	"github.com/soishi1/toylisp/tokenizer"
+)
+
+const (
+	defaultPrompt             = "toylisp> "
+	defaultContinuationPrompt = "...> "
+	// rcFileName is the name of the file in the home directory that the REPL
+	// evaluates on start up. It can set *prompt* and *continuation-prompt*.
+	rcFileName = ".toylisprc"
+)
+
+// loadRCFile evaluates the rc file in env if it exists, and then applies the
+// prompt flags given explicitly.
+func loadRCFile(env *evaluator.Env) {
+	if home, err := os.UserHomeDir(); err == nil {
+		path := filepath.Join(home, rcFileName)
+		if _, err := os.Stat(path); err == nil {
+			if _, err := env.LoadFile(path); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "prompt":
+			env.Set("*prompt*", newString(*prompt))
+		case "continuation-prompt":
+			env.Set("*continuation-prompt*", newString(*continuationPrompt))
+		}
+	})
+}
+
+// promptString returns the string bound to name in env, or def if it isn't
+// bound to a string.
+func promptString(env *evaluator.Env, name, def string) string {
+	if value, ok := env.Lookup(name); ok {
+		if s, ok := value.AsString(); ok {
+			return s
+		}
+	}
+	return def
+}
+
+func newString(s string) *evaluator.Value {
+	return evaluator.NewValue(sexpressions.NewString(s))
+}
+
+// interrupter turns SIGINT into Env.Interrupt while an evaluation is
+// running, so that Ctrl-C returns to the prompt instead of killing the REPL.
+type interrupter struct {
+	mu sync.Mutex
+	// env is the environment being evaluated, or nil if none is.
+	env *evaluator.Env
+}
+
+func newInterrupter() *interrupter {
+	in := &interrupter{}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt)
+	go func() {
+		for range ch {
+			in.mu.Lock()
+			if in.env != nil {
+				in.env.Interrupt()
+			}
+			in.mu.Unlock()
+		}
+	}()
+	return in
+}
+
+// run calls f while forwarding SIGINT to env. An interrupt left over from a
+// previous evaluation, such as a SIGINT that arrived as it returned, is
+// discarded first.
+func (in *interrupter) run(env *evaluator.Env, f func()) {
+	in.mu.Lock()
+	env.ClearInterrupt()
+	in.env = env
+	in.mu.Unlock()
+	defer func() {
+		in.mu.Lock()
+		in.env = nil
+		in.mu.Unlock()
+	}()
+	f()
+}
+
+// repl reads and evaluates forms interactively until the input ends or the
+// program calls exit, and returns the status to exit with.
+func repl(env *evaluator.Env) int {
+	loadRCFile(env)
+	in := newInterrupter()
+	stdin := bufio.NewReader(os.Stdin)
+	env.SetInput(stdin)
+	reader := newLineReader(stdin, func(prefix string) []string {
+		return completeSymbol(env, prefix)
+	})
+	builtins := make(map[string]bool)
+	for _, name := range evaluator.NewEnv().Names() {
+		builtins[name] = true
+	}
+	var lines []string
+	for {
+		p := promptString(env, "*prompt*", defaultPrompt)
+		if len(lines) > 0 {
+			p = promptString(env, "*continuation-prompt*", defaultContinuationPrompt)
+		}
+		line, err := reader.ReadLine(p)
+		if err == io.EOF {
+			return 0
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 0
+		}
+		if len(lines) == 0 {
+			if cmd, ok := findCommand(line); ok {
+				var quit bool
+				in.run(env, func() {
+					quit = cmd.run(&env, builtins, commandArgs(line))
+				})
+				if quit {
+					return 0
+				}
+				continue
+			}
+		}
+		lines = append(lines, line)
+		input := strings.Join(lines, "\n")
+		if !inputComplete(input) {
+			continue
+		}
+		lines = nil
+		tokens, err := tokenizer.Tokenize(input)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		if *debugOutput {
+			fmt.Println(tokens)
+		}
+		sexps, err := parser.Parse(tokens)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		if *debugOutput {
+			fmt.Println(sexps)
+		}
+		var exitErr *evaluator.ExitError
+		in.run(env, func() {
+			for i := range sexps {
+				value, err := env.Eval(sexps[i])
+				if errors.As(err, &exitErr) {
+					return
+				}
+				if errors.Is(err, evaluator.ErrInterrupted) {
+					fmt.Println(err)
+					return
+				}
+				if err != nil {
+					fmt.Println(err)
+					continue
+				}
+				printValue(value)
+			}
+		})
+		if exitErr != nil {
+			return exitErr.Code
+		}
+	}
+}
+
+// printValue prints the result of an evaluation. S-expressions too long for
+// a line are printed over multiple lines.
+func printValue(value *evaluator.Value) {
+	if value.SExp == nil {
+		fmt.Println(value)
+		return
+	}
+	fmt.Println(printer.Sprint(value.SExp))
+}
+
+// inputComplete reports whether s has no unclosed parens, strings, or block
+// comments, so that the REPL can stop reading more lines. Extra close parens
+// and invalid tokens count as complete so that they are reported.
+func inputComplete(s string) bool {
+	t := tokenizer.NewTokenizer()
+	tokens, err := t.Feed(s)
+	if err != nil {
+		return true
+	}
+	if t.Incomplete() {
+		return false
+	}
+	rest, err := t.Close()
+	if err != nil {
+		return true
+	}
+	depth := 0
+	for _, token := range append(tokens, rest...) {
+		switch token.Type {
+		case tokenizer.OpenParen, tokenizer.OpenVector:
+			depth++
+		case tokenizer.CloseParen:
+			depth--
+		}
+	}
+	return depth <= 0
+}
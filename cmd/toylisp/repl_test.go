@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestInputComplete(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{src: "", want: true},
+		{src: "(add 1 2)", want: true},
+		{src: "(add 1", want: false},
+		{src: "(define (f x)\n  (add x", want: false},
+		{src: "\"abc", want: false},
+		{src: "\"(\"", want: true},
+		{src: "#(1 2", want: false},
+		{src: "; (", want: true},
+		{src: "(f))", want: true},
+	}
+	for _, tt := range tests {
+		if got := inputComplete(tt.src); got != tt.want {
+			t.Errorf("inputComplete(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestFindCommand(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{line: ":quit", want: ":quit"},
+		{line: "  :load  f.lisp", want: ":load"},
+		{line: ":unknown", want: ""},
+		{line: "(quit)", want: ""},
+		{line: "", want: ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if cmd, ok := findCommand(tt.line); ok {
+			got = cmd.name
+		}
+		if got != tt.want {
+			t.Errorf("findCommand(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
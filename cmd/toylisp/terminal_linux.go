@@ -0,0 +1,10 @@
+package main
+
+import "syscall"
+
+// getTermios and setTermios are the ioctl requests that get and set the
+// terminal attributes.
+const (
+	getTermios = syscall.TCGETS
+	setTermios = syscall.TCSETS
+)
@@ -0,0 +1,119 @@
+package evaluator
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// maxCachedASTs is the maximum number of entries in an astCache. The least
+// recently used entry is evicted to make room for a new one, so that forms
+// evaluated only once aren't kept alive forever.
+const maxCachedASTs = 1024
+
+// astCache maps forms passed to Eval to their compiled ASTs, so that
+// evaluating the same form again skips makeAST, even if it was parsed again
+// such as when a REPL line is entered again or a file is loaded again.
+//
+// Forms are the same if they are Equal and read from the same positions, so
+// that the positions in errors stay those of the form evaluated.
+type astCache struct {
+	mu sync.Mutex
+	// entries are the *astCacheEntry values from the most recently used.
+	entries list.List
+	// byHash maps the hashes of the forms to their entries.
+	byHash map[uint64][]*list.Element
+}
+
+type astCacheEntry struct {
+	hash uint64
+	form *sexpressions.SExp
+	ast  ast
+}
+
+func newASTCache() *astCache {
+	return &astCache{byHash: make(map[uint64][]*list.Element)}
+}
+
+// get returns the AST of the form that is the same as sexp, whose hash is
+// hash.
+func (c *astCache) get(hash uint64, sexp *sexpressions.SExp) (ast, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, elem := range c.byHash[hash] {
+		entry := elem.Value.(*astCacheEntry)
+		if entry.form == sexp || sameForm(entry.form, sexp) {
+			c.entries.MoveToFront(elem)
+			return entry.ast, true
+		}
+	}
+	return nil, false
+}
+
+// put adds the AST a of sexp, whose hash is hash, evicting the least recently
+// used entry if the cache is full.
+func (c *astCache) put(hash uint64, sexp *sexpressions.SExp, a ast) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries.Len() >= maxCachedASTs {
+		c.remove(c.entries.Back())
+	}
+	elem := c.entries.PushFront(&astCacheEntry{hash: hash, form: sexp, ast: a})
+	c.byHash[hash] = append(c.byHash[hash], elem)
+}
+
+func (c *astCache) remove(elem *list.Element) {
+	entry := c.entries.Remove(elem).(*astCacheEntry)
+	elems := c.byHash[entry.hash]
+	for i := range elems {
+		if elems[i] == elem {
+			elems = append(elems[:i], elems[i+1:]...)
+			break
+		}
+	}
+	if len(elems) == 0 {
+		delete(c.byHash, entry.hash)
+	} else {
+		c.byHash[entry.hash] = elems
+	}
+}
+
+// len returns the number of entries.
+func (c *astCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries.Len()
+}
+
+// sameForm reports whether a and b are Equal and all of their elements are
+// at the same positions.
+func sameForm(a, b *sexpressions.SExp) bool {
+	return a.Equal(b) && samePositions(a, b)
+}
+
+// samePositions reports whether the elements of a and b, which are Equal, are
+// at the same positions.
+func samePositions(a, b *sexpressions.SExp) bool {
+	if a.Pos != b.Pos {
+		return false
+	}
+	if aPair, ok := a.AsPair(); ok {
+		bPair, _ := b.AsPair()
+		return samePositions(aPair.Car, bPair.Car) && samePositions(aPair.Cdr, bPair.Cdr)
+	}
+	aElems, ok := a.AsList()
+	if !ok {
+		aElems, _ = a.AsVector()
+	}
+	bElems, ok := b.AsList()
+	if !ok {
+		bElems, _ = b.AsVector()
+	}
+	for i := range aElems {
+		if !samePositions(aElems[i], bElems[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package evaluator
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/soishi1/toylisp/parser"
+	"github.com/soishi1/toylisp/sexpressions"
+	"github.com/soishi1/toylisp/tokenizer"
+)
+
+func parseForm(t *testing.T, src string) *sexpressions.SExp {
+	t.Helper()
+	toks, err := tokenizer.Tokenize(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sexps, err := parser.Parse(toks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sexps[0]
+}
+
+func TestCompileCache(t *testing.T) {
+	e := NewEnv()
+	a, err := e.compile(parseForm(t, "(lambda (x) (add x 1))"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The same source parsed again makes another SExp, which hits the cache.
+	if b, err := e.compile(parseForm(t, "(lambda (x) (add x 1))")); err != nil || b != a {
+		t.Errorf("compiling the re-parsed form made another AST")
+	}
+	// The AST of a form at another position would report errors there.
+	if b, err := e.compile(parseForm(t, "(lambda (x)  (add x 1))")); err != nil || b == a {
+		t.Errorf("compiling the form at another position reused the AST")
+	}
+	if b, err := e.compile(parseForm(t, "(lambda (x) (add x 2))")); err != nil || b == a {
+		t.Errorf("compiling another form reused the AST")
+	}
+}
+
+func TestCompileCacheEviction(t *testing.T) {
+	e := NewEnv()
+	compile := func(i int) ast {
+		a, err := e.compile(parseForm(t, fmt.Sprintf("(add %d 1)", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	first, second := compile(0), compile(1)
+	for i := 2; i < maxCachedASTs; i++ {
+		compile(i)
+	}
+	// Using the 1st form makes the 2nd the least recently used one.
+	compile(0)
+	compile(maxCachedASTs)
+	if n := e.interp.astCache.len(); n != maxCachedASTs {
+		t.Errorf("got %v entries, want %v", n, maxCachedASTs)
+	}
+	if compile(0) != first {
+		t.Errorf("the recently used form was evicted")
+	}
+	if compile(1) == second {
+		t.Errorf("the least recently used form wasn't evicted")
+	}
+}
@@ -0,0 +1,115 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// maxBacktraceFrames is the maximum number of frames recorded in a backtrace,
+// so that errors from deep recursions stay small.
+const maxBacktraceFrames = 64
+
+// Frame is a function application that was pending when an error occurred.
+type Frame struct {
+	// Name is the name of the applied function, such as the symbol it was
+	// called by.
+	Name string
+	// File is the path of the file the application was read from, or empty if
+	// it isn't known.
+	File string
+	// Pos is the position of the application.
+	Pos sexpressions.Pos
+}
+
+func (f Frame) String() string {
+	if f.File == "" {
+		return fmt.Sprintf("%s (%v)", f.Name, f.Pos)
+	}
+	return fmt.Sprintf("%s (%s:%v)", f.Name, f.File, f.Pos)
+}
+
+// backtraceError is an error with the applications pending when it occurred.
+type backtraceError struct {
+	err error
+	// frames are the applications from the innermost one.
+	frames []Frame
+	// omitted is the number of outer frames not recorded.
+	omitted int
+}
+
+func (e *backtraceError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.err.Error())
+	for _, f := range e.frames {
+		fmt.Fprintf(&b, "\n\tat %v", f)
+	}
+	if e.omitted > 0 {
+		fmt.Fprintf(&b, "\n\t... %d more", e.omitted)
+	}
+	return b.String()
+}
+
+func (e *backtraceError) Unwrap() error {
+	return e.err
+}
+
+// Backtrace returns the function applications that were pending when err
+// occurred, from the innermost one, or nil if err has no backtrace.
+func Backtrace(err error) []Frame {
+	var bt *backtraceError
+	if !errors.As(err, &bt) {
+		return nil
+	}
+	return append([]Frame(nil), bt.frames...)
+}
+
+// withFrame adds f to the backtrace of err as its outermost frame.
+// Continuation invocations are passed through as is since they are not
+// failures.
+func withFrame(err error, f Frame) error {
+	if isContinuationInvoked(err) {
+		return err
+	}
+	var bt *backtraceError
+	if !errors.As(err, &bt) {
+		return &backtraceError{err: err, frames: []Frame{f}}
+	}
+	if len(bt.frames) < maxBacktraceFrames {
+		bt.frames = append(bt.frames, f)
+	} else {
+		bt.omitted++
+	}
+	return err
+}
+
+// withFile sets the file of the position and the backtrace frames of err
+// that have no file to path, because they are in the file being evaluated.
+func withFile(err error, path string) error {
+	var positioned *PositionError
+	if errors.As(err, &positioned) && positioned.File == "" {
+		positioned.File = path
+	}
+	var bt *backtraceError
+	if errors.As(err, &bt) {
+		for i := range bt.frames {
+			if bt.frames[i].File == "" {
+				bt.frames[i].File = path
+			}
+		}
+	}
+	return err
+}
+
+// frameName returns the name of the function fn applied by funcAST.
+func frameName(funcAST ast, fn *Value) string {
+	if name, ok := funcAST.form().AsSymbol(); ok {
+		return name
+	}
+	if fn.valueType == Primitive {
+		return fn.value.(*primitive).name
+	}
+	return fn.String()
+}
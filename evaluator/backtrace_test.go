@@ -0,0 +1,46 @@
+package evaluator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBacktrace(t *testing.T) {
+	src := "(define inner (lambda (x) (car x)))\n" +
+		"(define outer (lambda (x)\n  (inner x)))\n" +
+		"(outer 1)"
+	_, err := NewEnv().EvalSource("a.lisp", src)
+	if conditionType(err) != typeErrorCondition {
+		t.Fatalf("got error %v, want %v", err, typeErrorCondition)
+	}
+	var got []string
+	for _, f := range Backtrace(err) {
+		got = append(got, f.String())
+	}
+	want := []string{"car (a.lisp:1:27)", "inner (a.lisp:3:3)", "outer (a.lisp:4:1)"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Backtrace = %q, want %q", got, want)
+	}
+	if !strings.Contains(err.Error(), "\n\tat inner (a.lisp:3:3)\n") {
+		t.Errorf("error %q doesn't show the backtrace", err)
+	}
+
+	if Backtrace(newCondition(errorCondition, "no frames")) != nil {
+		t.Error("Backtrace of an error without one isn't nil")
+	}
+}
+
+func TestBacktraceLimit(t *testing.T) {
+	src := "(define f (lambda (n) (if (= n 0) (car n) (add 1 (f (sub n 1))))))\n(f 100)"
+	_, err := NewEnv().EvalString(src)
+	if err == nil {
+		t.Fatal("got no error")
+	}
+	if n := len(Backtrace(err)); n != maxBacktraceFrames {
+		t.Errorf("got %v frames, want %v", n, maxBacktraceFrames)
+	}
+	if !strings.Contains(err.Error(), "more") {
+		t.Errorf("error %q doesn't count the omitted frames", err)
+	}
+}
@@ -0,0 +1,91 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// caseAST is (case key ((datum ...) body ...) ... [(else body ...)]), which
+// evaluates the body of the first clause with a datum equal? to the value of
+// key, or else the body of the else clause. The datums aren't evaluated. The
+// value is that of the last expression of the body, or nil if no clause
+// matches.
+type caseAST struct {
+	astNode
+	keyAST ast
+	// clauses maps each datum to the index of the first clause listing it
+	// in clauseASTs, so that the clause is found without comparing the key
+	// with every datum.
+	clauses    *sexpressions.Map
+	clauseASTs [][]ast
+	elseASTs   []ast
+}
+
+func (a *caseAST) dumpFields() []dumpField {
+	// clauses is written as datum->index pairs rather than the internals of
+	// the map.
+	var clauses []string
+	for _, datum := range a.clauses.Keys() {
+		index, _ := a.clauses.Get(datum)
+		clauses = append(clauses, fmt.Sprintf("%v->%v", datum, index))
+	}
+	return []dumpField{
+		{"keyAST", a.keyAST},
+		{"clauses", clauses},
+		{"clauseASTs", a.clauseASTs},
+		{"elseASTs", a.elseASTs},
+	}
+}
+
+func (a *caseAST) Eval(e *Env) (*Value, error) {
+	key, err := eval(e, a.keyAST)
+	if err != nil {
+		return nil, err
+	}
+	if i, ok := a.clauses.Get(toSExp(key)); ok {
+		n, _ := i.AsInt()
+		return evalSequence(e, a.clauseASTs[n])
+	}
+	return evalSequence(e, a.elseASTs)
+}
+
+// makeCaseAST makes AST for (case key ((datum ...) body ...) ... [(else body ...)]).
+func makeCaseAST(sexps []*sexpressions.SExp, sc *scope) (ast, error) {
+	if len(sexps) < 2 {
+		return nil, fmt.Errorf("case requires a key: %+v", sexps)
+	}
+	keyAST, err := makeAST(sexps[1], sc)
+	if err != nil {
+		return nil, err
+	}
+	a := &caseAST{keyAST: keyAST, clauses: sexpressions.NewMap()}
+	for i, clause := range sexps[2:] {
+		list, ok := clause.AsList()
+		if !ok || len(list) < 1 {
+			return nil, fmt.Errorf("case clause must be ((datum ...) body ...): %v", clause)
+		}
+		bodyASTs, err := makeASTs(list[1:], sc)
+		if err != nil {
+			return nil, err
+		}
+		if symbol, ok := list[0].AsSymbol(); ok && symbol == "else" {
+			if i != len(sexps)-3 {
+				return nil, fmt.Errorf("case else clause must be the last one: %v", clause)
+			}
+			a.elseASTs = bodyASTs
+			continue
+		}
+		datums, ok := list[0].AsList()
+		if !ok {
+			return nil, fmt.Errorf("case clause must be ((datum ...) body ...): %v", clause)
+		}
+		for _, datum := range datums {
+			if _, ok := a.clauses.Get(datum); !ok {
+				a.clauses.Set(datum, sexpressions.NewInt(len(a.clauseASTs)))
+			}
+		}
+		a.clauseASTs = append(a.clauseASTs, bodyASTs)
+	}
+	return a, nil
+}
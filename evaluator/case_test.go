@@ -0,0 +1,17 @@
+package evaluator
+
+import "testing"
+
+func TestCase(t *testing.T) {
+	tests := []evalTest{
+		{src: "(case 2 ((1) :one) ((2 3) :two-or-three) (else :other))", want: ":two-or-three"},
+		{src: "(case 4 ((1) :one) (else :other))", want: ":other"},
+		{src: "(case 4 ((1) :one))", want: "()"},
+		{src: "(case :a ((:a) 1))", want: "1"},
+		{src: `(case "s" (("s") 1))`, want: "1"},
+		{src: "(case (list 1 2) (((1 2)) 1))", want: "1"},
+		{src: "(case -0.0 ((0.0) :zero) (else :other))", want: ":zero"},
+		{src: "(map-get (make-map (list (cons 0.0 1))) -0.0)", want: "1"},
+	}
+	runEvalTests(t, tests)
+}
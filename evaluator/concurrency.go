@@ -0,0 +1,526 @@
+package evaluator
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// task is a call running on its own goroutine, started by spawn.
+type task struct {
+	// done is closed when the call returns.
+	done  chan struct{}
+	value *Value
+	err   error
+}
+
+// spawn calls fn with no arguments on a new goroutine. The call binds its
+// variables in its own environment as any call does, so variables it defines
+// aren't shared with other tasks. Maps and vectors may be shared, and are
+// synchronized so that tasks can update them concurrently.
+func spawn(e *Env, fn *Value) *task {
+	t := &task{done: make(chan struct{})}
+	ec := e.interp.evalContext()
+	ec.tasks.Add(1)
+	// The task is called from a copy of e, since parameterize changes the
+	// bindings of e while the task runs.
+	caller := &Env{parent: e, interp: e.interp, out: e.out, depth: e.depth, dynamic: e.dynamic}
+	go func() {
+		defer ec.tasks.Done()
+		defer close(t.done)
+		t.value, t.err = apply(caller, fn, nil)
+		if isContinuationInvoked(t.err) {
+			t.err = newCondition(errorCondition, "continuation invoked outside the task that captured it")
+		}
+	}()
+	return t
+}
+
+func asTask(name string, v *Value) (*task, error) {
+	if v.valueType != Task {
+		return nil, newCondition(typeErrorCondition, "%v argument is not task: %v", name, v)
+	}
+	return v.value.(*task), nil
+}
+
+// concurrencyPrimitives are primitives that run code concurrently.
+var concurrencyPrimitives = map[string]builtin{
+	// (spawn fn) calls fn with no arguments on a new goroutine, and returns a
+	// task to join.
+	"spawn": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		switch args[0].valueType {
+		case Lambda, Primitive:
+		default:
+			return nil, newCondition(typeErrorCondition, "spawn argument is not function: %v", args[0])
+		}
+		return &Value{valueType: Task, value: spawn(e, args[0])}, nil
+	}},
+	// (join task) waits for task to finish and returns the value of its
+	// function. If the function failed, join fails with the same error.
+	"join": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		t, err := asTask("join", args[0])
+		if err != nil {
+			return nil, err
+		}
+		if _, _, _, err := e.block([]reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(t.done)},
+		}); err != nil {
+			return nil, err
+		}
+		return t.value, t.err
+	}},
+	// (task-done? task) returns whether task has finished.
+	"task-done?": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		t, err := asTask("task-done?", args[0])
+		if err != nil {
+			return nil, err
+		}
+		select {
+		case <-t.done:
+			return True, nil
+		default:
+			return False, nil
+		}
+	}},
+}
+
+// NewChannel wraps ch into a Value, so that Go programs can communicate with
+// programs through it.
+func NewChannel(ch chan *Value) *Value {
+	return &Value{valueType: Channel, value: ch}
+}
+
+// AsChannel returns the Go channel of a channel made by chan or NewChannel.
+func (v *Value) AsChannel() (chan *Value, bool) {
+	if v.valueType != Channel {
+		return nil, false
+	}
+	return v.value.(chan *Value), true
+}
+
+func asChannel(name string, v *Value) (chan *Value, error) {
+	ch, ok := v.AsChannel()
+	if !ok {
+		return nil, newCondition(typeErrorCondition, "%v argument is not channel: %v", name, v)
+	}
+	return ch, nil
+}
+
+// block waits for one of cases as reflect.Select does, but also stops when
+// the evaluation is interrupted or its context is done. Sending to a closed
+// channel fails instead of panicking.
+func (e *Env) block(cases []reflect.SelectCase) (chosen int, recv reflect.Value, recvOK bool, err error) {
+	n := len(cases)
+	ctx := e.interp.context()
+	cases = append(cases,
+		reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(e.interp.interruptCh)},
+	)
+	defer func() {
+		if r := recover(); r != nil {
+			err = newCondition(errorCondition, "send on closed channel")
+		}
+	}()
+	chosen, recv, recvOK = reflect.Select(cases)
+	switch chosen {
+	case n:
+		return 0, reflect.Value{}, false, &cancelledError{err: ctx.Err()}
+	case n + 1:
+		atomic.StoreInt32(&e.interp.interrupted, 0)
+		return 0, reflect.Value{}, false, ErrInterrupted
+	}
+	return chosen, recv, recvOK, nil
+}
+
+// received returns the value received by block, which is nil if the channel
+// is closed.
+func received(recv reflect.Value, ok bool) *Value {
+	if !ok {
+		return Nil
+	}
+	return recv.Interface().(*Value)
+}
+
+// channelPrimitives are primitives that communicate through channels.
+var channelPrimitives = map[string]builtin{
+	// (chan [capacity]) returns a channel with the buffer capacity, which is 0
+	// by default.
+	"chan": {0, 1, func(e *Env, args []*Value) (*Value, error) {
+		capacity := 0
+		if len(args) == 1 {
+			n, ok := args[0].AsInt()
+			if !ok || n < 0 {
+				return nil, newCondition(typeErrorCondition, "chan argument is not non-negative int: %v", args[0])
+			}
+			if err := checkLength("chan", n); err != nil {
+				return nil, err
+			}
+			if err := e.allocate(n, 0); err != nil {
+				return nil, err
+			}
+			capacity = n
+		}
+		return NewChannel(make(chan *Value, capacity)), nil
+	}},
+	// (send ch value) sends value to ch, waiting until it is received or
+	// buffered, and returns value.
+	"send": {2, 2, func(e *Env, args []*Value) (*Value, error) {
+		ch, err := asChannel("send", args[0])
+		if err != nil {
+			return nil, err
+		}
+		_, _, _, err = e.block([]reflect.SelectCase{{Dir: reflect.SelectSend, Chan: reflect.ValueOf(ch), Send: reflect.ValueOf(args[1])}})
+		if err != nil {
+			return nil, err
+		}
+		return args[1], nil
+	}},
+	// (recv ch) waits for a value from ch and returns it. It returns nil if ch
+	// is closed.
+	"recv": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		ch, err := asChannel("recv", args[0])
+		if err != nil {
+			return nil, err
+		}
+		_, recv, ok, err := e.block([]reflect.SelectCase{{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)}})
+		if err != nil {
+			return nil, err
+		}
+		return received(recv, ok), nil
+	}},
+	// (chan-close ch) closes ch, so that receivers get nil once the buffered
+	// values are received.
+	"chan-close": {1, 1, func(e *Env, args []*Value) (result *Value, err error) {
+		ch, err := asChannel("chan-close", args[0])
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				result, err = nil, newCondition(errorCondition, "close of closed channel")
+			}
+		}()
+		close(ch)
+		return Nil, nil
+	}},
+}
+
+// selectAST waits until one of the clauses can communicate, and then
+// evaluates its body. It is (select clause ...) where each clause is one of
+//
+//	(recv ch (var [ok]) body ...)
+//	(send ch value body ...)
+//	(default body ...)
+//
+// A recv clause binds var to the value received, or nil if ch is closed, and
+// ok to whether a value was received. The value of select is that of the
+// body, or the value received or sent if the body is empty. The default
+// clause is chosen if no other clause can communicate immediately.
+type selectAST struct {
+	astNode
+	clauses []*selectClause
+	// defaultASTs is the body of the default clause, or nil if there is none.
+	defaultASTs []ast
+	hasDefault  bool
+}
+
+func (a *selectAST) dumpFields() []dumpField {
+	return []dumpField{
+		{"clauses", a.clauses},
+		{"defaultASTs", a.defaultASTs},
+		{"hasDefault", a.hasDefault},
+	}
+}
+
+type selectClause struct {
+	send              bool
+	chanAST, valueAST ast
+	// frame is the layout of the environment of the body of a recv clause.
+	frame *frame
+	// varPos are the positions of the symbols of frame in the source.
+	varPos   []sexpressions.Pos
+	bodyASTs []ast
+}
+
+func (c *selectClause) dumpFields() []dumpField {
+	return []dumpField{
+		{"send", c.send},
+		{"chanAST", c.chanAST},
+		{"valueAST", c.valueAST},
+		{"frame", c.frame},
+		{"varPos", c.varPos},
+		{"bodyASTs", c.bodyASTs},
+	}
+}
+
+func (a *selectAST) Eval(e *Env) (*Value, error) {
+	cases := make([]reflect.SelectCase, len(a.clauses), len(a.clauses)+3)
+	values := make([]*Value, len(a.clauses))
+	for i, clause := range a.clauses {
+		chValue, err := eval(e, clause.chanAST)
+		if err != nil {
+			return nil, err
+		}
+		ch, err := asChannel("select", chValue)
+		if err != nil {
+			return nil, err
+		}
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)}
+		if clause.send {
+			values[i], err = eval(e, clause.valueAST)
+			if err != nil {
+				return nil, err
+			}
+			cases[i].Dir, cases[i].Send = reflect.SelectSend, reflect.ValueOf(values[i])
+		}
+	}
+	if a.hasDefault {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+	}
+	chosen, recv, ok, err := e.block(cases)
+	if err != nil {
+		return nil, err
+	}
+	if chosen == len(a.clauses) {
+		return evalSequence(e, a.defaultASTs)
+	}
+	clause := a.clauses[chosen]
+	if clause.send {
+		if len(clause.bodyASTs) == 0 {
+			return values[chosen], nil
+		}
+		return evalSequence(e, clause.bodyASTs)
+	}
+	value := received(recv, ok)
+	env := newFrameEnv(e, clause.frame)
+	if len(env.slots) > 0 {
+		env.slots[0] = value
+	}
+	if len(env.slots) > 1 {
+		env.slots[1] = False
+		if ok {
+			env.slots[1] = True
+		}
+	}
+	if len(clause.bodyASTs) == 0 {
+		return value, nil
+	}
+	return evalSequence(env, clause.bodyASTs)
+}
+
+// makeSelectAST makes AST for (select clause ...).
+func makeSelectAST(sexps []*sexpressions.SExp, sc *scope) (ast, error) {
+	if len(sexps) == 1 {
+		// A select without clauses would wait forever.
+		return nil, fmt.Errorf("select requires at least 1 clause")
+	}
+	a := &selectAST{}
+	for _, sexp := range sexps[1:] {
+		list, ok := sexp.AsList()
+		if !ok || len(list) == 0 {
+			return nil, fmt.Errorf("select clause must be (recv ...), (send ...) or (default ...): %v", sexp)
+		}
+		kind, _ := list[0].AsSymbol()
+		switch kind {
+		case "default":
+			if a.hasDefault {
+				return nil, fmt.Errorf("select has more than 1 default clause: %+v", sexps)
+			}
+			bodyASTs, err := makeASTs(list[1:], sc)
+			if err != nil {
+				return nil, err
+			}
+			a.defaultASTs, a.hasDefault = bodyASTs, true
+		case "send":
+			if len(list) < 3 {
+				return nil, fmt.Errorf("send clause requires a channel and a value: %v", sexp)
+			}
+			clause := &selectClause{send: true}
+			var err error
+			if clause.chanAST, err = makeAST(list[1], sc); err != nil {
+				return nil, err
+			}
+			if clause.valueAST, err = makeAST(list[2], sc); err != nil {
+				return nil, err
+			}
+			if clause.bodyASTs, err = makeASTs(list[3:], sc); err != nil {
+				return nil, err
+			}
+			a.clauses = append(a.clauses, clause)
+		case "recv":
+			if len(list) < 3 {
+				return nil, fmt.Errorf("recv clause requires a channel and a list of 1 or 2 symbols: %v", sexp)
+			}
+			vars, ok := list[2].AsList()
+			if !ok || len(vars) == 0 || len(vars) > 2 {
+				return nil, fmt.Errorf("recv clause requires a channel and a list of 1 or 2 symbols: %v", sexp)
+			}
+			clause := &selectClause{frame: &frame{}}
+			for _, v := range vars {
+				symbol, ok := v.AsSymbolObject()
+				if !ok {
+					return nil, fmt.Errorf("recv clause requires a channel and a list of 1 or 2 symbols: %v", sexp)
+				}
+				clause.frame.symbols = append(clause.frame.symbols, symbol)
+				clause.varPos = append(clause.varPos, v.Pos)
+			}
+			var err error
+			if clause.chanAST, err = makeAST(list[1], sc); err != nil {
+				return nil, err
+			}
+			if clause.bodyASTs, err = makeASTs(list[3:], newScope(clause.frame, sc)); err != nil {
+				return nil, err
+			}
+			a.clauses = append(a.clauses, clause)
+		default:
+			return nil, fmt.Errorf("select clause must be (recv ...), (send ...) or (default ...): %v", sexp)
+		}
+	}
+	return a, nil
+}
+
+// mutex is a lock made by make-mutex. It holds a value in ch while locked,
+// so that waiting for it can be interrupted like channel operations.
+type mutex struct {
+	ch chan struct{}
+}
+
+func (m *mutex) lock(e *Env) error {
+	_, _, _, err := e.block([]reflect.SelectCase{{Dir: reflect.SelectSend, Chan: reflect.ValueOf(m.ch), Send: reflect.ValueOf(struct{}{})}})
+	return err
+}
+
+func (m *mutex) unlock() {
+	<-m.ch
+}
+
+// withLockAST evaluates body while holding a mutex. It is
+// (with-lock mutex body ...), and the mutex is unlocked when body returns or
+// fails.
+type withLockAST struct {
+	astNode
+	mutexAST ast
+	bodyASTs []ast
+}
+
+func (a *withLockAST) dumpFields() []dumpField {
+	return []dumpField{{"mutexAST", a.mutexAST}, {"bodyASTs", a.bodyASTs}}
+}
+
+func (a *withLockAST) Eval(e *Env) (*Value, error) {
+	value, err := eval(e, a.mutexAST)
+	if err != nil {
+		return nil, err
+	}
+	if value.valueType != Mutex {
+		return nil, newCondition(typeErrorCondition, "with-lock argument is not mutex: %v", value)
+	}
+	m := value.value.(*mutex)
+	if err := m.lock(e); err != nil {
+		return nil, err
+	}
+	defer m.unlock()
+	return evalSequence(e, a.bodyASTs)
+}
+
+// makeWithLockAST makes AST for (with-lock mutex body ...).
+func makeWithLockAST(sexps []*sexpressions.SExp, sc *scope) (ast, error) {
+	if len(sexps) < 2 {
+		return nil, fmt.Errorf("with-lock requires at least 1 arg: %+v", sexps)
+	}
+	mutexAST, err := makeAST(sexps[1], sc)
+	if err != nil {
+		return nil, err
+	}
+	bodyASTs, err := makeASTs(sexps[2:], sc)
+	if err != nil {
+		return nil, err
+	}
+	return &withLockAST{mutexAST: mutexAST, bodyASTs: bodyASTs}, nil
+}
+
+func asAtomic(name string, v *Value) (*int64, error) {
+	if v.valueType != Atomic {
+		return nil, newCondition(typeErrorCondition, "%v argument is not atomic: %v", name, v)
+	}
+	return v.value.(*int64), nil
+}
+
+// atomicArgs checks that args are an atomic followed by n ints, and returns
+// them.
+func atomicArgs(name string, args []*Value, n int) (*int64, []int64, error) {
+	a, err := asAtomic(name, args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	ints := make([]int64, n)
+	for i := range ints {
+		v, ok := args[i+1].AsInt()
+		if !ok {
+			return nil, nil, newCondition(typeErrorCondition, "%v argument[%v] is not int: %v", name, i+1, args[i+1])
+		}
+		ints[i] = int64(v)
+	}
+	return a, ints, nil
+}
+
+// syncPrimitives are primitives that make shared state safe to use from
+// tasks started by spawn.
+var syncPrimitives = map[string]builtin{
+	// (make-mutex) returns an unlocked mutex for with-lock.
+	"make-mutex": {0, 0, func(e *Env, args []*Value) (*Value, error) {
+		return &Value{valueType: Mutex, value: &mutex{ch: make(chan struct{}, 1)}}, nil
+	}},
+	// (make-atomic [n]) returns an integer counter that can be updated from
+	// multiple tasks at once. Its initial value is n, or 0 by default.
+	"make-atomic": {0, 1, func(e *Env, args []*Value) (*Value, error) {
+		n := new(int64)
+		if len(args) == 1 {
+			i, ok := args[0].AsInt()
+			if !ok {
+				return nil, newCondition(typeErrorCondition, "make-atomic argument is not int: %v", args[0])
+			}
+			*n = int64(i)
+		}
+		return &Value{valueType: Atomic, value: n}, nil
+	}},
+	// (atomic-get a) returns the value of a.
+	"atomic-get": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		a, _, err := atomicArgs("atomic-get", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return newIntValue(int(atomic.LoadInt64(a))), nil
+	}},
+	// (atomic-set! a n) sets a to n and returns n.
+	"atomic-set!": {2, 2, func(e *Env, args []*Value) (*Value, error) {
+		a, ints, err := atomicArgs("atomic-set!", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		atomic.StoreInt64(a, ints[0])
+		return args[1], nil
+	}},
+	// (atomic-add! a n) adds n to a and returns the new value.
+	"atomic-add!": {2, 2, func(e *Env, args []*Value) (*Value, error) {
+		a, ints, err := atomicArgs("atomic-add!", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return newIntValue(int(atomic.AddInt64(a, ints[0]))), nil
+	}},
+	// (atomic-cas! a old new) sets a to new if it is old, and returns whether
+	// it did.
+	"atomic-cas!": {3, 3, func(e *Env, args []*Value) (*Value, error) {
+		a, ints, err := atomicArgs("atomic-cas!", args, 2)
+		if err != nil {
+			return nil, err
+		}
+		if atomic.CompareAndSwapInt64(a, ints[0], ints[1]) {
+			return True, nil
+		}
+		return False, nil
+	}},
+}
@@ -0,0 +1,92 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSpawn(t *testing.T) {
+	tests := []evalTest{
+		{src: "(join (spawn (lambda () (add 1 2))))", want: "3"},
+		{src: "(set x 1) (join (spawn (lambda () (set x 2) x))) x", want: "1"},
+		{src: "(let ((t (spawn (lambda () 1)))) (join t) (task-done? t))", want: "#t"},
+		{src: "(join (spawn (lambda () (car 1))))", condition: typeErrorCondition},
+		{src: "(spawn 1)", condition: typeErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
+
+func TestChan(t *testing.T) {
+	tests := []evalTest{
+		{src: "(let ((ch (chan 1))) (send ch 1) (recv ch))", want: "1"},
+		{src: "(chan -1)", condition: typeErrorCondition},
+		{src: "(chan 100000000000000)", condition: rangeErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
+
+func TestSelect(t *testing.T) {
+	tests := []evalTest{
+		{src: "(select (default 1))", want: "1"},
+		{src: "(let ((ch (chan 1))) (send ch 2) (select (recv ch (x) (add x 1))))", want: "3"},
+		{src: "(select)", condition: syntaxErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
+
+func TestRecvCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := NewEnv().EvalStringContext(ctx, "(recv (chan))")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestWithLock(t *testing.T) {
+	// The tasks increment a plain variable under the mutex and an atomic
+	// without it, so that lost updates show up in either.
+	src := `
+(set m (make-mutex))
+(set a (make-atomic))
+(set n 0)
+(set work (lambda () (dotimes (i 200) (with-lock m (set! n (add n 1))) (atomic-add! a 1))))
+(set tasks (list (spawn work) (spawn work) (spawn work)))
+(dolist (task tasks) (join task))
+(list n (atomic-get a))`
+	got, err := NewEnv().EvalString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != "(600 600)" {
+		t.Errorf("got %v, want (600 600)", got)
+	}
+}
+
+// TestSharedMutableValues checks that tasks can update a map and a vector
+// they share. Concurrent writes to a Go map crash the process, and go test
+// -race reports unsynchronized ones.
+func TestSharedMutableValues(t *testing.T) {
+	src := `
+(define m (make-map))
+(define v (make-vector 10 0))
+(define work (lambda (base)
+  (lambda ()
+    (dotimes (i 1000)
+      (map-set m (add base i) i)
+      (map-get m i)
+      (vector-set! v (mod i 10) (add (vector-ref v (mod i 10)) 1))
+      (vector-length v)))))
+(define tasks (list (spawn (work 0)) (spawn (work 1000000)) (spawn (work 2000000))))
+(dolist (task tasks) (join task))
+(length (map-keys m))`
+	got, err := NewEnv().EvalString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != "3000" {
+		t.Errorf("got %v keys, want 3000", got)
+	}
+}
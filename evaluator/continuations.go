@@ -0,0 +1,57 @@
+package evaluator
+
+import (
+	"errors"
+)
+
+// continuation is an escape-only continuation captured by call/cc. It can
+// only be invoked while the call/cc that created it is still running, which
+// is enough for non-local exits such as early return from loops. Invoking it
+// unwinds the evaluation up to the call/cc by returning continuationInvoked
+// as an error, which try doesn't catch but unwind-protect sees.
+type continuation struct {
+	active bool
+}
+
+// continuationInvoked is the error that carries the value passed to a
+// continuation up to its call/cc.
+type continuationInvoked struct {
+	k     *continuation
+	value *Value
+}
+
+func (c *continuationInvoked) Error() string {
+	return "continuation invoked outside its call/cc"
+}
+
+func (k *continuation) invoke(args []*Value) (*Value, error) {
+	if !k.active {
+		return nil, newCondition(errorCondition, "continuation invoked after its call/cc returned (only escaping continuations are supported)")
+	}
+	return nil, &continuationInvoked{k: k, value: newMultipleValues(args)}
+}
+
+// isContinuationInvoked reports whether err is unwinding to a call/cc.
+func isContinuationInvoked(err error) bool {
+	var invoked *continuationInvoked
+	return errors.As(err, &invoked)
+}
+
+func callCC(e *Env, args []*Value) (*Value, error) {
+	k := &continuation{active: true}
+	defer func() {
+		k.active = false
+	}()
+	value, err := apply(e, args[0], []*Value{{valueType: Continuation, value: k}})
+	var invoked *continuationInvoked
+	if errors.As(err, &invoked) && invoked.k == k {
+		return invoked.value, nil
+	}
+	return value, err
+}
+
+// continuationPrimitives are primitives that capture continuations.
+var continuationPrimitives = map[string]builtin{
+	"call/cc":                        {1, 1, callCC},
+	"call-with-current-continuation": {1, 1, callCC},
+}
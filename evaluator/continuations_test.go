@@ -0,0 +1,13 @@
+package evaluator
+
+import "testing"
+
+func TestCallCC(t *testing.T) {
+	tests := []evalTest{
+		{src: "(call/cc (lambda (k) (add 1 (k 42))))", want: "42"},
+		{src: "(call/cc (lambda (k) 5))", want: "5"},
+		{src: "(call-with-current-continuation (lambda (k) (dolist (x '(1 2 3)) (when (= x 2) (k x))) 0))", want: "2"},
+		{src: "(call/cc 1)", condition: typeErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
@@ -0,0 +1,139 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// doAST is (do ((var init [step]) ...) (test result ...) body ...), which
+// binds each var to the value of its init, and then repeatedly evaluates
+// body and rebinds each var to the value of its step until test is true.
+// The value is that of the last result, or nil if there are none. Unlike
+// recursive loops, iterations don't use up the evaluation depth.
+type doAST struct {
+	astNode
+	// frame is the layout of the vars in the environment of each iteration.
+	frame    *frame
+	varPos   []sexpressions.Pos
+	initASTs []ast
+	// stepASTs are nil for the vars that keep their values.
+	stepASTs   []ast
+	testAST    ast
+	resultASTs []ast
+	bodyASTs   []ast
+}
+
+func (a *doAST) dumpFields() []dumpField {
+	return []dumpField{
+		{"frame", a.frame},
+		{"varPos", a.varPos},
+		{"initASTs", a.initASTs},
+		{"stepASTs", a.stepASTs},
+		{"testAST", a.testAST},
+		{"resultASTs", a.resultASTs},
+		{"bodyASTs", a.bodyASTs},
+	}
+}
+
+func (a *doAST) Eval(e *Env) (*Value, error) {
+	values := make([]*Value, len(a.initASTs))
+	for i, initAST := range a.initASTs {
+		value, err := eval(e, initAST)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	for {
+		// Each iteration gets its own environment, so that lambdas made in
+		// body keep the values of the iteration.
+		iterationEnv := newFrameEnv(e, a.frame)
+		copy(iterationEnv.slots, values)
+		done, err := eval(iterationEnv, a.testAST)
+		if err != nil {
+			return nil, err
+		}
+		if isTrue(done) {
+			return evalSequence(iterationEnv, a.resultASTs)
+		}
+		if _, err := evalSequence(iterationEnv, a.bodyASTs); err != nil {
+			return nil, err
+		}
+		values = make([]*Value, len(a.stepASTs))
+		for i, stepAST := range a.stepASTs {
+			if stepAST == nil {
+				values[i] = iterationEnv.slots[i]
+				continue
+			}
+			value, err := eval(iterationEnv, stepAST)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+	}
+}
+
+// makeDoAST makes AST for (do ((var init [step]) ...) (test result ...) body ...).
+func makeDoAST(sexps []*sexpressions.SExp, sc *scope) (ast, error) {
+	if len(sexps) < 3 {
+		return nil, fmt.Errorf("do requires bindings and a termination clause: %+v", sexps)
+	}
+	bindings, ok := sexps[1].AsList()
+	if !ok {
+		return nil, fmt.Errorf("do bindings must be a list: %v", sexps[1])
+	}
+	f := &frame{}
+	a := &doAST{frame: f}
+	var steps []*sexpressions.SExp
+	for _, binding := range bindings {
+		list, ok := binding.AsList()
+		if !ok || len(list) < 2 || len(list) > 3 {
+			return nil, fmt.Errorf("do binding must be (var init [step]): %v", binding)
+		}
+		symbol, ok := list[0].AsSymbolObject()
+		if !ok {
+			return nil, fmt.Errorf("do binding must be (var init [step]): %v", binding)
+		}
+		initAST, err := makeAST(list[1], sc)
+		if err != nil {
+			return nil, err
+		}
+		f.symbols = append(f.symbols, symbol)
+		a.varPos = append(a.varPos, list[0].Pos)
+		a.initASTs = append(a.initASTs, initAST)
+		var step *sexpressions.SExp
+		if len(list) == 3 {
+			step = list[2]
+		}
+		steps = append(steps, step)
+	}
+
+	bodyScope := newScope(f, sc)
+	for _, step := range steps {
+		var stepAST ast
+		if step != nil {
+			var err error
+			if stepAST, err = makeAST(step, bodyScope); err != nil {
+				return nil, err
+			}
+		}
+		a.stepASTs = append(a.stepASTs, stepAST)
+	}
+	clause, ok := sexps[2].AsList()
+	if !ok || len(clause) < 1 {
+		return nil, fmt.Errorf("do termination clause must be (test result ...): %v", sexps[2])
+	}
+	var err error
+	if a.testAST, err = makeAST(clause[0], bodyScope); err != nil {
+		return nil, err
+	}
+	if a.resultASTs, err = makeASTs(clause[1:], bodyScope); err != nil {
+		return nil, err
+	}
+	if a.bodyASTs, err = makeASTs(sexps[3:], bodyScope); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
@@ -0,0 +1,136 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// DumpAST writes the AST compiled from sexp to w as an indented tree, for
+// debugging the evaluator.
+func DumpAST(w io.Writer, sexp *sexpressions.SExp) error {
+	a, err := makeAST(sexp, nil)
+	if err != nil {
+		return err
+	}
+	d := &astDumper{w: w}
+	d.dump(reflect.ValueOf(a), 0)
+	fmt.Fprintln(w)
+	return nil
+}
+
+// dumper is implemented by ASTs and the parts of them that DumpAST writes as
+// trees.
+type dumper interface {
+	// dumpFields returns the fields to write, in order.
+	dumpFields() []dumpField
+}
+
+type dumpField struct {
+	name  string
+	value interface{}
+}
+
+type astDumper struct {
+	w io.Writer
+}
+
+var (
+	stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	dumperType   = reflect.TypeOf((*dumper)(nil)).Elem()
+)
+
+// dump writes v. Dumpers such as AST nodes are written as their type name
+// followed by their fields on separate lines indented by depth+1, and other
+// values are written in one line.
+func (d *astDumper) dump(v reflect.Value, depth int) {
+	if isLeaf(v) {
+		fmt.Fprint(d.w, leafString(v))
+		return
+	}
+	if v.Kind() == reflect.Slice {
+		for i := 0; i < v.Len(); i++ {
+			fmt.Fprintf(d.w, "\n%s- ", indent(depth+1))
+			d.dump(v.Index(i), depth+2)
+		}
+		return
+	}
+	n := v.Interface().(dumper)
+	fmt.Fprint(d.w, reflect.Indirect(reflect.ValueOf(n)).Type().Name())
+	for _, f := range n.dumpFields() {
+		fmt.Fprintf(d.w, "\n%s%s:", indent(depth+1), f.name)
+		field := reflect.ValueOf(f.value)
+		// Non-empty lists of nodes start on the next line.
+		if field.Kind() != reflect.Slice || isLeaf(field) {
+			fmt.Fprint(d.w, " ")
+		}
+		d.dump(field, depth+1)
+	}
+}
+
+// isLeaf reports whether v is written in one line.
+func isLeaf(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Interface:
+		if v.IsNil() {
+			return true
+		}
+		return isLeaf(v.Elem())
+	case reflect.Ptr:
+		if v.IsNil() {
+			return true
+		}
+	case reflect.Slice:
+		return v.Len() == 0 || isLeafType(v.Type().Elem())
+	}
+	return v.Type().Implements(stringerType) || !v.Type().Implements(dumperType)
+}
+
+// isLeafType reports whether values of t are written in one line regardless
+// of their contents.
+func isLeafType(t reflect.Type) bool {
+	if t.Implements(stringerType) {
+		return true
+	}
+	if t.Implements(dumperType) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		return false
+	case reflect.Ptr, reflect.Slice:
+		return isLeafType(t.Elem())
+	}
+	return true
+}
+
+func leafString(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return "nil"
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return "nil"
+		}
+		if s, ok := v.Interface().(fmt.Stringer); ok {
+			return s.String()
+		}
+		return leafString(v.Elem())
+	case reflect.Slice:
+		strs := make([]string, v.Len())
+		for i := range strs {
+			strs[i] = leafString(v.Index(i))
+		}
+		return "[" + strings.Join(strs, " ") + "]"
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func indent(depth int) string {
+	return strings.Repeat("  ", depth)
+}
@@ -0,0 +1,85 @@
+package evaluator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/soishi1/toylisp/parser"
+	"github.com/soishi1/toylisp/tokenizer"
+)
+
+func TestDumpAST(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{
+			src: "(if x 1)",
+			want: `ifAST
+  condAST: lookupAST
+    symbol: x
+    addr: global
+  thenAST: literalAST
+    value: 1
+  elseAST: literalAST
+    value: ()
+`,
+		},
+		{
+			src: "(lambda (x) (f x))",
+			want: `lambdaAST
+  params: lambdaList
+    args: [x]
+    keys: []
+    rest: nil
+  frame: frame
+    symbols: [x]
+    open: false
+    captures: []
+  bodyASTs:
+    - applicationAST
+        funcAST: lookupAST
+          symbol: f
+          addr: global
+        argASTs:
+          - lookupAST
+              symbol: x
+              addr: 0:0
+`,
+		},
+		{
+			src: "(case k ((1 2) 'a) (else 3))",
+			want: `caseAST
+  keyAST: lookupAST
+    symbol: k
+    addr: global
+  clauses: [1->0 2->0]
+  clauseASTs:
+    - 
+        - literalAST
+            value: a
+  elseASTs:
+    - literalAST
+        value: 3
+`,
+		},
+	}
+	for _, tt := range tests {
+		toks, err := tokenizer.Tokenize(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sexps, err := parser.Parse(toks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b strings.Builder
+		if err := DumpAST(&b, sexps[0]); err != nil {
+			t.Errorf("%v: %v", tt.src, err)
+			continue
+		}
+		if b.String() != tt.want {
+			t.Errorf("%v:\ngot:\n%v\nwant:\n%v", tt.src, b.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,431 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// ConditionValue is an error signaled during evaluation, which can be caught
+// by try. Errors raised by the evaluator or by primitives are turned into
+// conditions when caught.
+type ConditionValue struct {
+	// Type is the name of the condition type, such as type-error.
+	Type    string
+	Message string
+	// Data is the list of additional values passed to error. nil is treated
+	// as the empty list.
+	Data *Value
+	// Cause is the Go error the condition was made from, if any, such as one
+	// returned by a function registered with RegisterFunc.
+	Cause error
+}
+
+// Names of the condition types raised by the evaluator and the builtin
+// primitives.
+const (
+	errorCondition           = "error"
+	userErrorCondition       = "user-error"
+	typeErrorCondition       = "type-error"
+	arityErrorCondition      = "arity-error"
+	rangeErrorCondition      = "range-error"
+	unboundVariableCondition = "unbound-variable"
+	syntaxErrorCondition     = "syntax-error"
+	arithmeticErrorCondition = "arithmetic-error"
+	divisionByZeroCondition  = "division-by-zero"
+	stackOverflowCondition   = "stack-overflow"
+)
+
+// builtinConditionParents maps each builtin condition type to its parent.
+// error is the root of the hierarchy.
+var builtinConditionParents = map[string]string{
+	userErrorCondition:       errorCondition,
+	typeErrorCondition:       errorCondition,
+	arityErrorCondition:      errorCondition,
+	rangeErrorCondition:      errorCondition,
+	unboundVariableCondition: errorCondition,
+	syntaxErrorCondition:     errorCondition,
+	arithmeticErrorCondition: errorCondition,
+	divisionByZeroCondition:  arithmeticErrorCondition,
+	stackOverflowCondition:   errorCondition,
+}
+
+func newCondition(conditionType string, format string, args ...interface{}) *ConditionValue {
+	return &ConditionValue{
+		Type:    conditionType,
+		Message: fmt.Sprintf(format, args...),
+		Data:    Nil,
+	}
+}
+
+func (c *ConditionValue) Error() string {
+	if c.Data == nil || c.Data.IsNil() {
+		return c.Message
+	}
+	list, _ := c.Data.AsList()
+	strs := []string{c.Message}
+	for i := range list {
+		strs = append(strs, list[i].String())
+	}
+	return strings.Join(strs, " ")
+}
+
+// Unwrap returns the Go error the condition was made from, so that it can be
+// retrieved with errors.As after the condition passes through Lisp code.
+func (c *ConditionValue) Unwrap() error {
+	return c.Cause
+}
+
+// PositionError is an error annotated with the position of the innermost
+// expression whose evaluation failed.
+type PositionError struct {
+	// File is the path of the file the expression was read from, or empty if
+	// it isn't known.
+	File string
+	Pos  sexpressions.Pos
+	Err  error
+}
+
+func (e *PositionError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("%v: %v", e.Pos, e.Err)
+	}
+	return fmt.Sprintf("%s:%v: %v", e.File, e.Pos, e.Err)
+}
+
+func (e *PositionError) Unwrap() error {
+	return e.Err
+}
+
+// withPosition annotates err with the position of form unless err already
+// has a position. Continuation invocations are passed through as is since
+// they are not failures.
+func withPosition(err error, form *sexpressions.SExp) error {
+	if form == nil || !form.Pos.IsValid() || isContinuationInvoked(err) {
+		return err
+	}
+	var positioned *PositionError
+	if errors.As(err, &positioned) {
+		return err
+	}
+	return &PositionError{Pos: form.Pos, Err: err}
+}
+
+// asCondition turns err into a condition. Errors that don't wrap a condition
+// become conditions of type error caused by err.
+func asCondition(err error) *ConditionValue {
+	var c *ConditionValue
+	if errors.As(err, &c) {
+		return c
+	}
+	c = newCondition(errorCondition, "%v", err)
+	c.Cause = err
+	return c
+}
+
+// primitiveError turns an error returned by a primitive into a condition
+// caused by it, unless it wraps a condition already or stops evaluation, so
+// that the message of the condition is that of the primitive without the
+// positions added while the error propagates.
+func primitiveError(err error) error {
+	var c *ConditionValue
+	if errors.As(err, &c) || isAbort(err) || isContinuationInvoked(err) {
+		return err
+	}
+	return asCondition(err)
+}
+
+// isConditionType reports whether conditionType is ancestor or a descendant
+// of it. Condition types without a known parent are children of error.
+func (e *Env) isConditionType(conditionType, ancestor string) bool {
+	e.interp.conditionsMu.Lock()
+	defer e.interp.conditionsMu.Unlock()
+	for t := conditionType; ; {
+		if t == ancestor {
+			return true
+		}
+		if t == errorCondition {
+			return false
+		}
+		parent, ok := e.interp.conditionParents[t]
+		if !ok {
+			parent = errorCondition
+		}
+		t = parent
+	}
+}
+
+// errorPrimitives are primitives that signal and inspect conditions.
+var errorPrimitives = map[string]builtin{
+	// (error [type] "message" data ...) signals a condition of type, which is
+	// user-error if omitted.
+	"error": {1, Variadic, func(e *Env, args []*Value) (*Value, error) {
+		conditionType := userErrorCondition
+		if symbol, ok := args[0].AsSymbol(); ok {
+			conditionType = symbol
+			args = args[1:]
+		}
+		if len(args) < 1 {
+			return nil, newCondition(arityErrorCondition, "error requires a message")
+		}
+		message, ok := args[0].AsString()
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "error message is not string: %v", args[0])
+		}
+		return nil, &ConditionValue{Type: conditionType, Message: message, Data: newListValue(args[1:])}
+	}},
+	// (define-condition type parent) defines a condition type whose parent is
+	// parent.
+	"define-condition": {2, 2, func(e *Env, args []*Value) (*Value, error) {
+		conditionType, ok := args[0].AsSymbol()
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "define-condition type is not symbol: %v", args[0])
+		}
+		parent, ok := args[1].AsSymbol()
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "define-condition parent is not symbol: %v", args[1])
+		}
+		if conditionType == errorCondition || e.isConditionType(parent, conditionType) {
+			return nil, newCondition(errorCondition, "define-condition would make a cycle: %v %v", conditionType, parent)
+		}
+		e.interp.conditionsMu.Lock()
+		defer e.interp.conditionsMu.Unlock()
+		e.interp.conditionParents[conditionType] = parent
+		return args[0], nil
+	}},
+	"condition-type": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		c, err := asConditionValue("condition-type", args[0])
+		if err != nil {
+			return nil, err
+		}
+		return newSExpValue(sexpressions.NewSymbol(c.Type)), nil
+	}},
+	// (condition-is? c type) reports whether c is of type or its descendant.
+	"condition-is?": {2, 2, func(e *Env, args []*Value) (*Value, error) {
+		c, err := asConditionValue("condition-is?", args[0])
+		if err != nil {
+			return nil, err
+		}
+		conditionType, ok := args[1].AsSymbol()
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "condition-is? type is not symbol: %v", args[1])
+		}
+		if e.isConditionType(c.Type, conditionType) {
+			return True, nil
+		}
+		return False, nil
+	}},
+	"condition-message": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		c, err := asConditionValue("condition-message", args[0])
+		if err != nil {
+			return nil, err
+		}
+		return newStringValue(c.Message), nil
+	}},
+	"condition-data": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		c, err := asConditionValue("condition-data", args[0])
+		if err != nil {
+			return nil, err
+		}
+		if c.Data == nil {
+			return Nil, nil
+		}
+		return c.Data, nil
+	}},
+}
+
+func asConditionValue(name string, v *Value) (*ConditionValue, error) {
+	if v.valueType != Condition {
+		return nil, newCondition(typeErrorCondition, "%v argument is not condition: %v", name, v)
+	}
+	return v.value.(*ConditionValue), nil
+}
+
+// tryAST evaluates body, and if it fails, evaluates the first catch clause
+// that handles the condition. The condition is re-raised if none does.
+type tryAST struct {
+	astNode
+	bodyASTs []ast
+	clauses  []*catchClause
+}
+
+func (a *tryAST) dumpFields() []dumpField {
+	return []dumpField{{"bodyASTs", a.bodyASTs}, {"clauses", a.clauses}}
+}
+
+// catchClause is (catch [type ...] (var) handler ...). It handles conditions
+// of any of the types or their descendants, or all conditions if no type is
+// given.
+type catchClause struct {
+	types  []string
+	symbol *sexpressions.Symbol
+	// pos is the position of symbol in the source.
+	pos sexpressions.Pos
+	// frame is the layout of the environment of the handler, which binds
+	// symbol.
+	frame       *frame
+	handlerASTs []ast
+}
+
+func (c *catchClause) dumpFields() []dumpField {
+	return []dumpField{
+		{"types", c.types},
+		{"symbol", c.symbol},
+		{"pos", c.pos},
+		{"frame", c.frame},
+		{"handlerASTs", c.handlerASTs},
+	}
+}
+
+func (a *tryAST) Eval(e *Env) (*Value, error) {
+	value, err := evalSequence(e, a.bodyASTs)
+	if err == nil || isContinuationInvoked(err) || isAbort(err) {
+		return value, err
+	}
+	c := asCondition(err)
+	for _, clause := range a.clauses {
+		if !clause.handles(e, c) {
+			continue
+		}
+		handlerEnv := newFrameEnv(e, clause.frame)
+		handlerEnv.slots[0] = &Value{
+			valueType: Condition,
+			value:     c,
+		}
+		return evalSequence(handlerEnv, clause.handlerASTs)
+	}
+	return nil, err
+}
+
+func (c *catchClause) handles(e *Env, condition *ConditionValue) bool {
+	if len(c.types) == 0 {
+		return true
+	}
+	for _, t := range c.types {
+		if e.isConditionType(condition.Type, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// makeTryAST makes AST for (try body ... (catch [type ...] (var) handler ...) ...).
+func makeTryAST(sexps []*sexpressions.SExp, sc *scope) (ast, error) {
+	bodyEnd := len(sexps)
+	for bodyEnd > 1 && isCatchClause(sexps[bodyEnd-1]) {
+		bodyEnd--
+	}
+	if bodyEnd == len(sexps) {
+		return nil, fmt.Errorf("try requires at least 1 catch clause: %+v", sexps)
+	}
+	bodyASTs, err := makeASTs(sexps[1:bodyEnd], sc)
+	if err != nil {
+		return nil, err
+	}
+	var clauses []*catchClause
+	for _, sexp := range sexps[bodyEnd:] {
+		clause, err := makeCatchClause(sexp, sc)
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, clause)
+	}
+	return &tryAST{
+		bodyASTs: bodyASTs,
+		clauses:  clauses,
+	}, nil
+}
+
+func isCatchClause(sexp *sexpressions.SExp) bool {
+	list, ok := sexp.AsList()
+	if !ok || len(list) == 0 {
+		return false
+	}
+	symbol, ok := list[0].AsSymbol()
+	return ok && symbol == "catch"
+}
+
+func makeCatchClause(sexp *sexpressions.SExp, sc *scope) (*catchClause, error) {
+	list, _ := sexp.AsList()
+	clause := &catchClause{}
+	i := 1
+	for ; i < len(list); i++ {
+		t, ok := list[i].AsSymbol()
+		if !ok {
+			break
+		}
+		clause.types = append(clause.types, t)
+	}
+	if i >= len(list) {
+		return nil, fmt.Errorf("catch requires a list of 1 symbol: %+v", sexp)
+	}
+	vars, ok := list[i].AsList()
+	if !ok || len(vars) != 1 {
+		return nil, fmt.Errorf("catch requires a list of 1 symbol: %+v", sexp)
+	}
+	symbol, ok := vars[0].AsSymbolObject()
+	if !ok {
+		return nil, fmt.Errorf("catch requires a list of 1 symbol: %+v", sexp)
+	}
+	clause.symbol = symbol
+	clause.pos = vars[0].Pos
+	clause.frame = &frame{symbols: []*sexpressions.Symbol{symbol}}
+	handlerASTs, err := makeASTs(list[i+1:], newScope(clause.frame, sc))
+	if err != nil {
+		return nil, err
+	}
+	clause.handlerASTs = handlerASTs
+	return clause, nil
+}
+
+// unwindProtectAST evaluates the protected form, and then evaluates the
+// cleanup forms whether or not it failed.
+type unwindProtectAST struct {
+	astNode
+	protectedAST ast
+	cleanupASTs  []ast
+}
+
+func (a *unwindProtectAST) dumpFields() []dumpField {
+	return []dumpField{{"protectedAST", a.protectedAST}, {"cleanupASTs", a.cleanupASTs}}
+}
+
+func (a *unwindProtectAST) Eval(e *Env) (*Value, error) {
+	value, err := eval(e, a.protectedAST)
+	if stopsEvaluation(err) {
+		// The cleanup forms would fail right away for the same reason, so
+		// they run within an allowance of their own, and the original error
+		// is returned whether or not they fail.
+		restore := e.setAllowance()
+		evalSequence(e, a.cleanupASTs)
+		restore()
+		return nil, err
+	}
+	// An error from the cleanup forms takes precedence over the result of the
+	// protected form.
+	if _, cleanupErr := evalSequence(e, a.cleanupASTs); cleanupErr != nil {
+		return nil, cleanupErr
+	}
+	return value, err
+}
+
+// makeUnwindProtectAST makes AST for (unwind-protect protected cleanup ...).
+func makeUnwindProtectAST(sexps []*sexpressions.SExp, sc *scope) (ast, error) {
+	if len(sexps) < 2 {
+		return nil, fmt.Errorf("unwind-protect requires at least 1 arg: %+v", sexps)
+	}
+	protectedAST, err := makeAST(sexps[1], sc)
+	if err != nil {
+		return nil, err
+	}
+	cleanupASTs, err := makeASTs(sexps[2:], sc)
+	if err != nil {
+		return nil, err
+	}
+	return &unwindProtectAST{
+		protectedAST: protectedAST,
+		cleanupASTs:  cleanupASTs,
+	}, nil
+}
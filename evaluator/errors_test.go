@@ -0,0 +1,65 @@
+package evaluator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTry(t *testing.T) {
+	tests := []evalTest{
+		{src: "(try (error \"boom\") (catch (c) (condition-message c)))", want: "\"boom\""},
+		{src: "(try (error \"boom\" 1 2) (catch (c) (condition-data c)))", want: "(1 2)"},
+		{src: "(try (car 1) (catch (c) 2))", want: "2"},
+		{src: "(try 1 (catch (c) 2))", want: "1"},
+		{src: "(error \"boom\")", condition: userErrorCondition},
+		{src: "(error 1)", condition: typeErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
+
+func TestConditionTypes(t *testing.T) {
+	tests := []evalTest{
+		{src: "(try (error 'my-error \"boom\" 1 2) (catch my-error (c) (condition-data c)))", want: "(1 2)"},
+		{src: "(try (car 1) (catch error (c) (condition-type c)))", want: "type-error"},
+		{src: "(try (car 1) (catch arity-error (c) 1))", condition: typeErrorCondition},
+		{src: "(try (car 1) (catch arity-error type-error (c) 1))", want: "1"},
+		{src: "(try (div 1 0) (catch arithmetic-error (c) (condition-type c)))", want: "division-by-zero"},
+		{src: "(define-condition 'my-error 'type-error) (try (error 'my-error \"x\") (catch type-error (c) (condition-is? c 'error)))", want: "#t"},
+		{src: "(error 'my-error)", condition: arityErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
+
+func TestUnwindProtect(t *testing.T) {
+	tests := []evalTest{
+		{src: "(unwind-protect 1 (set x 2)) x", want: "2"},
+		{src: "(unwind-protect 1 2)", want: "1"},
+		{src: "(try (unwind-protect (car 1) (set x 3)) (catch (c) x))", want: "3"},
+		{src: "(unwind-protect (car 1) (set x 3))", condition: typeErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
+
+func TestUnwindProtectAfterLimit(t *testing.T) {
+	e := NewEnv()
+	e.SetStepLimit(1000)
+	_, err := e.EvalString("(define x 0) (unwind-protect (let loop () (loop)) (set x (add x 1)))")
+	if !errors.Is(err, ErrFuelExhausted) {
+		t.Fatalf("got %v, want ErrFuelExhausted", err)
+	}
+	e.SetStepLimit(0)
+	if got, err := e.EvalString("x"); err != nil || got.String() != "1" {
+		t.Errorf("x = %v, %v, want 1", got, err)
+	}
+
+	// The cleanup forms can't run forever either.
+	e.SetStepLimit(1000)
+	_, err = e.EvalString("(unwind-protect (let loop () (loop)) (let loop () (loop)))")
+	if !errors.Is(err, ErrFuelExhausted) {
+		t.Fatalf("got %v, want ErrFuelExhausted", err)
+	}
+	_, err = e.EvalString("(define f (lambda () (unwind-protect (let loop () (loop)) (f)))) (f)")
+	if !errors.Is(err, ErrFuelExhausted) {
+		t.Fatalf("got %v, want ErrFuelExhausted", err)
+	}
+}
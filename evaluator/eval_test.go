@@ -0,0 +1,56 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+func TestEval(t *testing.T) {
+	tests := []evalTest{
+		{src: "(eval '(add 1 2))", want: "3"},
+		{src: "(eval (quote (add 1 2)))", want: "3"},
+		{src: "(define x 1) ((lambda (x) (eval 'x)) 42)", want: "1"},
+		{src: "((lambda (eval-test-y) (eval 'eval-test-y)) 42)", condition: unboundVariableCondition},
+		{src: "((lambda () (eval '(define eval-test-z 3)))) eval-test-z", want: "3"},
+		{src: "(eval ''a)", want: "a"},
+		{src: "(eval 1 2)", condition: typeErrorCondition},
+		{src: "(eval)", condition: arityErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
+
+func TestEvalInEnvironment(t *testing.T) {
+	e := NewEnv()
+	other := NewEnv()
+	other.Set("x", NewValue(sexpressions.NewInt(7)))
+	e.Set("other", NewEnvironment(other))
+	got, err := e.EvalString("(eval 'x other)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != "7" {
+		t.Errorf("got %v, want 7", got)
+	}
+}
+
+// TestComments checks that comments are skipped end to end. The tokenizer
+// tests cover their syntax.
+func TestComments(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{src: "; (car 1)\n(add 1 #| 2 #| (car 2) |# |# 3) ; sum", want: "4"},
+	})
+}
+
+func TestKeywords(t *testing.T) {
+	tests := []evalTest{
+		{src: ":foo", want: ":foo"},
+		{src: "(quote :foo)", want: ":foo"},
+		{src: "(eq? :foo :foo)", want: "#t"},
+		{src: "(eq? :foo 'foo)", want: "#f"},
+		{src: "(equal? :a :b)", want: "#f"},
+		{src: "(let ((m (make-map))) (map-set m :k 1) (map-get m :k))", want: "1"},
+		{src: "(define :foo 1)", condition: syntaxErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
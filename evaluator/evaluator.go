@@ -2,9 +2,19 @@
 package evaluator
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/soishi1/toylisp/parser"
 	"github.com/soishi1/toylisp/sexpressions"
+	"github.com/soishi1/toylisp/tokenizer"
 )
 
 var Nil = &Value{
@@ -15,12 +25,30 @@ var Nil = &Value{
 	},
 }
 
+var (
+	True  = newSExpValue(sexpressions.NewBool(true))
+	False = newSExpValue(sexpressions.NewBool(false))
+)
+
 type ValueType int
 
 const (
 	SExp ValueType = iota
 	Lambda
 	Primitive
+	Environment
+	Condition
+	MultipleValues
+	Continuation
+	Parameter
+	Struct
+	Task
+	Channel
+	Mutex
+	Atomic
+	Promise
+	Generator
+	Port
 )
 
 type Value struct {
@@ -30,12 +58,18 @@ type Value struct {
 }
 
 type LambdaValue struct {
-	args []string
-	body []ast
-	env  *Env
+	params *lambdaList
+	// frame is the layout of the environment each call binds the parameters
+	// in.
+	frame *frame
+	body  []ast
+	// env is the environment the lambda is defined in.
+	env *Env
 }
 
-type PrimitiveFunc func(args []*Value) (*Value, error)
+// PrimitiveFunc is a function implemented in Go. e is the environment the
+// function is applied in.
+type PrimitiveFunc func(e *Env, args []*Value) (*Value, error)
 
 func (v *Value) String() string {
 	switch v.valueType {
@@ -43,164 +77,499 @@ func (v *Value) String() string {
 		return v.SExp.String()
 	case Lambda:
 		return "#<lambda>"
+	case Primitive:
+		return "#<primitive>"
+	case Environment:
+		return "#<environment>"
+	case MultipleValues:
+		values := v.value.([]*Value)
+		strs := make([]string, len(values))
+		for i := range values {
+			strs[i] = values[i].String()
+		}
+		return strings.Join(strs, " ")
+	case Continuation:
+		return "#<continuation>"
+	case Parameter:
+		return "#<parameter>"
+	case Struct:
+		return v.value.(*structValue).String()
+	case Task:
+		return "#<task>"
+	case Channel:
+		return "#<channel>"
+	case Mutex:
+		return "#<mutex>"
+	case Promise:
+		return "#<promise>"
+	case Generator:
+		return "#<generator>"
+	case Port:
+		return "#<port>"
+	case Atomic:
+		return fmt.Sprintf("#<atomic %v>", atomic.LoadInt64(v.value.(*int64)))
+	case Condition:
+		c := v.value.(*ConditionValue)
+		return fmt.Sprintf("#<condition %v %v>", c.Type, c.Message)
 	}
 	return ""
 }
 
 type ast interface {
 	Eval(e *Env) (*Value, error)
+	// form returns the s-expression the AST is made from.
+	form() *sexpressions.SExp
+	setForm(sexp *sexpressions.SExp)
+	dumper
+}
+
+// astNode implements the methods common to all ASTs. It is embedded in
+// each AST type.
+type astNode struct {
+	sexp *sexpressions.SExp
+}
+
+func (n *astNode) form() *sexpressions.SExp {
+	return n.sexp
+}
+
+func (n *astNode) setForm(sexp *sexpressions.SExp) {
+	n.sexp = sexp
 }
 
 type literalAST struct {
+	astNode
 	value *Value
 }
 
+func (a *literalAST) dumpFields() []dumpField {
+	return []dumpField{{"value", a.value}}
+}
+
 func (a *literalAST) Eval(e *Env) (*Value, error) {
 	return a.value, nil
 }
 
 type lookupAST struct {
-	symbol string
+	astNode
+	symbol *sexpressions.Symbol
+	// addr is where symbol is bound if it is resolved at compile time.
+	addr address
+}
+
+func (a *lookupAST) dumpFields() []dumpField {
+	return []dumpField{{"symbol", a.symbol}, {"addr", a.addr}}
 }
 
 func (a *lookupAST) Eval(e *Env) (*Value, error) {
-	value, ok := e.Lookup(a.symbol)
+	if a.addr.resolved() {
+		if value, ok := e.lookupAddress(a.symbol, a.addr); ok {
+			return value, nil
+		}
+	}
+	value, ok := e.lookupSymbol(a.symbol)
 	if !ok {
-		return nil, fmt.Errorf("undefined variable %v", a.symbol)
+		return nil, newCondition(unboundVariableCondition, "undefined variable %v", a.symbol)
 	}
 	return value, nil
 }
 
 type ifAST struct {
+	astNode
 	condAST, thenAST, elseAST ast
 }
 
+func (a *ifAST) dumpFields() []dumpField {
+	return []dumpField{{"condAST", a.condAST}, {"thenAST", a.thenAST}, {"elseAST", a.elseAST}}
+}
+
 func (a *ifAST) Eval(e *Env) (*Value, error) {
-	condValue, err := a.condAST.Eval(e)
+	condValue, err := eval(e, a.condAST)
 	if err != nil {
 		return nil, err
 	}
-	if condValue.IsNil() {
-		return a.elseAST.Eval(e)
+	if !isTrue(condValue) {
+		return eval(e, a.elseAST)
 	} else {
-		return a.thenAST.Eval(e)
+		return eval(e, a.thenAST)
+	}
+}
+
+// whenAST is (when cond body ...), which evaluates body if cond is true, or
+// (unless cond body ...), which evaluates it if cond is false. The value is
+// that of the last expression of body, or nil if it isn't evaluated.
+type whenAST struct {
+	astNode
+	// unless is true for unless.
+	unless   bool
+	condAST  ast
+	bodyASTs []ast
+}
+
+func (a *whenAST) dumpFields() []dumpField {
+	return []dumpField{{"unless", a.unless}, {"condAST", a.condAST}, {"bodyASTs", a.bodyASTs}}
+}
+
+func (a *whenAST) Eval(e *Env) (*Value, error) {
+	condValue, err := eval(e, a.condAST)
+	if err != nil {
+		return nil, err
 	}
+	if isTrue(condValue) == a.unless {
+		return Nil, nil
+	}
+	return evalSequence(e, a.bodyASTs)
 }
 
 type setAST struct {
-	symbol   string
+	astNode
+	symbol *sexpressions.Symbol
+	// slot is the slot of symbol in the frame of the environment, or -1 if
+	// the frame doesn't bind it.
+	slot     int
 	valueAST ast
 }
 
+func (a *setAST) dumpFields() []dumpField {
+	return []dumpField{{"symbol", a.symbol}, {"slot", a.slot}, {"valueAST", a.valueAST}}
+}
+
 func (a *setAST) Eval(e *Env) (*Value, error) {
-	value, err := a.valueAST.Eval(e)
+	value, err := eval(e, a.valueAST)
+	if err != nil {
+		return nil, err
+	}
+	if a.slot >= 0 {
+		e.slots[a.slot] = value
+	} else {
+		e.setSymbol(a.symbol, value)
+	}
+	return value, nil
+}
+
+// assignAST is set!, which changes the variable that is visible from the
+// environment instead of defining a new one.
+type assignAST struct {
+	astNode
+	symbol *sexpressions.Symbol
+	// addr is where symbol is bound if it is resolved at compile time.
+	addr     address
+	valueAST ast
+}
+
+func (a *assignAST) dumpFields() []dumpField {
+	return []dumpField{{"symbol", a.symbol}, {"addr", a.addr}, {"valueAST", a.valueAST}}
+}
+
+func (a *assignAST) Eval(e *Env) (*Value, error) {
+	value, err := eval(e, a.valueAST)
 	if err != nil {
 		return nil, err
 	}
-	e.Set(a.symbol, value)
+	if a.addr.resolved() && e.assignAddress(a.symbol, a.addr, value) {
+		return value, nil
+	}
+	if !e.assignSymbol(a.symbol, value) {
+		return nil, newCondition(unboundVariableCondition, "set! of undefined variable %v", a.symbol)
+	}
 	return value, nil
 }
 
 type lambdaAST struct {
-	symbols  []string
+	astNode
+	params *lambdaList
+	// frame is the layout of the parameters in the environment of the body.
+	frame    *frame
 	bodyASTs []ast
 }
 
+func (a *lambdaAST) dumpFields() []dumpField {
+	return []dumpField{{"params", a.params}, {"frame", a.frame}, {"bodyASTs", a.bodyASTs}}
+}
+
 func (a *lambdaAST) Eval(e *Env) (*Value, error) {
 	return &Value{
 		valueType: Lambda,
 		value: &LambdaValue{
-			args: a.symbols,
-			body: a.bodyASTs,
-			env:  newEnvWithParent(e),
+			params: a.params,
+			frame:  a.frame,
+			body:   a.bodyASTs,
+			env:    closureEnv(e, a.frame),
 		},
 	}, nil
 }
 
 type applicationAST struct {
+	astNode
 	funcAST ast
 	argASTs []ast
 }
 
+func (a *applicationAST) dumpFields() []dumpField {
+	return []dumpField{{"funcAST", a.funcAST}, {"argASTs", a.argASTs}}
+}
+
 func (a *applicationAST) Eval(e *Env) (*Value, error) {
-	funcValue, err := a.funcAST.Eval(e)
+	funcValue, args, err := a.evalOperands(e)
 	if err != nil {
 		return nil, err
 	}
+	value, err := apply(e, funcValue, args)
+	if err != nil {
+		return nil, withFrame(err, a.frame(funcValue))
+	}
+	return value, nil
+}
+
+// evalOperands evaluates the function and the arguments of a.
+func (a *applicationAST) evalOperands(e *Env) (funcValue *Value, args []*Value, err error) {
+	funcValue, err = eval(e, a.funcAST)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range a.argASTs {
+		arg, err := eval(e, a.argASTs[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		args = append(args, arg)
+	}
+	return funcValue, args, nil
+}
+
+// frame returns the backtrace frame of a applying funcValue.
+func (a *applicationAST) frame(funcValue *Value) Frame {
+	return Frame{Name: frameName(a.funcAST, funcValue), Pos: a.form().Pos}
+}
+
+// apply calls funcValue with already evaluated args. e is the environment
+// the application happens in.
+func apply(e *Env, funcValue *Value, args []*Value) (*Value, error) {
+	if e != nil {
+		if hooks := e.interp.loadHooks(); hooks != nil {
+			return e.applyWithHooks(hooks, funcValue, args)
+		}
+	}
+	return applyFunc(e, funcValue, args)
+}
+
+func applyFunc(e *Env, funcValue *Value, args []*Value) (*Value, error) {
 	if funcValue.valueType == Lambda {
 		lambda := funcValue.value.(*LambdaValue)
-		return a.EvalLambdaApplication(e, lambda)
+		return applyLambda(e, lambda, args)
 	}
 	if funcValue.valueType == Primitive {
-		primitive := funcValue.value.(PrimitiveFunc)
-		return a.EvalPrimitiveApplication(e, primitive)
+		return funcValue.value.(*primitive).call(e, args)
+	}
+	if funcValue.valueType == Parameter {
+		if len(args) != 0 {
+			return nil, newCondition(arityErrorCondition, "parameter takes no arguments, but got %v", len(args))
+		}
+		return e.parameterValue(funcValue.value.(*parameter)), nil
+	}
+	if funcValue.valueType == Continuation {
+		return funcValue.value.(*continuation).invoke(args)
 	}
-	return nil, fmt.Errorf("Unsupported application function: %+v", funcValue)
+	return nil, newCondition(typeErrorCondition, "Unsupported application function: %+v", funcValue)
 }
 
-func (a *applicationAST) EvalLambdaApplication(e *Env, lambda *LambdaValue) (*Value, error) {
-	if len(lambda.args) != len(a.argASTs) {
-		return nil, fmt.Errorf("%+v requires %v arguments, but got %v", lambda, len(lambda.args), len(a.argASTs))
+// applyLambda binds args in a new environment inside the one lambda is
+// defined in, so that recursive and concurrent calls don't share variables.
+// The output is the one of caller, which may be nil.
+//
+// Calls in tail position of the body replace the call instead of nesting in
+// it, so that loops written as recursion run in constant stack. Other calls
+// nest up to maxCallDepth deep.
+func applyLambda(caller *Env, lambda *LambdaValue, args []*Value) (*Value, error) {
+	depth := 1
+	if caller != nil {
+		depth = caller.depth + 1
 	}
-	applicationEnv := lambda.env
-	for i := range lambda.args {
-		arg, err := a.argASTs[i].Eval(e)
+	if depth > maxCallDepth {
+		return nil, newCondition(stackOverflowCondition, "calls are nested more than %v deep", maxCallDepth)
+	}
+	// tailFrame is the tail call that replaced the original one, which is
+	// the only one of them kept in backtraces.
+	var tailFrame *Frame
+	for {
+		value, call, err := applyLambdaBody(caller, depth, lambda, args)
 		if err != nil {
+			if tailFrame != nil {
+				err = withFrame(err, *tailFrame)
+			}
 			return nil, err
 		}
-		applicationEnv.Set(lambda.args[i], arg)
+		if call == nil {
+			return value, nil
+		}
+		lambda, args, tailFrame = call.lambda, call.args, call.frame
+	}
+}
+
+// applyLambdaBody evaluates the body of lambda with args bound, and returns
+// the call in its tail position, if any, without applying it.
+func applyLambdaBody(caller *Env, depth int, lambda *LambdaValue, args []*Value) (*Value, *tailCall, error) {
+	// The output and the parameters bound by parameterize follow calls, so
+	// they are only taken from the environment lambda is defined in if there
+	// is no caller. That environment may be changing them on another task.
+	from := lambda.env
+	if caller != nil {
+		from = caller
+	}
+	applicationEnv := &Env{
+		frame:   lambda.frame,
+		slots:   make([]*Value, len(lambda.frame.symbols)),
+		parent:  lambda.env,
+		interp:  lambda.env.interp,
+		out:     from.out,
+		depth:   depth,
+		dynamic: from.dynamic,
+	}
+	if err := lambda.params.bind(applicationEnv, args); err != nil {
+		return nil, nil, fmt.Errorf("lambda: %w", err)
+	}
+	return evalSequenceTail(applicationEnv, lambda.body)
+}
+
+// eval evaluates a in e. All ASTs are evaluated through it so that
+// evaluations can be interrupted, counted and hooked.
+func eval(e *Env, a ast) (*Value, error) {
+	if err := e.checkInterrupt(); err != nil {
+		return nil, err
+	}
+	if err := e.consumeStep(); err != nil {
+		return nil, err
 	}
 	var value *Value
-	for i := range lambda.body {
-		var err error
-		value, err = lambda.body[i].Eval(applicationEnv)
-		if err != nil {
-			return nil, err
-		}
+	var err error
+	if hooks := e.interp.loadHooks(); hooks != nil {
+		value, err = e.evalWithHooks(hooks, a)
+	} else {
+		value, err = a.Eval(e)
+	}
+	if err != nil {
+		return nil, withPosition(err, a.form())
 	}
 	return value, nil
 }
 
-func (a *applicationAST) EvalPrimitiveApplication(e *Env, primitive PrimitiveFunc) (*Value, error) {
-	var args []*Value
-	for i := range a.argASTs {
-		arg, err := a.argASTs[i].Eval(e)
+// evalSequence evaluates asts in order and returns the last value, or nil if
+// asts is empty.
+func evalSequence(e *Env, asts []ast) (*Value, error) {
+	value := Nil
+	for i := range asts {
+		var err error
+		value, err = eval(e, asts[i])
 		if err != nil {
 			return nil, err
 		}
-		args = append(args, arg)
 	}
-	return primitive(args)
+	return value, nil
 }
 
 type Env struct {
-	vars   map[string]*Value
+	// mu guards vars and imports, which goroutines started by spawn may
+	// share.
+	mu sync.RWMutex
+	// vars are the variables defined by set, except for the ones in frame.
+	vars map[*sexpressions.Symbol]*Value
+	// frame is the layout of slots if the environment is made for a lambda
+	// or a catch clause, or nil.
+	frame *frame
+	// slots are the values of the variables of frame, which are nil until
+	// bound.
+	slots  []*Value
 	parent *Env
+	// interp is shared by all environments derived from the same NewEnv.
+	interp *interpreter
+	// module is the module whose body is evaluated in this environment, if any.
+	module *module
+	// imports are modules whose exported bindings are visible from this
+	// environment.
+	imports []*module
+	// out is where output primitives called in this environment write to,
+	// such as the string port of with-output-to-string, or nil for the
+	// output of the interpreter. It follows calls rather than the lexical
+	// nesting of environments, so that it is per evaluation and not shared
+	// with tasks spawned outside of it.
+	out io.Writer
+	// depth is the number of lambda calls the environment is nested in,
+	// which follows calls like out.
+	depth int
+	// dynamic are the parameters bound by parameterize, which follow calls
+	// like out.
+	dynamic *dynamicBinding
+}
+
+// makeAST parses a s-expression and turn it into AST. Variables bound in sc
+// are resolved to their addresses, and sc is nil at the top level.
+func makeAST(sexp *sexpressions.SExp, sc *scope) (ast, error) {
+	a, err := makeAST1(sexp, sc)
+	if err != nil {
+		return nil, withPosition(err, sexp)
+	}
+	a.setForm(sexp)
+	return a, nil
 }
 
-// makeAST parses a s-expression and turn it into AST.
-func makeAST(sexp *sexpressions.SExp) (ast, error) {
+func makeAST1(sexp *sexpressions.SExp, sc *scope) (ast, error) {
 	switch sexp.Type {
-	case sexpressions.StringType, sexpressions.IntType:
+	case sexpressions.StringType, sexpressions.IntType, sexpressions.FloatType, sexpressions.BigIntType,
+		sexpressions.BoolType, sexpressions.CharType, sexpressions.KeywordType, sexpressions.VectorType,
+		sexpressions.MapType:
 		return &literalAST{
 			value: &Value{
 				valueType: SExp,
 				SExp:      sexp,
 			},
 		}, nil
+	case sexpressions.ObjectType:
+		return &literalAST{
+			value: newSExpValue(sexp),
+		}, nil
+	case sexpressions.PairType:
+		return nil, fmt.Errorf("failed to evaluate %v (dotted list can't be evaluated)", sexp)
 	case sexpressions.ListType:
 		list, _ := sexp.AsList()
-		return makeASTFromList(list)
+		return makeASTFromList(list, sc)
 	case sexpressions.SymbolType:
-		symbol, _ := sexp.AsSymbol()
+		symbol, _ := sexp.AsSymbolObject()
 		return &lookupAST{
 			symbol: symbol,
+			addr:   sc.resolve(symbol),
 		}, nil
 	}
 	return nil, fmt.Errorf("failed to evaluate %v (unknown sexpression type)", sexp)
 }
 
-func makeASTFromList(sexps []*sexpressions.SExp) (ast, error) {
+// makeASTs makes ASTs of sexps in sc.
+func makeASTs(sexps []*sexpressions.SExp, sc *scope) ([]ast, error) {
+	var asts []ast
+	for i := range sexps {
+		ast, err := makeAST(sexps[i], sc)
+		if err != nil {
+			return nil, err
+		}
+		asts = append(asts, ast)
+	}
+	return asts, nil
+}
+
+// specialForms are the names handled by makeASTFromList. It must be kept in
+// sync with the switch there.
+var specialForms = []string{
+	"if", "set", "define", "set!", "quote", "lambda", "try", "unwind-protect", "parameterize",
+	"defstruct", "module", "export", "import", "select", "with-lock", "delay",
+	"stream-cons", "let", "do", "dotimes", "dolist", "case",
+	"when", "unless",
+}
+
+// SpecialForms returns the names of special forms.
+func SpecialForms() []string {
+	return append([]string(nil), specialForms...)
+}
+
+func makeASTFromList(sexps []*sexpressions.SExp, sc *scope) (ast, error) {
 	if len(sexps) == 0 {
 		return &literalAST{value: Nil}, nil
 	}
@@ -209,38 +578,75 @@ func makeASTFromList(sexps []*sexpressions.SExp) (ast, error) {
 	if symbol, ok := first.AsSymbol(); ok {
 		switch symbol {
 		case "if":
-			return makeIfAST(sexps)
-		case "set":
-			return makeSetAST(sexps)
+			return makeIfAST(sexps, sc)
+		case "set", "define":
+			sc.markDefines()
+			return makeSetAST(sexps, sc)
+		case "set!":
+			return makeAssignAST(sexps, sc)
 		case "quote":
 			return makeQuoteAST(sexps)
 		case "lambda":
-			return makeLambdaAST(sexps)
+			return makeLambdaAST(sexps, sc)
+		case "try":
+			return makeTryAST(sexps, sc)
+		case "unwind-protect":
+			return makeUnwindProtectAST(sexps, sc)
+		case "parameterize":
+			return makeParameterizeAST(sexps, sc)
+		case "defstruct":
+			sc.markDefines()
+			return makeDefstructAST(sexps)
+		case "module":
+			return makeModuleAST(sexps, sc)
+		case "export":
+			return makeExportAST(sexps)
+		case "import":
+			sc.markDefines()
+			return makeImportAST(sexps)
+		case "select":
+			return makeSelectAST(sexps, sc)
+		case "with-lock":
+			return makeWithLockAST(sexps, sc)
+		case "delay":
+			return makeDelayAST(sexps, sc)
+		case "stream-cons":
+			return makeStreamConsAST(sexps, sc)
+		case "let":
+			return makeLetAST(sexps, sc)
+		case "do":
+			return makeDoAST(sexps, sc)
+		case "dotimes", "dolist":
+			return makeIterationAST(sexps, sc)
+		case "case":
+			return makeCaseAST(sexps, sc)
+		case "when", "unless":
+			return makeWhenAST(sexps, sc)
 		}
 	}
-	return makeApplicationAST(sexps)
+	return makeApplicationAST(sexps, sc)
 }
 
-func makeIfAST(sexps []*sexpressions.SExp) (ast, error) {
+func makeIfAST(sexps []*sexpressions.SExp, sc *scope) (ast, error) {
 	if len(sexps) != 3 && len(sexps) != 4 {
 		return nil, fmt.Errorf("if requires 2 or 3 args: %+v", sexps)
 	}
 
-	var elseAST ast = &literalAST{value: Nil}
+	var elseAST ast = &literalAST{astNode: astNode{sexp: Nil.SExp}, value: Nil}
 	if len(sexps) == 4 {
 		var err error
-		elseAST, err = makeAST(sexps[3])
+		elseAST, err = makeAST(sexps[3], sc)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	thenAST, err := makeAST(sexps[2])
+	thenAST, err := makeAST(sexps[2], sc)
 	if err != nil {
 		return nil, err
 	}
 
-	condAST, err := makeAST(sexps[1])
+	condAST, err := makeAST(sexps[1], sc)
 	if err != nil {
 		return nil, err
 	}
@@ -252,27 +658,76 @@ func makeIfAST(sexps []*sexpressions.SExp) (ast, error) {
 	}, nil
 }
 
-func makeSetAST(sexps []*sexpressions.SExp) (ast, error) {
-	if len(sexps) != 3 {
-		return nil, fmt.Errorf("set requires 2 args: %+v", sexps)
+// makeWhenAST makes AST for (when cond body ...) and (unless cond body ...).
+func makeWhenAST(sexps []*sexpressions.SExp, sc *scope) (ast, error) {
+	name, _ := sexps[0].AsSymbol()
+	if len(sexps) < 2 {
+		return nil, fmt.Errorf("%v requires a condition: %+v", name, sexps)
 	}
+	condAST, err := makeAST(sexps[1], sc)
+	if err != nil {
+		return nil, err
+	}
+	bodyASTs, err := makeASTs(sexps[2:], sc)
+	if err != nil {
+		return nil, err
+	}
+	return &whenAST{unless: name == "unless", condAST: condAST, bodyASTs: bodyASTs}, nil
+}
 
-	symbol, ok := sexps[1].AsSymbol()
-	if !ok {
-		return nil, fmt.Errorf("1st argument to set must be a symbol: %+v", sexps)
+// makeSetAST makes AST for (set name value) and (define name value), which
+// define name in the environment they are evaluated in.
+func makeSetAST(sexps []*sexpressions.SExp, sc *scope) (ast, error) {
+	symbol, err := assignedSymbol(sexps)
+	if err != nil {
+		return nil, err
 	}
 
-	valueAST, err := makeAST(sexps[2])
+	valueAST, err := makeAST(sexps[2], sc)
 	if err != nil {
 		return nil, err
 	}
 
+	slot := -1
+	if sc != nil {
+		slot = sc.frame.index(symbol)
+	}
 	return &setAST{
 		symbol:   symbol,
+		slot:     slot,
 		valueAST: valueAST,
 	}, nil
 }
 
+// makeAssignAST makes AST for (set! name value).
+func makeAssignAST(sexps []*sexpressions.SExp, sc *scope) (ast, error) {
+	symbol, err := assignedSymbol(sexps)
+	if err != nil {
+		return nil, err
+	}
+	valueAST, err := makeAST(sexps[2], sc)
+	if err != nil {
+		return nil, err
+	}
+	return &assignAST{
+		symbol:   symbol,
+		addr:     sc.resolve(symbol),
+		valueAST: valueAST,
+	}, nil
+}
+
+// assignedSymbol returns the name in (form name value).
+func assignedSymbol(sexps []*sexpressions.SExp) (*sexpressions.Symbol, error) {
+	if len(sexps) != 3 {
+		return nil, fmt.Errorf("%v requires 2 args: %+v", sexps[0], sexps)
+	}
+	symbol, ok := sexps[1].AsSymbolObject()
+	if !ok {
+		return nil, fmt.Errorf("1st argument to %v must be a symbol: %+v", sexps[0], sexps)
+	}
+	return symbol, nil
+}
+
 func makeQuoteAST(sexps []*sexpressions.SExp) (ast, error) {
 	if len(sexps) != 2 {
 		return nil, fmt.Errorf("quote requires 1 arg: %+v", sexps)
@@ -285,52 +740,43 @@ func makeQuoteAST(sexps []*sexpressions.SExp) (ast, error) {
 	}, nil
 }
 
-func makeLambdaAST(sexps []*sexpressions.SExp) (ast, error) {
+func makeLambdaAST(sexps []*sexpressions.SExp, sc *scope) (ast, error) {
 	if len(sexps) < 3 {
 		return nil, fmt.Errorf("lambda requires at least 2 arguments: %+v", sexps)
 	}
 
-	args, ok := sexps[1].AsList()
-	if !ok {
-		return nil, fmt.Errorf("1st argument to lambda must be a list of symbols: %+v", sexps)
-	}
-	var symbols []string
-	for i := range args {
-		symbol, ok := args[i].AsSymbol()
-		if !ok {
-			return nil, fmt.Errorf("1st argument to lambda must be a list of symbols: %+v", sexps)
-		}
-		symbols = append(symbols, symbol)
+	f := &frame{}
+	bodyScope := newScope(f, sc)
+	params, err := parseLambdaList(sexps[1], bodyScope)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %+v", err, sexps)
 	}
+	f.symbols = params.symbols()
 
-	var bodyASTs []ast
-	for i := 2; i < len(sexps); i++ {
-		ast, err := makeAST(sexps[i])
-		if err != nil {
-			return nil, err
-		}
-		bodyASTs = append(bodyASTs, ast)
+	bodyASTs, err := makeASTs(sexps[2:], bodyScope)
+	if err != nil {
+		return nil, err
 	}
 
 	return &lambdaAST{
-		symbols:  symbols,
+		params:   params,
+		frame:    f,
 		bodyASTs: bodyASTs,
 	}, nil
 }
 
-func makeApplicationAST(sexps []*sexpressions.SExp) (ast, error) {
+func makeApplicationAST(sexps []*sexpressions.SExp, sc *scope) (ast, error) {
 	if len(sexps) == 0 {
 		return nil, fmt.Errorf("function application requires at least 1 argument: %+v", sexps)
 	}
-
-	funcAST, err := makeAST(sexps[0])
+	funcAST, err := makeAST(sexps[0], sc)
 	if err != nil {
 		return nil, err
 	}
 
 	var argASTs []ast
 	for i := 1; i < len(sexps); i++ {
-		argAST, err := makeAST(sexps[i])
+		argAST, err := makeAST(sexps[i], sc)
 		if err != nil {
 			return nil, err
 		}
@@ -344,71 +790,582 @@ func makeApplicationAST(sexps []*sexpressions.SExp) (ast, error) {
 	}, nil
 }
 
-func NewEnv() *Env {
-	return &Env{
-		vars: map[string]*Value{
-			"nil": Nil,
-			"add": makePrimitive(func(args []*Value) (*Value, error) {
-				sum := 0
-				for i := range args {
-					x, ok := args[i].AsInt()
-					if !ok {
-						return nil, fmt.Errorf("add argument[%v] is not int: %v", i, args[i])
-					}
-					sum += x
-				}
-				return &Value{
-					valueType: SExp,
-					SExp: &sexpressions.SExp{
-						Type:  sexpressions.IntType,
-						Value: sum,
-					},
-				}, nil
-			}),
-		},
-		parent: nil,
+// corePrimitives are primitives that don't belong to any specific data type.
+var corePrimitives = map[string]builtin{
+	"gensym": {0, 1, func(e *Env, args []*Value) (*Value, error) {
+		prefix := "G"
+		if len(args) == 1 {
+			var ok bool
+			prefix, ok = args[0].AsString()
+			if !ok {
+				return nil, newCondition(typeErrorCondition, "gensym argument is not string: %v", args[0])
+			}
+		}
+		// Generated symbols are uninterned, so they are never the same as symbols
+		// written in source code. The counter and the "#:" prefix, which can't be
+		// produced by the tokenizer, make them distinguishable when printed.
+		n := atomic.AddInt64(&gensymCounter, 1)
+		return &Value{
+			valueType: SExp,
+			SExp: &sexpressions.SExp{
+				Type:  sexpressions.SymbolType,
+				Value: sexpressions.NewUninternedSymbol(fmt.Sprintf("#:%s%d", prefix, n)),
+			},
+		}, nil
+	}},
+	// (eval sexp [env]) evaluates sexp at the top level, where the forms
+	// around the call are evaluated, or in env, which Go programs make with
+	// NewEnvironment. The variables of the lambdas it is called in aren't
+	// visible to sexp.
+	"eval": {1, 2, func(e *Env, args []*Value) (*Value, error) {
+		if args[0].valueType != SExp {
+			return nil, newCondition(typeErrorCondition, "eval argument[0] is not s-expression: %v", args[0])
+		}
+		if len(args) == 2 {
+			if args[1].valueType != Environment {
+				return nil, newCondition(typeErrorCondition, "eval argument[1] is not environment: %v", args[1])
+			}
+			return args[1].value.(*Env).Eval(args[0].SExp)
+		}
+		return e.topLevel().Eval(args[0].SExp)
+	}},
+	"apply": {2, Variadic, func(e *Env, args []*Value) (*Value, error) {
+		last := args[len(args)-1]
+		if last.valueType != SExp {
+			return nil, newCondition(typeErrorCondition, "apply last argument is not list: %v", last)
+		}
+		list, ok := last.AsList()
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "apply last argument is not list: %v", last)
+		}
+		funcArgs := append([]*Value{}, args[1:len(args)-1]...)
+		for i := range list {
+			funcArgs = append(funcArgs, newSExpValue(list[i]))
+		}
+		return apply(e, args[0], funcArgs)
+	}},
+	"char->int": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		c, ok := args[0].AsChar()
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "char->int argument is not char: %v", args[0])
+		}
+		return newSExpValue(sexpressions.NewInt(int(c))), nil
+	}},
+	"int->char": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		i, ok := args[0].AsInt()
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "int->char argument is not int: %v", args[0])
+		}
+		if i < 0 || i > unicode.MaxRune || !utf8.ValidRune(rune(i)) {
+			return nil, newCondition(rangeErrorCondition, "int->char argument is not a valid code point: %v", i)
+		}
+		return newSExpValue(sexpressions.NewChar(rune(i))), nil
+	}},
+	// (read str) parses str and returns the first s-expression in it.
+	"read": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		str, ok := args[0].AsString()
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "read argument is not string: %v", args[0])
+		}
+		tokens, err := tokenizer.Tokenize(str)
+		if err != nil {
+			return nil, newCondition(syntaxErrorCondition, "read: %v", err)
+		}
+		sexps, err := parser.Parse(tokens)
+		if err != nil {
+			return nil, newCondition(syntaxErrorCondition, "read: %v", err)
+		}
+		if len(sexps) == 0 {
+			return nil, newCondition(syntaxErrorCondition, "read: no s-expression in %v", args[0])
+		}
+		return newSExpValue(sexps[0]), nil
+	}},
+	// (values x ...) returns the arguments as multiple values. A single value
+	// is returned as is.
+	"values": {0, Variadic, func(e *Env, args []*Value) (*Value, error) {
+		return newMultipleValues(args), nil
+	}},
+	// (call-with-values producer consumer) calls producer with no arguments
+	// and then calls consumer with the values it returned as arguments.
+	"call-with-values": {2, 2, func(e *Env, args []*Value) (*Value, error) {
+		produced, err := apply(e, args[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		values := []*Value{produced}
+		if produced.valueType == MultipleValues {
+			values = produced.value.([]*Value)
+		}
+		return apply(e, args[1], values)
+	}},
+	// (eq? a b) reports whether a and b are the same object. Numbers,
+	// characters, booleans, symbols, and keywords with the same value are the
+	// same object.
+	"eq?": {2, 2, func(e *Env, args []*Value) (*Value, error) {
+		if isEq(args[0], args[1]) {
+			return True, nil
+		}
+		return False, nil
+	}},
+	// (version) returns the version of the interpreter as a string.
+	"version": {0, 0, func(e *Env, args []*Value) (*Value, error) {
+		return newStringValue(Version), nil
+	}},
+	"equal?": {2, 2, func(e *Env, args []*Value) (*Value, error) {
+		if toSExp(args[0]).Equal(toSExp(args[1])) {
+			return True, nil
+		}
+		return False, nil
+	}},
+}
+
+// newMultipleValues returns values as multiple values, or the value itself if
+// there is only 1.
+func newMultipleValues(values []*Value) *Value {
+	if len(values) == 1 {
+		return values[0]
+	}
+	return &Value{
+		valueType: MultipleValues,
+		value:     append([]*Value{}, values...),
+	}
+}
+
+func isEq(a, b *Value) bool {
+	if a.valueType != SExp || b.valueType != SExp {
+		return a == b
+	}
+	if a.SExp == b.SExp || (a.IsNil() && b.IsNil()) {
+		return true
+	}
+	switch a.Type {
+	case sexpressions.SymbolType, sexpressions.KeywordType, sexpressions.IntType, sexpressions.FloatType,
+		sexpressions.BigIntType, sexpressions.BoolType, sexpressions.CharType, sexpressions.ObjectType:
+		return a.Equal(b.SExp)
+	}
+	return false
+}
+
+// gensymCounter is the number of symbols generated by gensym so far.
+var gensymCounter int64
+
+// builtin is a builtin primitive with the minimum and maximum numbers of
+// arguments it takes, which are checked before fn is called as for
+// primitives registered by RegisterPrimitive.
+type builtin struct {
+	minArgs, maxArgs int
+	fn               PrimitiveFunc
+}
+
+// primitiveGroup is a set of builtin primitives that require capability to be
+// defined, or none if it's empty.
+type primitiveGroup struct {
+	capability Capability
+	primitives map[string]builtin
+	// pure is true if the primitives have no side effects and their results
+	// depend only on their arguments, so that constant folding may call them
+	// at compile time.
+	pure bool
+}
+
+// builtinPrimitives are the groups of primitives defined by NewEnv.
+var builtinPrimitives = []primitiveGroup{
+	{primitives: corePrimitives},
+	{primitives: numberPrimitives, pure: true},
+	{primitives: randomPrimitives},
+	{primitives: formatPrimitives},
+	{capability: CapIO, primitives: ioPrimitives},
+	{primitives: errorPrimitives},
+	{primitives: continuationPrimitives},
+	{primitives: parameterPrimitives},
+	{capability: CapIO, primitives: loadPrimitives},
+	{primitives: listPrimitives},
+	{primitives: mapPrimitives},
+	{primitives: vectorPrimitives},
+	{primitives: jsonPrimitives},
+	{primitives: concurrencyPrimitives},
+	{primitives: channelPrimitives},
+	{primitives: syncPrimitives},
+	{primitives: promisePrimitives},
+	{primitives: generatorPrimitives},
+	{capability: CapIO, primitives: portPrimitives},
+	{capability: CapIO, primitives: filePrimitives},
+	{capability: CapOS, primitives: osPrimitives},
+	{capability: CapOS, primitives: execPrimitives},
+	{capability: CapTime, primitives: timePrimitives},
+}
+
+// NewEnv returns a top-level environment with the builtin primitives and the
+// prelude.
+func NewEnv(opts ...Option) *Env {
+	o := &envOptions{prelude: true}
+	for _, opt := range opts {
+		opt(o)
+	}
+	e := newEnvWithParent(nil)
+	e.Set("nil", Nil)
+	for _, group := range builtinPrimitives {
+		if !o.allows(group.capability) {
+			continue
+		}
+		for name, b := range group.primitives {
+			v := makePrimitive(e, name, b.minArgs, b.maxArgs, b.fn)
+			v.value.(*primitive).pure = group.pure
+			e.Set(name, v)
+		}
+	}
+	e.interp.foldConstants = o.foldConstants
+	if o.prelude {
+		// The prelude is part of the evaluator, and TestPrelude checks that
+		// it loads with every option, so failing is a bug of the evaluator.
+		if err := e.loadPrelude(); err != nil {
+			panic(fmt.Sprintf("failed to load prelude: %v", err))
+		}
+	}
+	return e
+}
+
+// NewEnvironment wraps e into a Value, so that Go programs can pass it to
+// eval to evaluate s-expressions in e.
+func NewEnvironment(e *Env) *Value {
+	return &Value{valueType: Environment, value: e}
+}
+
+// NewValue wraps sexp into a Value, so that Go programs can bind s-expressions
+// with Env.Set.
+func NewValue(sexp *sexpressions.SExp) *Value {
+	return newSExpValue(sexp)
+}
+
+// newSExpValue wraps sexp into a Value. It is the inverse of toSExp.
+func newSExpValue(sexp *sexpressions.SExp) *Value {
+	if sexp.Type == sexpressions.ObjectType {
+		return sexp.Value.(*Value)
+	}
+	return &Value{
+		valueType: SExp,
+		SExp:      sexp,
+	}
+}
+
+// toSExp returns the s-expression representation of v so that it can be
+// stored in lists. Values that are not s-expressions are boxed as ObjectType.
+func toSExp(v *Value) *sexpressions.SExp {
+	if v.valueType == SExp {
+		return v.SExp
+	}
+	return &sexpressions.SExp{
+		Type:  sexpressions.ObjectType,
+		Value: v,
+	}
+}
+
+// newListValue returns a list Value containing values.
+func newListValue(values []*Value) *Value {
+	if len(values) == 0 {
+		return Nil
+	}
+	list := make([]*sexpressions.SExp, len(values))
+	for i := range values {
+		list[i] = toSExp(values[i])
+	}
+	return newSExpValue(sexpressions.NewList(list...))
+}
+
+// isTrue reports whether v counts as true in conditionals. Only #f and nil
+// are false.
+func isTrue(v *Value) bool {
+	if v.valueType != SExp {
+		return true
+	}
+	if b, ok := v.AsBool(); ok {
+		return b
 	}
+	return !v.IsNil()
+}
+
+// Variadic as the maximum number of arguments of a primitive means it takes
+// any number of arguments.
+const Variadic = -1
+
+// primitive is a function implemented in Go, whose number of arguments is
+// checked before calling fn.
+type primitive struct {
+	name             string
+	minArgs, maxArgs int
+	fn               PrimitiveFunc
+	// env is where the primitive is registered, and is used when it's called
+	// from Go by Value.Call.
+	env *Env
+	// pure is true for builtin primitives that constant folding may call.
+	pure bool
 }
 
-func makePrimitive(p PrimitiveFunc) *Value {
+func (p *primitive) call(e *Env, args []*Value) (*Value, error) {
+	n := len(args)
+	switch {
+	case p.minArgs == p.maxArgs && n != p.minArgs:
+		return nil, newCondition(arityErrorCondition, "%v requires %v arguments, but got %v", p.name, p.minArgs, n)
+	case p.maxArgs == Variadic && n < p.minArgs:
+		return nil, newCondition(arityErrorCondition, "%v requires at least %v arguments, but got %v", p.name, p.minArgs, n)
+	case p.maxArgs != Variadic && (n < p.minArgs || n > p.maxArgs):
+		return nil, newCondition(arityErrorCondition, "%v requires %v to %v arguments, but got %v", p.name, p.minArgs, p.maxArgs, n)
+	}
+	value, err := p.fn(e, args)
+	if err != nil {
+		return nil, primitiveError(err)
+	}
+	return value, nil
+}
+
+// makePrimitive returns a primitive that takes minArgs to maxArgs arguments.
+// maxArgs may be Variadic.
+func makePrimitive(e *Env, name string, minArgs, maxArgs int, fn PrimitiveFunc) *Value {
 	return &Value{
 		valueType: Primitive,
-		value:     p,
+		value:     &primitive{name: name, minArgs: minArgs, maxArgs: maxArgs, fn: fn, env: e},
 	}
 }
 
+// RegisterPrimitive binds name in e to fn, which is a primitive that takes
+// minArgs to maxArgs arguments. maxArgs may be Variadic. Calls with a wrong
+// number of arguments fail with an arity-error condition without calling fn.
+func (e *Env) RegisterPrimitive(name string, minArgs, maxArgs int, fn PrimitiveFunc) {
+	e.Set(name, makePrimitive(e, name, minArgs, maxArgs, fn))
+}
+
 func newEnvWithParent(parent *Env) *Env {
-	return &Env{
-		vars:   make(map[string]*Value),
+	e := &Env{
+		vars:   make(map[*sexpressions.Symbol]*Value),
 		parent: parent,
 	}
+	if parent != nil {
+		e.interp = parent.interp
+		e.out = parent.out
+		e.depth = parent.depth
+		e.dynamic = parent.dynamic
+	} else {
+		e.interp = newInterpreter()
+	}
+	return e
+}
+
+// newFrameEnv returns an environment with empty slots for the variables of f.
+// Its vars are allocated when set defines a variable outside of f.
+func newFrameEnv(parent *Env, f *frame) *Env {
+	return &Env{
+		frame:   f,
+		slots:   make([]*Value, len(f.symbols)),
+		parent:  parent,
+		interp:  parent.interp,
+		out:     parent.out,
+		depth:   parent.depth,
+		dynamic: parent.dynamic,
+	}
 }
 
+// Lookup returns the value of the variable named symbol visible from e. It
+// doesn't intern symbol, so looking up names that were never bound doesn't
+// grow the symbol table.
 func (e *Env) Lookup(symbol string) (result *Value, ok bool) {
+	s, ok := sexpressions.Interned(symbol)
+	if !ok {
+		// Only qualified names may refer to a variable without being
+		// interned.
+		s = sexpressions.NewUninternedSymbol(symbol)
+	}
+	return e.lookupSymbol(s)
+}
+
+// lookupSymbol looks up symbol in e, the modules imported into e, and then in
+// its ancestors. A symbol of the form module:name that isn't bound otherwise
+// refers to name exported by module.
+func (e *Env) lookupSymbol(symbol *sexpressions.Symbol) (result *Value, ok bool) {
 	for cursor := e; cursor != nil; cursor = cursor.parent {
-		value, ok := cursor.vars[symbol]
+		if i := cursor.frame.index(symbol); i >= 0 && cursor.slots[i] != nil {
+			return cursor.slots[i], true
+		}
+		value, ok := cursor.localVar(symbol)
 		if ok {
 			return value, true
 		}
+		for _, m := range cursor.importedModules() {
+			if value, ok := m.lookup(symbol); ok {
+				return value, true
+			}
+		}
 	}
-	return nil, false
+	return e.lookupQualified(symbol)
+}
+
+// Names returns the sorted names of the variables visible from e, including
+// ones exported by imported modules.
+func (e *Env) Names() []string {
+	seen := make(map[string]bool)
+	for cursor := e; cursor != nil; cursor = cursor.parent {
+		cursor.mu.RLock()
+		for symbol := range cursor.vars {
+			seen[symbol.Name] = true
+		}
+		cursor.mu.RUnlock()
+		for i, value := range cursor.slots {
+			if value != nil {
+				seen[cursor.frame.symbols[i].Name] = true
+			}
+		}
+		for _, m := range cursor.importedModules() {
+			for _, symbol := range m.exported() {
+				if _, ok := m.lookup(symbol); ok {
+					seen[symbol.Name] = true
+				}
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 func (e *Env) Set(symbol string, value *Value) {
+	e.setSymbol(sexpressions.Intern(symbol), value)
+}
+
+func (e *Env) setSymbol(symbol *sexpressions.Symbol, value *Value) {
+	if i := e.frame.index(symbol); i >= 0 {
+		e.slots[i] = value
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.vars == nil {
+		e.vars = make(map[*sexpressions.Symbol]*Value)
+	}
 	e.vars[symbol] = value
 }
 
+// localVar returns the variable defined by set in e itself.
+func (e *Env) localVar(symbol *sexpressions.Symbol) (*Value, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	value, ok := e.vars[symbol]
+	return value, ok
+}
+
+// importedModules returns the modules imported into e itself.
+func (e *Env) importedModules() []*module {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.imports
+}
+
+// topLevel returns the environment the top-level forms around e are evaluated
+// in, such as the one made by NewEnv or the one of a module body.
+func (e *Env) topLevel() *Env {
+	for e.parent != nil && (e.frame != nil || !e.hasVars()) {
+		e = e.parent
+	}
+	return e
+}
+
+// hasVars reports whether e has variables other than the slots.
+func (e *Env) hasVars() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.vars != nil || len(e.imports) > 0
+}
+
+// mayShadow reports whether e itself has symbol defined by set, or imports
+// modules that may export it.
+func (e *Env) mayShadow(symbol *sexpressions.Symbol) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	_, ok := e.vars[symbol]
+	return ok || len(e.imports) > 0
+}
+
+// assignSymbol changes the value of symbol in the nearest environment that
+// binds it. It returns false if symbol isn't bound. Variables exported by
+// imported modules can't be changed from outside the module.
+func (e *Env) assignSymbol(symbol *sexpressions.Symbol, value *Value) bool {
+	for cursor := e; cursor != nil; cursor = cursor.parent {
+		if i := cursor.frame.index(symbol); i >= 0 && cursor.slots[i] != nil {
+			cursor.slots[i] = value
+			return true
+		}
+		cursor.mu.Lock()
+		_, ok := cursor.vars[symbol]
+		if ok {
+			cursor.vars[symbol] = value
+		}
+		cursor.mu.Unlock()
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
 func (e *Env) String() string {
+	vars := make(map[string]*Value)
+	e.mu.RLock()
+	for symbol, value := range e.vars {
+		vars[symbol.Name] = value
+	}
+	e.mu.RUnlock()
+	for i, value := range e.slots {
+		if value != nil {
+			vars[e.frame.symbols[i].Name] = value
+		}
+	}
 	if e.parent != nil {
-		return fmt.Sprintf("%+v parent: %+v", e.vars, e.parent)
+		return fmt.Sprintf("%+v parent: %+v", vars, e.parent)
 	}
-	return fmt.Sprintf("%+v", e.vars)
+	return fmt.Sprintf("%+v", vars)
+}
+
+// Check reports whether sexp is a well-formed program without evaluating it.
+// For example, it checks that special forms have the right number of
+// arguments.
+func Check(sexp *sexpressions.SExp) error {
+	_, err := makeAST(sexp, nil)
+	return err
 }
 
 func (e *Env) Eval(sexp *sexpressions.SExp) (result *Value, err error) {
-	ast, err := makeAST(sexp)
+	ast, err := e.compile(sexp)
+	if err != nil {
+		var positioned *PositionError
+		if errors.As(err, &positioned) {
+			return nil, &PositionError{Pos: positioned.Pos, Err: newCondition(syntaxErrorCondition, "%v", positioned.Err)}
+		}
+		return nil, newCondition(syntaxErrorCondition, "makeAst(%v): %v", sexp, err)
+	}
+	defer e.enterEval()()
+	return eval(e, ast)
+}
+
+// compile returns the AST of sexp, reusing the one made when the same form
+// was last evaluated. Folded ASTs depend on the bindings of e, so they aren't
+// cached.
+func (e *Env) compile(sexp *sexpressions.SExp) (ast, error) {
+	if e.interp.foldConstants {
+		a, err := makeAST(sexp, nil)
+		if err != nil {
+			return nil, err
+		}
+		return e.fold(a), nil
+	}
+	hash := sexp.Hash()
+	if a, ok := e.interp.astCache.get(hash, sexp); ok {
+		return a, nil
+	}
+	a, err := makeAST(sexp, nil)
 	if err != nil {
-		return nil, fmt.Errorf("makeAst(%v): %v", sexp, err)
+		return nil, err
 	}
-	return ast.Eval(e)
+	e.interp.astCache.put(hash, sexp, a)
+	return a, nil
 }
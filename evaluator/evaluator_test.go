@@ -0,0 +1,116 @@
+package evaluator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+func TestBuiltinArity(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{src: "(car)", want: "car requires 1 arguments, but got 0"},
+		{src: "(cons 1 2 3)", want: "cons requires 2 arguments, but got 3"},
+		{src: "(make-map 1 2)", want: "make-map requires 0 to 1 arguments, but got 2"},
+		{src: "(next)", want: "next requires 1 to 2 arguments, but got 0"},
+		{src: "(apply car)", want: "apply requires at least 2 arguments, but got 1"},
+		{src: "(div 1)", want: "div requires at least 2 arguments, but got 1"},
+		{src: "(<)", want: "< requires at least 1 arguments, but got 0"},
+		{src: "(atomic-get)", want: "atomic-get requires 1 arguments, but got 0"},
+		{src: "(error)", want: "error requires at least 1 arguments, but got 0"},
+		{src: "(error 'my-error)", want: "error requires a message"},
+	}
+	env := NewEnv()
+	for _, tt := range tests {
+		_, err := env.EvalString(tt.src)
+		var c *ConditionValue
+		if !errors.As(err, &c) || c.Type != arityErrorCondition {
+			t.Errorf("%v: got error %v, want %v", tt.src, err, arityErrorCondition)
+			continue
+		}
+		if c.Message != tt.want {
+			t.Errorf("%v: got message %q, want %q", tt.src, c.Message, tt.want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	e := NewEnv()
+	if _, err := e.EvalString("(module m (export f) (define f 1)) (define x 2)"); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"x": "2", "m:f": "1"} {
+		if got, ok := e.Lookup(name); !ok || got.String() != want {
+			t.Errorf("Lookup(%v) = %v, %v, want %v", name, got, ok, want)
+		}
+	}
+	if _, ok := e.Lookup("lookup-test-unbound"); ok {
+		t.Error("Lookup found an unbound name")
+	}
+	if _, ok := sexpressions.Interned("lookup-test-unbound"); ok {
+		t.Error("Lookup interned an unbound name")
+	}
+}
+
+func TestGensym(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{src: "(eq? (gensym) (gensym))", want: "#f"},
+		{src: "(define g (gensym)) (eq? g g)", want: "#t"},
+		{src: "(eq? (gensym \"x\") 'x)", want: "#f"},
+		{src: "(gensym 1)", condition: typeErrorCondition},
+	})
+	got, err := NewEnv().EvalString(`(gensym "tmp")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got.String(), "#:tmp") {
+		t.Errorf("(gensym \"tmp\") = %v, want a name starting with #:tmp", got)
+	}
+}
+
+func TestChars(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{src: `#\a`, want: `#\a`},
+		{src: `(char->int #\a)`, want: "97"},
+		{src: `(char->int #\newline)`, want: "10"},
+		{src: `(char->int #\space)`, want: "32"},
+		{src: "(int->char 955)", want: `#\λ`},
+		{src: "(int->char 10)", want: `#\newline`},
+		{src: `(eq? (int->char 97) #\a)`, want: "#t"},
+		{src: `(char->int "a")`, condition: typeErrorCondition},
+		{src: "(int->char -1)", condition: rangeErrorCondition},
+		{src: "(int->char 55296)", condition: rangeErrorCondition},
+	})
+}
+
+func TestRead(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{src: `(read "(1 2 3)")`, want: "(1 2 3)"},
+		{src: `(car (read "(add 1 2)"))`, want: "add"},
+		{src: `(eval (read "(add 1 2)"))`, want: "3"},
+		{src: `(read "  x ; comment\n y")`, want: "x"},
+		{src: `(read "'a")`, want: "(quote a)"},
+		{src: `(read "\"s\"")`, want: `"s"`},
+		{src: `(read "(1 2")`, condition: syntaxErrorCondition},
+		{src: `(read "#|")`, condition: syntaxErrorCondition},
+		{src: `(read "")`, condition: syntaxErrorCondition},
+		{src: "(read 1)", condition: typeErrorCondition},
+	})
+}
+
+func TestValues(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{src: "(values 1 2)", want: "1 2"},
+		{src: "(values 1)", want: "1"},
+		{src: "(call-with-values (lambda () (values 1 2)) list)", want: "(1 2)"},
+		{src: "(call-with-values (lambda () (values 1 2)) add)", want: "3"},
+		{src: "(call-with-values (lambda () 5) list)", want: "(5)"},
+		{src: "(call-with-values (lambda () (values)) list)", want: "()"},
+		{src: "(call-with-values (lambda () (values 1 2)) (lambda (a) a))", condition: arityErrorCondition},
+		{src: "(call-with-values 1 list)", condition: typeErrorCondition},
+	})
+}
@@ -0,0 +1,81 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"reflect"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// runCommand runs cmd and returns a map of its standard output and error as
+// strings under :stdout and :stderr, and its exit code under :exit-code.
+// Exiting with a non-zero code isn't an error. If the evaluation is
+// interrupted, the command is killed, but not the processes it started.
+func (e *Env) runCommand(name string, cmd *exec.Cmd) (*Value, error) {
+	// The output is accounted for as it is read, and the command fails with
+	// a closed pipe once it exceeds the memory limit.
+	stdout, stderr := &allocatingWriter{e: e}, &allocatingWriter{e: e}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("%v: %w", name, err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	_, recv, _, err := e.block([]reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(done)},
+	})
+	if err != nil {
+		// Wait isn't waited for, since it doesn't return until the children
+		// of the command that inherited its output exit too.
+		cmd.Process.Kill()
+		return nil, err
+	}
+	for _, w := range []*allocatingWriter{stdout, stderr} {
+		if w.err != nil {
+			return nil, w.err
+		}
+	}
+	if waitErr, _ := recv.Interface().(error); waitErr != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(waitErr, &exitErr) {
+			return nil, fmt.Errorf("%v: %w", name, waitErr)
+		}
+	}
+	m := sexpressions.NewMap()
+	m.Set(sexpressions.NewKeyword("stdout"), sexpressions.NewString(stdout.buf.String()))
+	m.Set(sexpressions.NewKeyword("stderr"), sexpressions.NewString(stderr.buf.String()))
+	m.Set(sexpressions.NewKeyword("exit-code"), sexpressions.NewInt(cmd.ProcessState.ExitCode()))
+	return newSExpValue(sexpressions.NewMapSExp(m)), nil
+}
+
+// execPrimitives are primitives that run external commands.
+var execPrimitives = map[string]builtin{
+	// (exec program arg ...) runs program with args without a shell, and
+	// returns a map of its output and exit code such as
+	// {:stdout "..." :stderr "" :exit-code 0}. program is looked up in PATH
+	// unless it contains a slash.
+	"exec": {1, Variadic, func(e *Env, args []*Value) (*Value, error) {
+		strs := make([]string, len(args))
+		for i := range args {
+			s, ok := args[i].AsString()
+			if !ok {
+				return nil, newCondition(typeErrorCondition, "exec argument[%v] is not string: %v", i, args[i])
+			}
+			strs[i] = s
+		}
+		return e.runCommand("exec", exec.Command(strs[0], strs[1:]...))
+	}},
+	// (shell command) runs command with sh -c and returns a map of its output
+	// and exit code as exec does.
+	"shell": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		command, ok := args[0].AsString()
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "shell argument is not string: %v", args[0])
+		}
+		return e.runCommand("shell", exec.Command("sh", "-c", command))
+	}},
+}
@@ -0,0 +1,35 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExec(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{src: `(map-get (exec "echo" "a b" "c") :stdout)`, want: `"a b c\n"`},
+		{src: `(map-get (exec "sh" "-c" "exit 2") :exit-code)`, want: "2"},
+		{src: `(map-get (shell "echo hi; echo err >&2; exit 3") :stdout)`, want: `"hi\n"`},
+		{src: `(map-get (shell "echo hi; echo err >&2; exit 3") :stderr)`, want: `"err\n"`},
+		{src: `(map-get (shell "echo hi; echo err >&2; exit 3") :exit-code)`, want: "3"},
+		{src: `(map-get (shell "true") :exit-code)`, want: "0"},
+		{src: `(exec "exec-test-no-such-program")`, condition: errorCondition},
+		{src: `(exec "echo" 1)`, condition: typeErrorCondition},
+		{src: "(shell 'ls)", condition: typeErrorCondition},
+	})
+}
+
+func TestExecInterrupted(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := NewEnv().EvalStringContext(ctx, `(exec "sleep" "10")`)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("exec returned after %v, want the command killed", d)
+	}
+}
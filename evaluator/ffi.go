@@ -0,0 +1,370 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+var (
+	valuePtrType  = reflect.TypeOf((*Value)(nil))
+	envPtrType    = reflect.TypeOf((*Env)(nil))
+	bigIntPtrType = reflect.TypeOf((*big.Int)(nil))
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// RegisterFunc binds name in e to a primitive that calls the Go function fn.
+// Arguments are converted to the parameter types of fn and the result is
+// converted back, for example:
+//
+//	env.RegisterFunc("concat", func(a, b string) string { return a + b })
+//
+// Supported types are bool, integers, floats, string, *big.Int, slices
+// (from lists and vectors), maps (from maps), *Value (passed as is) and
+// interface{} (converted to the natural Go type). fn may take *Env as its first
+// parameter to receive the calling environment, may be variadic, and may
+// return an error as its last result. A non-nil error is signaled as a
+// condition of type error whose Cause is the error, or as the condition the
+// error wraps, if any. RegisterFunc fails if fn isn't a function or uses
+// unsupported types.
+func (e *Env) RegisterFunc(name string, fn interface{}) error {
+	f := reflect.ValueOf(fn)
+	if f.Kind() != reflect.Func || f.IsNil() {
+		return fmt.Errorf("RegisterFunc %s: %T is not a function", name, fn)
+	}
+	t := f.Type()
+	passEnv := t.NumIn() > 0 && t.In(0) == envPtrType
+	var params []reflect.Type
+	for i := 0; i < t.NumIn(); i++ {
+		if i == 0 && passEnv {
+			continue
+		}
+		param := t.In(i)
+		if t.IsVariadic() && i == t.NumIn()-1 {
+			param = param.Elem()
+		}
+		if !convertible(param) {
+			return fmt.Errorf("RegisterFunc %s: unsupported parameter type %v", name, param)
+		}
+		params = append(params, param)
+	}
+	returnsError := t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
+	results := t.NumOut()
+	if returnsError {
+		results--
+	}
+	if results > 1 {
+		return fmt.Errorf("RegisterFunc %s: functions may return at most 1 value besides an error", name)
+	}
+	if results == 1 && !convertible(t.Out(0)) {
+		return fmt.Errorf("RegisterFunc %s: unsupported result type %v", name, t.Out(0))
+	}
+
+	minArgs, maxArgs := len(params), len(params)
+	if t.IsVariadic() {
+		minArgs, maxArgs = len(params)-1, Variadic
+	}
+	e.RegisterPrimitive(name, minArgs, maxArgs, func(e *Env, args []*Value) (*Value, error) {
+		var in []reflect.Value
+		if passEnv {
+			in = append(in, reflect.ValueOf(e))
+		}
+		for i, arg := range args {
+			param := params[len(params)-1]
+			if i < len(params) {
+				param = params[i]
+			}
+			v, err := fromLisp(arg, param)
+			if err != nil {
+				return nil, newCondition(typeErrorCondition, "%s: argument %d: %v", name, i+1, err)
+			}
+			in = append(in, v)
+		}
+		out := f.Call(in)
+		if returnsError {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				var c *ConditionValue
+				if errors.As(err, &c) {
+					return nil, err
+				}
+				c = newCondition(errorCondition, "%s: %v", name, err)
+				c.Cause = err
+				return nil, c
+			}
+		}
+		if results == 0 {
+			return Nil, nil
+		}
+		result, err := toLisp(out[0])
+		if err != nil {
+			return nil, newCondition(typeErrorCondition, "%s: result: %v", name, err)
+		}
+		return result, nil
+	})
+	return nil
+}
+
+// Call calls v, which must be a function such as a lambda or a primitive, with
+// args converted to Lisp values as by RegisterFunc. It lets Go code use Lisp
+// functions as callbacks.
+func (v *Value) Call(args ...interface{}) (*Value, error) {
+	values := make([]*Value, len(args))
+	for i, arg := range args {
+		value, err := toLisp(reflect.ValueOf(arg))
+		if err != nil {
+			return nil, newCondition(typeErrorCondition, "argument %d: %v", i+1, err)
+		}
+		values[i] = value
+	}
+	var e *Env
+	switch v.valueType {
+	case Lambda:
+		e = v.value.(*LambdaValue).env
+	case Primitive:
+		e = v.value.(*primitive).env
+	}
+	if e != nil {
+		defer e.enterEval()()
+	}
+	return apply(e, v, values)
+}
+
+// convertible reports whether values of t can be converted from and to Lisp
+// values.
+func convertible(t reflect.Type) bool {
+	switch t {
+	case valuePtrType, bigIntPtrType:
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	case reflect.Interface:
+		return t.NumMethod() == 0
+	case reflect.Slice:
+		return convertible(t.Elem())
+	case reflect.Map:
+		return convertible(t.Key()) && convertible(t.Elem())
+	}
+	return false
+}
+
+// fromLisp converts v to a Go value of type t.
+func fromLisp(v *Value, t reflect.Type) (reflect.Value, error) {
+	mismatch := func() (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("cannot convert %v to %v", v, t)
+	}
+	switch t {
+	case valuePtrType:
+		return reflect.ValueOf(v), nil
+	case bigIntPtrType:
+		if b, ok := v.AsBigInt(); ok && v.valueType == SExp {
+			return reflect.ValueOf(new(big.Int).Set(b)), nil
+		}
+		if i, ok := v.AsInt(); ok && v.valueType == SExp {
+			return reflect.ValueOf(big.NewInt(int64(i))), nil
+		}
+		return mismatch()
+	}
+	if v.valueType != SExp {
+		if t.Kind() == reflect.Interface {
+			return reflect.ValueOf(v), nil
+		}
+		return mismatch()
+	}
+	result := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Bool:
+		b, ok := v.AsBool()
+		if !ok {
+			return mismatch()
+		}
+		result.SetBool(b)
+	case reflect.String:
+		s, ok := v.AsString()
+		if !ok {
+			return mismatch()
+		}
+		result.SetString(s)
+	case reflect.Float32, reflect.Float64:
+		n, ok := asNumber(v)
+		if !ok {
+			return mismatch()
+		}
+		result.SetFloat(n.float())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := v.AsInt()
+		if !ok {
+			return mismatch()
+		}
+		if result.OverflowInt(int64(i)) {
+			return reflect.Value{}, fmt.Errorf("%v overflows %v", v, t)
+		}
+		result.SetInt(int64(i))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := v.AsInt()
+		if !ok {
+			return mismatch()
+		}
+		if i < 0 || result.OverflowUint(uint64(i)) {
+			return reflect.Value{}, fmt.Errorf("%v overflows %v", v, t)
+		}
+		result.SetUint(uint64(i))
+	case reflect.Slice:
+		elems, ok := v.AsList()
+		if !ok {
+			elems, ok = v.AsVector()
+		}
+		if !ok {
+			return mismatch()
+		}
+		result = reflect.MakeSlice(t, len(elems), len(elems))
+		for i := range elems {
+			elem, err := fromLisp(newSExpValue(elems[i]), t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			result.Index(i).Set(elem)
+		}
+	case reflect.Map:
+		m, ok := v.AsMap()
+		if !ok {
+			return mismatch()
+		}
+		result = reflect.MakeMapWithSize(t, m.Len())
+		for _, key := range m.Keys() {
+			value, _ := m.Get(key)
+			k, err := fromLisp(newSExpValue(key), t.Key())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			// Keys of interface types hold values such as []interface{} for
+			// lists, which panic as Go map keys.
+			keyType := k.Type()
+			if keyType.Kind() == reflect.Interface && !k.IsNil() {
+				keyType = k.Elem().Type()
+			}
+			if !keyType.Comparable() {
+				return reflect.Value{}, fmt.Errorf("map key %v can't be a Go map key", key)
+			}
+			val, err := fromLisp(newSExpValue(value), t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			result.SetMapIndex(k, val)
+		}
+	case reflect.Interface:
+		natural, err := naturalGoValue(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if natural != nil {
+			result.Set(reflect.ValueOf(natural))
+		}
+	default:
+		return mismatch()
+	}
+	return result, nil
+}
+
+// naturalGoValue converts v to the Go type that represents it most directly,
+// such as int for integers and []interface{} for lists.
+func naturalGoValue(v *Value) (interface{}, error) {
+	switch v.Type {
+	case sexpressions.IntType, sexpressions.FloatType, sexpressions.StringType,
+		sexpressions.BoolType, sexpressions.CharType, sexpressions.BigIntType:
+		return v.Value, nil
+	case sexpressions.SymbolType:
+		name, _ := v.AsSymbol()
+		return name, nil
+	case sexpressions.KeywordType:
+		return v.Value, nil
+	case sexpressions.ListType, sexpressions.VectorType:
+		t := reflect.TypeOf([]interface{}{})
+		s, err := fromLisp(v, t)
+		if err != nil {
+			return nil, err
+		}
+		return s.Interface(), nil
+	case sexpressions.MapType:
+		t := reflect.TypeOf(map[interface{}]interface{}{})
+		m, err := fromLisp(v, t)
+		if err != nil {
+			return nil, err
+		}
+		return m.Interface(), nil
+	}
+	return v, nil
+}
+
+// toLisp converts the Go value v to a Lisp value.
+func toLisp(v reflect.Value) (*Value, error) {
+	if !v.IsValid() {
+		return Nil, nil
+	}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return Nil, nil
+		}
+	}
+	switch v.Type() {
+	case valuePtrType:
+		return v.Interface().(*Value), nil
+	case bigIntPtrType:
+		return newBigNumber(new(big.Int).Set(v.Interface().(*big.Int))).value(), nil
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		return toLisp(v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			return True, nil
+		}
+		return False, nil
+	case reflect.String:
+		return newStringValue(v.String()), nil
+	case reflect.Float32, reflect.Float64:
+		return number{f: v.Float(), isFloat: true}.value(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return newBigNumber(big.NewInt(v.Int())).value(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return newBigNumber(new(big.Int).SetUint64(v.Uint())).value(), nil
+	case reflect.Slice:
+		values := make([]*Value, v.Len())
+		for i := range values {
+			value, err := toLisp(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return newListValue(values), nil
+	case reflect.Map:
+		keys := v.MapKeys()
+		// Go maps are unordered, so sort keys to make the result deterministic.
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		m := sexpressions.NewMap()
+		for _, key := range keys {
+			k, err := toLisp(key)
+			if err != nil {
+				return nil, err
+			}
+			value, err := toLisp(v.MapIndex(key))
+			if err != nil {
+				return nil, err
+			}
+			m.Set(toSExp(k), toSExp(value))
+		}
+		return newSExpValue(sexpressions.NewMapSExp(m)), nil
+	}
+	return nil, fmt.Errorf("unsupported Go type %v", v.Type())
+}
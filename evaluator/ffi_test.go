@@ -0,0 +1,19 @@
+package evaluator
+
+import "testing"
+
+func TestRegisterFuncMap(t *testing.T) {
+	tests := []evalTest{
+		{src: "(count-keys (make-map (list (cons 1 1) (cons :a 2))))", want: "2"},
+		{src: "(count-keys (make-map (list (cons \"a\" 1))))", want: "1"},
+		{src: "(count-keys (make-map (list (cons (list 1 2) 1))))", condition: typeErrorCondition},
+		{src: "(count-keys (make-map (list (cons (vector 1 2) 1))))", condition: typeErrorCondition},
+	}
+	runEvalTestsIn(t, func() *Env {
+		e := NewEnv()
+		if err := e.RegisterFunc("count-keys", func(m map[interface{}]int) int { return len(m) }); err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}, tests)
+}
@@ -0,0 +1,136 @@
+package evaluator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// openFile opens the file at path in mode, which is :read, :write or
+// :append, and returns a port for it.
+func openFile(name, path string, mode *Value) (*port, error) {
+	keyword, ok := mode.AsKeyword()
+	if !ok || mode.valueType != SExp {
+		return nil, newCondition(typeErrorCondition, "%v mode is not keyword: %v", name, mode)
+	}
+	var flag int
+	switch keyword {
+	case "read":
+		flag = os.O_RDONLY
+	case "write":
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	case "append":
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	default:
+		return nil, newCondition(rangeErrorCondition, "%v mode is not :read, :write or :append: %v", name, mode)
+	}
+	f, err := os.OpenFile(path, flag, 0o666)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", name, err)
+	}
+	if flag == os.O_RDONLY {
+		return &port{r: bufio.NewReader(f), closer: f}, nil
+	}
+	return &port{w: f, closer: f}, nil
+}
+
+// openArgs returns the path and the mode of the arguments of open and
+// with-open-file, which are (path [mode]). The mode is :read by default.
+func openArgs(name string, args []*Value) (string, *Value, error) {
+	path, ok := args[0].AsString()
+	if !ok {
+		return "", nil, newCondition(typeErrorCondition, "%v path is not string: %v", name, args[0])
+	}
+	mode := newSExpValue(sexpressions.NewKeyword("read"))
+	if len(args) == 2 {
+		mode = args[1]
+	}
+	return path, mode, nil
+}
+
+// filePrimitives are primitives that read and write files.
+var filePrimitives = map[string]builtin{
+	// (open path [mode]) opens the file at path and returns a port for it.
+	// mode is :read, which is the default, :write, which truncates the file,
+	// or :append. Files opened for writing are created if they don't exist.
+	"open": {1, 2, func(e *Env, args []*Value) (*Value, error) {
+		path, mode, err := openArgs("open", args)
+		if err != nil {
+			return nil, err
+		}
+		p, err := openFile("open", path, mode)
+		if err != nil {
+			return nil, err
+		}
+		return newPortValue(p), nil
+	}},
+	// (close port) closes port. Reading or writing a closed port fails.
+	"close": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		p, err := asPort("close", args[0])
+		if err != nil {
+			return nil, err
+		}
+		if err := p.close(); err != nil {
+			return nil, fmt.Errorf("close: %w", err)
+		}
+		return Nil, nil
+	}},
+	// (with-open-file path [mode] fn) opens the file at path as by open,
+	// calls fn with the port, and closes it when fn returns or fails.
+	"with-open-file": {2, 3, func(e *Env, args []*Value) (result *Value, err error) {
+		fn := args[len(args)-1]
+		path, mode, err := openArgs("with-open-file", args[:len(args)-1])
+		if err != nil {
+			return nil, err
+		}
+		p, err := openFile("with-open-file", path, mode)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if cerr := p.close(); cerr != nil && err == nil {
+				result, err = nil, fmt.Errorf("with-open-file: %w", cerr)
+			}
+		}()
+		return apply(e, fn, []*Value{newPortValue(p)})
+	}},
+	// (read-file path) returns the contents of the file at path as a string.
+	"read-file": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		path, ok := args[0].AsString()
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "read-file path is not string: %v", args[0])
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("read-file: %w", err)
+		}
+		defer f.Close()
+		w := &allocatingWriter{e: e}
+		if _, err := io.Copy(w, f); err != nil {
+			if w.err != nil {
+				return nil, w.err
+			}
+			return nil, fmt.Errorf("read-file: %w", err)
+		}
+		return newStringValue(w.buf.String()), nil
+	}},
+	// (write-file path str) replaces the contents of the file at path with
+	// str, creating the file if it doesn't exist.
+	"write-file": {2, 2, func(e *Env, args []*Value) (*Value, error) {
+		path, ok := args[0].AsString()
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "write-file path is not string: %v", args[0])
+		}
+		s, ok := args[1].AsString()
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "write-file contents is not string: %v", args[1])
+		}
+		if err := os.WriteFile(path, []byte(s), 0o666); err != nil {
+			return nil, fmt.Errorf("write-file: %w", err)
+		}
+		return Nil, nil
+	}},
+}
@@ -0,0 +1,42 @@
+package evaluator
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := strconv.Quote(filepath.Join(dir, "a.txt"))
+	missing := strconv.Quote(filepath.Join(dir, "missing.txt"))
+	e := NewEnv()
+	tests := []evalTest{
+		{src: "(write-file " + path + " \"one\\ntwo\\n\")", want: "()"},
+		{src: "(read-file " + path + ")", want: `"one\ntwo\n"`},
+		{src: "(define p (open " + path + ")) (list (read-line p) (read-line p) (read-line p))", want: `("one" "two" ())`},
+		{src: "(close p) (read-line p)", condition: errorCondition},
+		{src: "(with-open-file " + path + " :append (lambda (p) (write-string \"three\" p)))", want: "()"},
+		{src: "(with-open-file " + path + " (lambda (p) (read-line p) (read-line p) (read-line p)))", want: `"three"`},
+		{src: "(with-open-file " + path + " :write (lambda (p) (format p \"~a\" 4)))", want: "()"},
+		{src: "(read-file " + path + ")", want: `"4"`},
+		{src: "(try (with-open-file " + path + " (lambda (p) (set! q p) (car 1))) (catch (e) (read-line q)))", condition: errorCondition},
+		{src: "(read-file " + missing + ")", condition: errorCondition},
+		{src: "(open " + path + " :bogus)", condition: rangeErrorCondition},
+		{src: "(open " + path + " \"r\")", condition: typeErrorCondition},
+		{src: "(write-file " + path + " 1)", condition: typeErrorCondition},
+		{src: "(close 1)", condition: typeErrorCondition},
+	}
+	e.Set("q", Nil)
+	runEvalTestsIn(t, func() *Env { return e }, tests)
+
+	if _, err := os.Stat(filepath.Join(dir, "missing.txt")); !os.IsNotExist(err) {
+		t.Errorf("read-file of a missing file created it: %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "a.txt"))
+	if err != nil || strings.TrimSpace(string(b)) != "4" {
+		t.Errorf("file contents = %q, %v, want 4", b, err)
+	}
+}
@@ -0,0 +1,255 @@
+package evaluator
+
+import "github.com/soishi1/toylisp/sexpressions"
+
+// fold returns a with constant subexpressions evaluated: applications of
+// pure primitives to literals are replaced by their results, and ifs with a
+// literal condition by the branch taken. Variables assigned anywhere in a are
+// not assumed to be bound to the primitives.
+func (e *Env) fold(a ast) ast {
+	assigned := make(map[*sexpressions.Symbol]bool)
+	collectAssigned(a, assigned)
+	return e.foldAST(a, assigned)
+}
+
+// foldAST folds a, in which the symbols in shadowed may not refer to the
+// bindings in e.
+func (e *Env) foldAST(a ast, shadowed map[*sexpressions.Symbol]bool) ast {
+	switch a := a.(type) {
+	case *lambdaAST:
+		inner := withSymbols(shadowed, a.params.symbols()...)
+		replaceChildren(a, func(child ast) ast {
+			return e.foldAST(child, inner)
+		})
+		return a
+	case *tryAST:
+		for i := range a.bodyASTs {
+			a.bodyASTs[i] = e.foldAST(a.bodyASTs[i], shadowed)
+		}
+		for _, clause := range a.clauses {
+			inner := withSymbols(shadowed, clause.symbol)
+			for i := range clause.handlerASTs {
+				clause.handlerASTs[i] = e.foldAST(clause.handlerASTs[i], inner)
+			}
+		}
+		return a
+	case *selectAST:
+		for _, clause := range a.clauses {
+			clause.chanAST = e.foldAST(clause.chanAST, shadowed)
+			if clause.valueAST != nil {
+				clause.valueAST = e.foldAST(clause.valueAST, shadowed)
+			}
+			inner := shadowed
+			if clause.frame != nil {
+				inner = withSymbols(shadowed, clause.frame.symbols...)
+			}
+			for i := range clause.bodyASTs {
+				clause.bodyASTs[i] = e.foldAST(clause.bodyASTs[i], inner)
+			}
+		}
+		for i := range a.defaultASTs {
+			a.defaultASTs[i] = e.foldAST(a.defaultASTs[i], shadowed)
+		}
+		return a
+	case *letAST:
+		for i := range a.initASTs {
+			a.initASTs[i] = e.foldAST(a.initASTs[i], shadowed)
+		}
+		inner := shadowed
+		if a.name != nil {
+			inner = withSymbols(shadowed, a.name)
+		}
+		a.lambdaAST = e.foldAST(a.lambdaAST, inner)
+		return a
+	case *iterationAST:
+		a.exprAST = e.foldAST(a.exprAST, shadowed)
+		inner := withSymbols(shadowed, a.frame.symbols...)
+		if a.resultAST != nil {
+			a.resultAST = e.foldAST(a.resultAST, inner)
+		}
+		for i := range a.bodyASTs {
+			a.bodyASTs[i] = e.foldAST(a.bodyASTs[i], inner)
+		}
+		return a
+	case *doAST:
+		for i := range a.initASTs {
+			a.initASTs[i] = e.foldAST(a.initASTs[i], shadowed)
+		}
+		inner := withSymbols(shadowed, a.frame.symbols...)
+		for i := range a.stepASTs {
+			if a.stepASTs[i] != nil {
+				a.stepASTs[i] = e.foldAST(a.stepASTs[i], inner)
+			}
+		}
+		a.testAST = e.foldAST(a.testAST, inner)
+		for i := range a.resultASTs {
+			a.resultASTs[i] = e.foldAST(a.resultASTs[i], inner)
+		}
+		for i := range a.bodyASTs {
+			a.bodyASTs[i] = e.foldAST(a.bodyASTs[i], inner)
+		}
+		return a
+	case *applicationAST:
+		replaceChildren(a, func(child ast) ast {
+			return e.foldAST(child, shadowed)
+		})
+		if value, ok := e.foldApplication(a, shadowed); ok {
+			return &literalAST{astNode: a.astNode, value: value}
+		}
+		return a
+	case *ifAST:
+		replaceChildren(a, func(child ast) ast {
+			return e.foldAST(child, shadowed)
+		})
+		if cond, ok := a.condAST.(*literalAST); ok {
+			if isTrue(cond.value) {
+				return a.thenAST
+			}
+			return a.elseAST
+		}
+		return a
+	}
+	replaceChildren(a, func(child ast) ast {
+		return e.foldAST(child, shadowed)
+	})
+	return a
+}
+
+// withSymbols returns a copy of shadowed with symbols added.
+func withSymbols(shadowed map[*sexpressions.Symbol]bool, symbols ...*sexpressions.Symbol) map[*sexpressions.Symbol]bool {
+	result := make(map[*sexpressions.Symbol]bool, len(shadowed)+len(symbols))
+	for symbol := range shadowed {
+		result[symbol] = true
+	}
+	for _, symbol := range symbols {
+		result[symbol] = true
+	}
+	return result
+}
+
+// foldApplication returns the value of a if it applies a pure primitive to
+// literals and succeeds. Failing applications are left to fail at run time.
+func (e *Env) foldApplication(a *applicationAST, shadowed map[*sexpressions.Symbol]bool) (*Value, bool) {
+	lookup, ok := a.funcAST.(*lookupAST)
+	if !ok || shadowed[lookup.symbol] {
+		return nil, false
+	}
+	fn, ok := e.lookupSymbol(lookup.symbol)
+	if !ok || fn.valueType != Primitive || !fn.value.(*primitive).pure {
+		return nil, false
+	}
+	args := make([]*Value, len(a.argASTs))
+	for i, argAST := range a.argASTs {
+		literal, ok := argAST.(*literalAST)
+		if !ok {
+			return nil, false
+		}
+		args[i] = literal.value
+	}
+	value, err := fn.value.(*primitive).call(e, args)
+	if err != nil || value.valueType != SExp {
+		return nil, false
+	}
+	return value, true
+}
+
+// collectAssigned adds the symbols assigned by set, define and set! in a to
+// assigned.
+func collectAssigned(a ast, assigned map[*sexpressions.Symbol]bool) {
+	switch a := a.(type) {
+	case *setAST:
+		assigned[a.symbol] = true
+	case *assignAST:
+		assigned[a.symbol] = true
+	}
+	replaceChildren(a, func(child ast) ast {
+		collectAssigned(child, assigned)
+		return child
+	})
+}
+
+// replaceChildren replaces each AST directly contained in a with the result
+// of fn.
+func replaceChildren(a ast, fn func(ast) ast) {
+	replaceAll := func(asts []ast) {
+		for i := range asts {
+			asts[i] = fn(asts[i])
+		}
+	}
+	switch a := a.(type) {
+	case *ifAST:
+		a.condAST, a.thenAST, a.elseAST = fn(a.condAST), fn(a.thenAST), fn(a.elseAST)
+	case *setAST:
+		a.valueAST = fn(a.valueAST)
+	case *assignAST:
+		a.valueAST = fn(a.valueAST)
+	case *lambdaAST:
+		for _, key := range a.params.keys {
+			if key.defaultAST != nil {
+				key.defaultAST = fn(key.defaultAST)
+			}
+		}
+		replaceAll(a.bodyASTs)
+	case *applicationAST:
+		a.funcAST = fn(a.funcAST)
+		replaceAll(a.argASTs)
+	case *tryAST:
+		replaceAll(a.bodyASTs)
+		for _, clause := range a.clauses {
+			replaceAll(clause.handlerASTs)
+		}
+	case *unwindProtectAST:
+		a.protectedAST = fn(a.protectedAST)
+		replaceAll(a.cleanupASTs)
+	case *moduleAST:
+		replaceAll(a.bodyASTs)
+	case *delayAST:
+		a.exprAST = fn(a.exprAST)
+	case *streamConsAST:
+		a.headAST, a.tailAST = fn(a.headAST), fn(a.tailAST)
+	case *letAST:
+		replaceAll(a.initASTs)
+		a.lambdaAST = fn(a.lambdaAST)
+	case *whenAST:
+		a.condAST = fn(a.condAST)
+		replaceAll(a.bodyASTs)
+	case *caseAST:
+		a.keyAST = fn(a.keyAST)
+		for _, clauseASTs := range a.clauseASTs {
+			replaceAll(clauseASTs)
+		}
+		replaceAll(a.elseASTs)
+	case *iterationAST:
+		a.exprAST = fn(a.exprAST)
+		if a.resultAST != nil {
+			a.resultAST = fn(a.resultAST)
+		}
+		replaceAll(a.bodyASTs)
+	case *doAST:
+		replaceAll(a.initASTs)
+		for i := range a.stepASTs {
+			if a.stepASTs[i] != nil {
+				a.stepASTs[i] = fn(a.stepASTs[i])
+			}
+		}
+		a.testAST = fn(a.testAST)
+		replaceAll(a.resultASTs)
+		replaceAll(a.bodyASTs)
+	case *withLockAST:
+		a.mutexAST = fn(a.mutexAST)
+		replaceAll(a.bodyASTs)
+	case *selectAST:
+		for _, clause := range a.clauses {
+			clause.chanAST = fn(clause.chanAST)
+			if clause.valueAST != nil {
+				clause.valueAST = fn(clause.valueAST)
+			}
+			replaceAll(clause.bodyASTs)
+		}
+		replaceAll(a.defaultASTs)
+	case *parameterizeAST:
+		replaceAll(a.paramASTs)
+		replaceAll(a.valueASTs)
+		replaceAll(a.bodyASTs)
+	}
+}
@@ -0,0 +1,43 @@
+package evaluator
+
+import "testing"
+
+func TestFold(t *testing.T) {
+	e := NewEnv(WithConstantFolding())
+	tests := []struct {
+		src string
+		// want is the printed value the form is folded into, or "" if it isn't
+		// folded.
+		want string
+	}{
+		{src: "(add 1 (mul 2 3))", want: "7"},
+		{src: "(if (< 1 2) (add 1 1) x)", want: "2"},
+		{src: "(add x 1)"},
+		{src: "(car 1)"},
+		{src: "(print 1)"},
+	}
+	for _, tt := range tests {
+		a, err := e.compile(parseForm(t, tt.src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		literal, ok := a.(*literalAST)
+		switch {
+		case tt.want == "" && ok:
+			t.Errorf("%v is folded into %v", tt.src, literal.value)
+		case tt.want != "" && (!ok || literal.value.String() != tt.want):
+			t.Errorf("%v is compiled into %T, want it folded into %v", tt.src, a, tt.want)
+		}
+	}
+
+	// Folding doesn't change the results, including of primitives that are
+	// shadowed or assigned in the same form.
+	runEvalTestsIn(t, func() *Env { return NewEnv(WithConstantFolding()) }, []evalTest{
+		{src: "(dotimes (i 3 (add 1 2)) (mul 4 5))", want: "3"},
+		{src: "((lambda (add) (add 1 2)) sub)", want: "-1"},
+		{src: "(let ((mul add)) (mul 2 3))", want: "5"},
+		{src: "((lambda () (set add sub) (add 1 2)))", want: "-1"},
+		{src: "(if #f (car 1) 'ok)", want: "ok"},
+		{src: "(car 1)", condition: typeErrorCondition},
+	})
+}
@@ -0,0 +1,224 @@
+package evaluator
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+	"sync"
+)
+
+// errGeneratorClosed unwinds the function of a generator closed while it is
+// suspended in yield. Like ErrInterrupted, it isn't caught by try, but
+// unwind-protect sees it.
+var errGeneratorClosed = errors.New("generator closed")
+
+// generator is a function running on its own goroutine that produces values
+// on demand, made by make-generator. It is a separate object from its state
+// so that a generator no longer referenced is closed by its finalizer, which
+// ends the goroutine suspended in yield.
+type generator struct {
+	*generatorState
+}
+
+type generatorState struct {
+	// mu guards started, running and done.
+	mu      sync.Mutex
+	env     *Env
+	fn      *Value
+	started bool
+	// running is true while fn runs between next and yield. next and close
+	// fail meanwhile instead of waiting, since they may be called by fn
+	// itself, and so does yield otherwise.
+	running bool
+	done    bool
+	// resume gets true to make the suspended yield return, and false to
+	// close the generator.
+	resume chan bool
+	// results gets a value for each yield, and the final result of fn.
+	results chan generatorResult
+}
+
+type generatorResult struct {
+	value *Value
+	err   error
+	// done is true for the final result.
+	done bool
+}
+
+func newGenerator(e *Env, fn *Value) *generator {
+	g := &generator{&generatorState{
+		env:     e,
+		fn:      fn,
+		resume:  make(chan bool),
+		results: make(chan generatorResult),
+	}}
+	runtime.SetFinalizer(g, func(g *generator) {
+		go g.close()
+	})
+	return g
+}
+
+// next resumes the generator until it yields, and returns the value it
+// yielded. ok is false if the function has returned, either now or before.
+// If waiting is interrupted, the generator is closed once the function
+// yields or returns.
+func (g *generatorState) next(e *Env) (value *Value, ok bool, err error) {
+	g.mu.Lock()
+	if g.done {
+		g.mu.Unlock()
+		return nil, false, nil
+	}
+	if g.running {
+		g.mu.Unlock()
+		return nil, false, newCondition(errorCondition, "next of generator that is running")
+	}
+	g.running = true
+	if g.started {
+		g.mu.Unlock()
+		g.resume <- true
+	} else {
+		g.started = true
+		g.mu.Unlock()
+		go g.run()
+	}
+	_, recv, _, err := e.block([]reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(g.results)},
+	})
+	if err != nil {
+		g.mu.Lock()
+		g.done = true
+		g.mu.Unlock()
+		go g.discard()
+		return nil, false, err
+	}
+	r := recv.Interface().(generatorResult)
+	if r.done {
+		return nil, false, r.err
+	}
+	return r.value, true, nil
+}
+
+// discard waits for the running function to yield or return after next was
+// interrupted, and closes it if it yielded.
+func (g *generatorState) discard() {
+	if r := <-g.results; !r.done {
+		g.resume <- false
+		<-g.results
+	}
+}
+
+// suspend marks the function as no longer running before it sends a result
+// to next, so that next can be called again once the result is received.
+func (g *generatorState) suspend(done bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.running = false
+	if done {
+		g.done = true
+	}
+}
+
+func (g *generatorState) run() {
+	yield := makePrimitive(g.env, "yield", 0, 1, func(e *Env, args []*Value) (*Value, error) {
+		g.mu.Lock()
+		running := g.running
+		g.mu.Unlock()
+		if !running {
+			// Nothing would receive the value, such as when yield escapes
+			// the generator and is called after it returns.
+			return nil, newCondition(errorCondition, "yield called outside of its running generator")
+		}
+		value := Nil
+		if len(args) == 1 {
+			value = args[0]
+		}
+		g.suspend(false)
+		g.results <- generatorResult{value: value}
+		if !<-g.resume {
+			return nil, errGeneratorClosed
+		}
+		return Nil, nil
+	})
+	value, err := apply(g.env, g.fn, []*Value{yield})
+	if errors.Is(err, errGeneratorClosed) {
+		err = nil
+	}
+	g.suspend(true)
+	g.results <- generatorResult{value: value, err: err, done: true}
+}
+
+// close makes the suspended function return by failing yield, so that its
+// unwind-protect cleanups run. Later calls to next return no value. Closing
+// a running generator fails.
+func (g *generatorState) close() error {
+	g.mu.Lock()
+	if g.done {
+		g.mu.Unlock()
+		return nil
+	}
+	if g.running {
+		g.mu.Unlock()
+		return newCondition(errorCondition, "generator-close of generator that is running")
+	}
+	g.done = true
+	started := g.started
+	g.mu.Unlock()
+	if !started {
+		return nil
+	}
+	g.resume <- false
+	return (<-g.results).err
+}
+
+func asGenerator(name string, v *Value) (*generator, error) {
+	if v.valueType != Generator {
+		return nil, newCondition(typeErrorCondition, "%v argument is not generator: %v", name, v)
+	}
+	return v.value.(*generator), nil
+}
+
+// generatorPrimitives are primitives that make and consume generators.
+var generatorPrimitives = map[string]builtin{
+	// (make-generator fn) returns a generator that calls fn with a yield
+	// function when next is first called on it. Each call to (yield value)
+	// suspends fn and makes next return value.
+	"make-generator": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		switch args[0].valueType {
+		case Lambda, Primitive:
+		default:
+			return nil, newCondition(typeErrorCondition, "make-generator argument is not function: %v", args[0])
+		}
+		return &Value{valueType: Generator, value: newGenerator(e, args[0])}, nil
+	}},
+	// (next gen [default]) resumes gen and returns the next value it yields,
+	// or default, which is nil by default, once its function has returned.
+	"next": {1, 2, func(e *Env, args []*Value) (*Value, error) {
+		g, err := asGenerator("next", args[0])
+		if err != nil {
+			return nil, err
+		}
+		value, ok, err := g.next(e)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			if len(args) == 2 {
+				return args[1], nil
+			}
+			return Nil, nil
+		}
+		return value, nil
+	}},
+	// (generator-close gen) stops gen, running the cleanups of its function
+	// if it is suspended.
+	"generator-close": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		g, err := asGenerator("generator-close", args[0])
+		if err != nil {
+			return nil, err
+		}
+		if err := g.close(); err != nil {
+			return nil, err
+		}
+		return Nil, nil
+	}},
+}
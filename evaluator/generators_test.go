@@ -0,0 +1,53 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGenerator(t *testing.T) {
+	tests := []evalTest{
+		{src: "(set g (make-generator (lambda (yield) (yield 1) (yield 2)))) (list (next g) (next g) (next g :end))", want: "(1 2 :end)"},
+		{src: "(set g (make-generator (lambda (yield) (next g)))) (next g)", condition: errorCondition},
+		{src: "(set g (make-generator (lambda (yield) (generator-close g)))) (next g)", condition: errorCondition},
+		{src: "(set ch (chan 1)) (set g (make-generator (lambda (yield) (send ch yield)))) (next g) ((recv ch) 1)", condition: errorCondition},
+		{src: "(set ch (chan 1)) (set g (make-generator (lambda (yield) (send ch yield) (yield 1)))) (next g) ((recv ch) 2)", condition: errorCondition},
+		{src: "(set g (make-generator (lambda (yield) (next g)))) (try (next g) (catch error (c) 1)) (next g :end)", want: ":end"},
+	}
+	runEvalTests(t, tests)
+}
+
+func TestGeneratorNextInterrupted(t *testing.T) {
+	e := NewEnv()
+	if _, err := e.EvalString("(set ch (chan)) (set g (make-generator (lambda (yield) (yield (recv ch)))))"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := e.EvalStringContext(ctx, "(next g)"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	got, err := e.EvalString("(next g :end)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != ":end" {
+		t.Errorf("next after interrupted next = %v, want :end", got)
+	}
+}
+
+func TestGeneratorNextInterrupt(t *testing.T) {
+	e := NewEnv()
+	if _, err := e.EvalString("(set g (make-generator (lambda (yield) (dotimes (i 1000000000000) i))))"); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		e.Interrupt()
+	}()
+	if _, err := e.EvalString("(next g)"); !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("got error %v, want %v", err, ErrInterrupted)
+	}
+}
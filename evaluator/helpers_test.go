@@ -0,0 +1,50 @@
+package evaluator
+
+import (
+	"errors"
+	"testing"
+)
+
+// conditionType returns the type of the condition err wraps, or "" if none.
+func conditionType(err error) string {
+	var c *ConditionValue
+	if !errors.As(err, &c) {
+		return ""
+	}
+	return c.Type
+}
+
+// evalTest is a source and either the printed value it evaluates to or the
+// type of the condition it fails with.
+type evalTest struct {
+	src       string
+	want      string
+	condition string
+}
+
+// runEvalTests evaluates each of tests in a new environment.
+func runEvalTests(t *testing.T, tests []evalTest) {
+	t.Helper()
+	runEvalTestsIn(t, func() *Env { return NewEnv() }, tests)
+}
+
+// runEvalTestsIn evaluates each of tests in an environment made by newEnv.
+func runEvalTestsIn(t *testing.T, newEnv func() *Env, tests []evalTest) {
+	t.Helper()
+	for _, tt := range tests {
+		got, err := newEnv().EvalString(tt.src)
+		if tt.condition != "" {
+			if conditionType(err) != tt.condition {
+				t.Errorf("%v: got error %v, want %v", tt.src, err, tt.condition)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", tt.src, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%v = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package evaluator
+
+import (
+	"time"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// Hooks are callbacks invoked during evaluation, which can be used to write
+// tracers, profilers and debuggers. Any of them may be nil. They are called
+// synchronously, so they should return quickly.
+type Hooks struct {
+	// BeforeEval is called before form is evaluated in e.
+	BeforeEval func(form *sexpressions.SExp, e *Env)
+	// AfterEval is called after form is evaluated in e with its result, the
+	// error if it failed and how long it took.
+	AfterEval func(form *sexpressions.SExp, e *Env, result *Value, err error, d time.Duration)
+	// BeforeApply is called before fn is applied to args in e.
+	BeforeApply func(fn *Value, args []*Value, e *Env)
+	// AfterApply is called after fn is applied to args in e with its result,
+	// the error if it failed and how long it took.
+	AfterApply func(fn *Value, args []*Value, e *Env, result *Value, err error, d time.Duration)
+}
+
+// SetHooks sets the callbacks invoked during evaluation in all environments
+// derived from the same NewEnv as e. Passing nil removes them.
+func (e *Env) SetHooks(hooks *Hooks) {
+	e.interp.hooks.Store(hooks)
+}
+
+// loadHooks returns the hooks set by SetHooks, or nil. Callers read them once
+// so that SetHooks running concurrently can't remove them halfway.
+func (i *interpreter) loadHooks() *Hooks {
+	return i.hooks.Load().(*Hooks)
+}
+
+func (e *Env) evalWithHooks(hooks *Hooks, a ast) (*Value, error) {
+	if hooks.BeforeEval != nil {
+		hooks.BeforeEval(a.form(), e)
+	}
+	start := time.Now()
+	value, err := a.Eval(e)
+	if hooks.AfterEval != nil {
+		hooks.AfterEval(a.form(), e, value, err, time.Since(start))
+	}
+	return value, err
+}
+
+func (e *Env) applyWithHooks(hooks *Hooks, fn *Value, args []*Value) (*Value, error) {
+	if hooks.BeforeApply != nil {
+		hooks.BeforeApply(fn, args, e)
+	}
+	start := time.Now()
+	value, err := applyFunc(e, fn, args)
+	if hooks.AfterApply != nil {
+		hooks.AfterApply(fn, args, e, value, err, time.Since(start))
+	}
+	return value, err
+}
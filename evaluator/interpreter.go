@@ -0,0 +1,138 @@
+package evaluator
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"math/rand"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// interpreter holds the state shared by all environments derived from the
+// same NewEnv, as opposed to package-level state shared by every interpreter.
+type interpreter struct {
+	randMu sync.Mutex
+	rand   *rand.Rand
+	// stdin is where input primitives such as read-line read from.
+	stdin *bufio.Reader
+	// stdout is where output primitives write to.
+	stdout io.Writer
+	// stderr is where error output primitives such as eprint write to.
+	stderr io.Writer
+	// commandLine is the list returned by the command-line primitive.
+	commandLine []string
+	// clock holds the *clockState set by SetClock, which time primitives
+	// running in other goroutines may read meanwhile.
+	clock atomic.Value
+
+	conditionsMu sync.Mutex
+	// conditionParents maps condition types to their parents.
+	conditionParents map[string]string
+
+	modulesMu sync.Mutex
+	// modules maps names to modules defined by the module special form.
+	modules map[string]*module
+
+	requireMu sync.Mutex
+	// loadPath is the list of directories require searches.
+	loadPath []string
+	// required maps paths of files loaded by require to whether loading them
+	// has finished.
+	required map[string]bool
+
+	// interrupted is set to 1 by Interrupt, and evaluation stops when it sees
+	// it.
+	interrupted int32
+	// interruptCh gets a value on Interrupt, so that primitives blocked on
+	// channels wake up.
+	interruptCh chan struct{}
+	// ctx holds the *evalContext of the running evaluation. It is read by
+	// tasks while EvalContext replaces it, so it is accessed atomically, as
+	// are the other settings below.
+	ctx atomic.Value
+
+	// stepLimit is the maximum number of steps per call to Eval, or 0 if
+	// there is no limit.
+	stepLimit int64
+	// steps is the number of ASTs evaluated by the outermost running Eval.
+	steps int64
+	// evalDepth is the number of nested calls to Eval running.
+	evalDepth int32
+
+	// memoryLimit is the maximum number of bytes allocated per call to Eval,
+	// or 0 if there is no limit.
+	memoryLimit int64
+	// allocated is the approximate number of bytes allocated by the
+	// outermost running Eval.
+	allocated int64
+
+	// hooks holds the *Hooks set by SetHooks, which may be nil.
+	hooks atomic.Value
+	// allowance holds the *allowance of the running cleanup forms of
+	// unwind-protect, which is nil outside of them.
+	allowance atomic.Value
+
+	// foldConstants is true if forms are constant folded before evaluation.
+	foldConstants bool
+
+	astCache *astCache
+}
+
+func newInterpreter() *interpreter {
+	conditionParents := make(map[string]string)
+	for t, parent := range builtinConditionParents {
+		conditionParents[t] = parent
+	}
+	i := &interpreter{
+		rand:             rand.New(rand.NewSource(time.Now().UnixNano())),
+		stdin:            bufio.NewReader(os.Stdin),
+		stdout:           os.Stdout,
+		stderr:           os.Stderr,
+		commandLine:      os.Args,
+		conditionParents: conditionParents,
+		modules:          make(map[string]*module),
+		loadPath:         defaultLoadPath(),
+		required:         make(map[string]bool),
+		astCache:         newASTCache(),
+		interruptCh:      make(chan struct{}, 1),
+	}
+	i.ctx.Store(&evalContext{ctx: context.Background()})
+	i.hooks.Store((*Hooks)(nil))
+	i.allowance.Store((*allowance)(nil))
+	i.clock.Store(&clockState{clock: systemClock{}, start: time.Now()})
+	return i
+}
+
+// SetRandomSeed reseeds the random number generator used by the random
+// primitive, so that runs can be reproduced.
+func (e *Env) SetRandomSeed(seed int64) {
+	e.interp.randMu.Lock()
+	defer e.interp.randMu.Unlock()
+	e.interp.rand.Seed(seed)
+}
+
+// SetInput sets the reader that input primitives such as read-line read
+// from. It is os.Stdin by default. A *bufio.Reader is used as is, so that
+// input it has buffered for other readers, such as a REPL, isn't lost.
+func (e *Env) SetInput(r io.Reader) {
+	if b, ok := r.(*bufio.Reader); ok {
+		e.interp.stdin = b
+		return
+	}
+	e.interp.stdin = bufio.NewReader(r)
+}
+
+// SetOutput sets the writer that output primitives such as print write to.
+// It is os.Stdout by default.
+func (e *Env) SetOutput(w io.Writer) {
+	e.interp.stdout = w
+}
+
+// SetErrorOutput sets the writer that error output primitives such as eprint
+// write to. It is os.Stderr by default.
+func (e *Env) SetErrorOutput(w io.Writer) {
+	e.interp.stderr = w
+}
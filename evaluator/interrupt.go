@@ -0,0 +1,125 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// ErrInterrupted is returned by evaluations stopped by Env.Interrupt. Unlike
+// conditions, it isn't caught by try.
+var ErrInterrupted = errors.New("interrupted")
+
+// Interrupt stops the evaluation running in an environment derived from the
+// same NewEnv as e. It is safe to call from another goroutine, such as a
+// signal handler. Environments stay usable after the interrupted evaluation
+// returns ErrInterrupted.
+func (e *Env) Interrupt() {
+	atomic.StoreInt32(&e.interp.interrupted, 1)
+	select {
+	case e.interp.interruptCh <- struct{}{}:
+	default:
+	}
+}
+
+// ClearInterrupt discards a call to Interrupt that no evaluation has seen,
+// such as one made after the evaluation it was meant for returned, so that
+// it doesn't stop the next evaluation.
+func (e *Env) ClearInterrupt() {
+	atomic.StoreInt32(&e.interp.interrupted, 0)
+	select {
+	case <-e.interp.interruptCh:
+	default:
+	}
+}
+
+// checkInterrupt returns ErrInterrupted once for each call to Interrupt, and
+// a cancelledError if the context of the evaluation is done unless cleanup
+// forms are running within their allowance.
+func (e *Env) checkInterrupt() error {
+	if atomic.CompareAndSwapInt32(&e.interp.interrupted, 1, 0) {
+		select {
+		case <-e.interp.interruptCh:
+		default:
+		}
+		return ErrInterrupted
+	}
+	if e.interp.loadAllowance() != nil {
+		return nil
+	}
+	ctx := e.interp.context()
+	select {
+	case <-ctx.Done():
+		return &cancelledError{err: ctx.Err()}
+	default:
+		return nil
+	}
+}
+
+// cancelledError is returned by evaluations stopped because their context is
+// done. It matches both ErrInterrupted and the error of the context with
+// errors.Is.
+type cancelledError struct {
+	err error
+}
+
+func (c *cancelledError) Error() string {
+	return "interrupted: " + c.err.Error()
+}
+
+func (c *cancelledError) Unwrap() error {
+	return c.err
+}
+
+func (c *cancelledError) Is(target error) bool {
+	return target == ErrInterrupted
+}
+
+// EvalContext evaluates sexp like Eval, but stops with an error matching
+// ctx.Err() when ctx is cancelled or its deadline passes. Tasks spawned by
+// the evaluation are cancelled and waited for when it returns, so that they
+// don't outlive ctx.
+func (e *Env) EvalContext(ctx context.Context, sexp *sexpressions.SExp) (*Value, error) {
+	defer e.setContext(ctx)()
+	return e.Eval(sexp)
+}
+
+// EvalStringContext evaluates src like EvalString, but stops when ctx is done.
+func (e *Env) EvalStringContext(ctx context.Context, src string) (*Value, error) {
+	defer e.setContext(ctx)()
+	return e.EvalString(src)
+}
+
+// evalContext is the context of evaluations started by EvalContext and the
+// like, and the tasks spawned by them.
+type evalContext struct {
+	ctx   context.Context
+	tasks sync.WaitGroup
+}
+
+// context returns the context of the running evaluation.
+func (i *interpreter) context() context.Context {
+	return i.evalContext().ctx
+}
+
+func (i *interpreter) evalContext() *evalContext {
+	return i.ctx.Load().(*evalContext)
+}
+
+// setContext makes ctx the context of evaluations in e's interpreter, and
+// returns a function that cancels the tasks spawned meanwhile, waits for them
+// and restores the previous context.
+func (e *Env) setContext(ctx context.Context) (restore func()) {
+	old := e.interp.evalContext()
+	ctx, cancel := context.WithCancel(ctx)
+	current := &evalContext{ctx: ctx}
+	e.interp.ctx.Store(current)
+	return func() {
+		cancel()
+		current.tasks.Wait()
+		e.interp.ctx.Store(old)
+	}
+}
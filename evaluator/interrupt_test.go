@@ -0,0 +1,93 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+func TestEvalContextCancelsTasks(t *testing.T) {
+	e := NewEnv()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := e.EvalStringContext(ctx, `
+(set counter 0)
+(set task (spawn (lambda () (dotimes (i 1000000000000) (set counter (add counter 1))))))
+(join task)`)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	// The task was cancelled with the evaluation, so the counter stays put.
+	before, err := e.EvalString("counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	after, err := e.EvalString("counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.String() != after.String() {
+		t.Errorf("task kept running after the evaluation returned: counter %v -> %v", before, after)
+	}
+	done, err := e.EvalString("(task-done? task)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done != True {
+		t.Errorf("(task-done? task) = %v, want true", done)
+	}
+}
+
+func TestEvalContextTasksFinish(t *testing.T) {
+	e := NewEnv()
+	got, err := e.EvalStringContext(context.Background(), "(join (spawn (lambda () (add 1 2))))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != "3" {
+		t.Errorf("got %v, want 3", got)
+	}
+}
+
+func TestSetHooksConcurrently(t *testing.T) {
+	e := NewEnv()
+	hooks := &Hooks{BeforeEval: func(form *sexpressions.SExp, e *Env) {}}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			e.SetHooks(hooks)
+			e.SetHooks(nil)
+			e.SetStepLimit(0)
+			e.SetMemoryLimit(0)
+		}
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err := e.EvalStringContext(ctx, "(join (spawn (lambda () (dotimes (i 10000) (add i 1)))))")
+	<-done
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClearInterrupt(t *testing.T) {
+	e := NewEnv()
+	e.Interrupt()
+	e.ClearInterrupt()
+	got, err := e.EvalString("(add 1 2)")
+	if err != nil {
+		t.Fatalf("evaluation after ClearInterrupt failed: %v", err)
+	}
+	if got.String() != "3" {
+		t.Errorf("got %v, want 3", got)
+	}
+	e.Interrupt()
+	if _, err := e.EvalString("(add 1 2)"); !errors.Is(err, ErrInterrupted) {
+		t.Errorf("got error %v, want %v", err, ErrInterrupted)
+	}
+}
@@ -0,0 +1,239 @@
+package evaluator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// ioPrimitives are primitives that read input and write output.
+var ioPrimitives = map[string]builtin{
+	// (print x ...) writes the printed representations of the arguments
+	// separated by spaces and followed by a newline, in a form that can be
+	// read back.
+	"print": {0, Variadic, func(e *Env, args []*Value) (*Value, error) {
+		return printValues(e.output(), "print", args)
+	}},
+	// (eprint x ...) is like print but writes to the error output.
+	"eprint": {0, Variadic, func(e *Env, args []*Value) (*Value, error) {
+		return printValues(e.interp.stderr, "eprint", args)
+	}},
+	// (display x ...) writes the arguments without separators. Strings and
+	// characters are written as is, without quotes or #\.
+	"display": {0, Variadic, func(e *Env, args []*Value) (*Value, error) {
+		for i := range args {
+			if _, err := fmt.Fprint(e.output(), displayString(args[i])); err != nil {
+				return nil, fmt.Errorf("display: %w", err)
+			}
+		}
+		return lastOrNil(args), nil
+	}},
+	"newline": {0, 0, func(e *Env, args []*Value) (*Value, error) {
+		if _, err := fmt.Fprintln(e.output()); err != nil {
+			return nil, fmt.Errorf("newline: %w", err)
+		}
+		return Nil, nil
+	}},
+	// (read-line [port]) reads a line from port, or from the input if it is
+	// omitted, and returns it without the trailing newline, or nil at the end
+	// of the input.
+	"read-line": {0, 1, func(e *Env, args []*Value) (*Value, error) {
+		var line string
+		var ok bool
+		var err error
+		if len(args) == 1 {
+			p, perr := asPort("read-line", args[0])
+			if perr != nil {
+				return nil, perr
+			}
+			line, ok, err = p.readLine()
+		} else {
+			line, ok, err = readLine(e.interp.stdin)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read-line: %w", err)
+		}
+		if !ok {
+			return Nil, nil
+		}
+		if err := e.allocate(0, len(line)); err != nil {
+			return nil, err
+		}
+		return newStringValue(line), nil
+	}},
+	// (format dest fmt arg ...) formats args according to fmt. If dest is #t
+	// or a port, the result is written to the output or the port and nil is
+	// returned. If dest is #f or nil, the result is returned as a string.
+	"format": {2, Variadic, func(e *Env, args []*Value) (*Value, error) {
+		if !isTrue(args[0]) {
+			return formatToString(e, args)
+		}
+		str, err := formatArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		w := e.output()
+		if args[0].valueType == Port {
+			w = args[0].value.(*port)
+		} else if b, ok := args[0].AsBool(); !ok || !b {
+			return nil, newCondition(typeErrorCondition, "format destination is not #t, #f, nil or port: %v", args[0])
+		}
+		if _, err := fmt.Fprint(w, str); err != nil {
+			return nil, fmt.Errorf("format: %w", err)
+		}
+		return Nil, nil
+	}},
+}
+
+// formatPrimitives are the primitives that build strings without I/O, which
+// are overridden by ioPrimitives if I/O is allowed.
+var formatPrimitives = map[string]builtin{
+	// (format dest fmt arg ...) is format of ioPrimitives that only allows #f
+	// and nil as dest, which returns the result as a string.
+	"format": {2, Variadic, func(e *Env, args []*Value) (*Value, error) {
+		if isTrue(args[0]) {
+			return nil, newCondition(typeErrorCondition, "format destination is not #f or nil without the io capability: %v", args[0])
+		}
+		return formatToString(e, args)
+	}},
+}
+
+// formatArgs formats the arguments of format after the destination.
+func formatArgs(args []*Value) (string, error) {
+	f, ok := args[1].AsString()
+	if !ok {
+		return "", newCondition(typeErrorCondition, "format argument[1] is not string: %v", args[1])
+	}
+	str, err := format(f, args[2:])
+	if err != nil {
+		return "", fmt.Errorf("format: %w", err)
+	}
+	return str, nil
+}
+
+// formatToString returns the result of format as a string.
+func formatToString(e *Env, args []*Value) (*Value, error) {
+	str, err := formatArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.allocate(0, len(str)); err != nil {
+		return nil, err
+	}
+	return newStringValue(str), nil
+}
+
+// readLine reads a line from r without the trailing newline. ok is false at
+// the end of the input.
+func readLine(r *bufio.Reader) (line string, ok bool, err error) {
+	line, err = r.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return "", false, nil
+	}
+	if err != nil && err != io.EOF {
+		return "", false, err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	return line, true, nil
+}
+
+// printValues writes the printed representations of args to w separated by
+// spaces and followed by a newline.
+func printValues(w io.Writer, name string, args []*Value) (*Value, error) {
+	strs := make([]string, len(args))
+	for i := range args {
+		strs[i] = args[i].String()
+	}
+	if _, err := fmt.Fprintln(w, strings.Join(strs, " ")); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return lastOrNil(args), nil
+}
+
+// format interprets the directives in f:
+//
+//	~a  the next argument as by display
+//	~s  the next argument as by print
+//	~d  the next argument, which must be an integer
+//	~%  a newline
+//	~~  a tilde
+func format(f string, args []*Value) (string, error) {
+	var b strings.Builder
+	next := 0
+	nextArg := func(directive byte) (*Value, error) {
+		if next >= len(args) {
+			return nil, fmt.Errorf("no argument for ~%c", directive)
+		}
+		next++
+		return args[next-1], nil
+	}
+	for i := 0; i < len(f); i++ {
+		if f[i] != '~' {
+			b.WriteByte(f[i])
+			continue
+		}
+		i++
+		if i >= len(f) {
+			return "", fmt.Errorf("format string ends with ~")
+		}
+		switch f[i] {
+		case 'a', 'A':
+			arg, err := nextArg(f[i])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(displayString(arg))
+		case 's', 'S':
+			arg, err := nextArg(f[i])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(arg.String())
+		case 'd', 'D':
+			arg, err := nextArg(f[i])
+			if err != nil {
+				return "", err
+			}
+			if n, ok := asNumber(arg); !ok || n.isFloat {
+				return "", newCondition(typeErrorCondition, "~d argument is not integer: %v", arg)
+			}
+			b.WriteString(arg.String())
+		case '%':
+			b.WriteByte('\n')
+		case '~':
+			b.WriteByte('~')
+		default:
+			return "", fmt.Errorf("unknown directive ~%c", f[i])
+		}
+	}
+	if next < len(args) {
+		return "", fmt.Errorf("too many arguments: %v", len(args))
+	}
+	return b.String(), nil
+}
+
+// displayString returns the representation of v for display.
+func displayString(v *Value) string {
+	if s, ok := v.AsString(); ok {
+		return s
+	}
+	if c, ok := v.AsChar(); ok {
+		return string(c)
+	}
+	return v.String()
+}
+
+func lastOrNil(values []*Value) *Value {
+	if len(values) == 0 {
+		return Nil
+	}
+	return values[len(values)-1]
+}
+
+func newStringValue(s string) *Value {
+	return newSExpValue(sexpressions.NewString(s))
+}
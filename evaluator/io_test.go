@@ -0,0 +1,106 @@
+package evaluator
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestReadLine(t *testing.T) {
+	tests := []struct {
+		src   string
+		input string
+		want  string
+	}{
+		{src: "(read-line)", input: "hello\nworld\n", want: `"hello"`},
+		{src: "(list (read-line) (read-line))", input: "a\r\nb", want: `("a" "b")`},
+		{src: "(read-line)", input: "", want: "()"},
+	}
+	for _, tt := range tests {
+		e := NewEnv()
+		e.SetInput(strings.NewReader(tt.input))
+		got, err := e.EvalString(tt.src)
+		if err != nil {
+			t.Errorf("%v: %v", tt.src, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%v with input %q = %v, want %v", tt.src, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestSetInputSharesReader(t *testing.T) {
+	in := bufio.NewReader(strings.NewReader("(read-line)\nhello\nrest\n"))
+	e := NewEnv()
+	e.SetInput(in)
+	// Reading the first line buffers the rest of the input in in, which
+	// read-line must still see.
+	if line, err := in.ReadString('\n'); err != nil || line != "(read-line)\n" {
+		t.Fatalf("ReadString = %q, %v", line, err)
+	}
+	got, err := e.EvalString("(read-line)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != `"hello"` {
+		t.Errorf("(read-line) = %v, want \"hello\"", got)
+	}
+	if line, _ := in.ReadString('\n'); line != "rest\n" {
+		t.Errorf("ReadString after read-line = %q, want %q", line, "rest\n")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{src: `(format #f "~a and ~s" "x" "x")`, want: `"x and \"x\""`},
+		{src: `(format #f "~A ~S" #\a #\a)`, want: `"a #\\a"`},
+		{src: `(format nil "~d items~%" 3)`, want: `"3 items\n"`},
+		{src: `(format #f "100~~")`, want: `"100~"`},
+		{src: `(format #f "~a" '(1 "b"))`, want: `"(1 \"b\")"`},
+		{src: `(format #f "~d" 1.5)`, condition: typeErrorCondition},
+		{src: `(format #f 1)`, condition: typeErrorCondition},
+		{src: `(format 1 "x")`, condition: typeErrorCondition},
+		{src: `(format #f "~a")`, condition: errorCondition},
+		{src: `(format #f "~a" 1 2)`, condition: errorCondition},
+		{src: `(format #f "~q" 1)`, condition: errorCondition},
+		{src: `(format #f "~")`, condition: errorCondition},
+	})
+
+	var out strings.Builder
+	e := NewEnv()
+	e.SetOutput(&out)
+	got, err := e.EvalString(`(format #t "~a=~d~%" 'x 1)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != Nil || out.String() != "x=1\n" {
+		t.Errorf("format #t returned %v and wrote %q, want () and %q", got, out.String(), "x=1\n")
+	}
+}
+
+func TestSetOutput(t *testing.T) {
+	var out, errOut, other strings.Builder
+	e := NewEnv()
+	e.SetOutput(&out)
+	e.SetErrorOutput(&errOut)
+	o := NewEnv()
+	o.SetOutput(&other)
+	src := `(print 1 "a") (display "b" #\c) (newline) (write-string "d") (format #t "~a" 'e) (eprint 'oops)`
+	if _, err := e.EvalString(src); err != nil {
+		t.Fatal(err)
+	}
+	if want := "1 \"a\"\nbc\nde"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+	if want := "oops\n"; errOut.String() != want {
+		t.Errorf("error output = %q, want %q", errOut.String(), want)
+	}
+	// The output is set per interpreter.
+	if _, err := o.EvalString("(print 2)"); err != nil {
+		t.Fatal(err)
+	}
+	if other.String() != "2\n" || strings.Contains(out.String(), "2") {
+		t.Errorf("outputs after printing in another interpreter = %q and %q", out.String(), other.String())
+	}
+}
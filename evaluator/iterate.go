@@ -0,0 +1,121 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// iterationAST is (dotimes (var count [result]) body ...), which evaluates
+// body with var bound to each integer from 0 below count, or
+// (dolist (var list [result]) body ...), which evaluates body with var bound
+// to each element of list. The value is that of result, evaluated with var
+// bound to the number of iterations or nil respectively, or nil if it is
+// omitted.
+type iterationAST struct {
+	astNode
+	// name is dotimes or dolist.
+	name string
+	// frame is the layout of var in the environment of each iteration.
+	frame     *frame
+	varPos    sexpressions.Pos
+	exprAST   ast
+	resultAST ast
+	bodyASTs  []ast
+}
+
+func (a *iterationAST) dumpFields() []dumpField {
+	return []dumpField{
+		{"name", a.name},
+		{"frame", a.frame},
+		{"varPos", a.varPos},
+		{"exprAST", a.exprAST},
+		{"resultAST", a.resultAST},
+		{"bodyASTs", a.bodyASTs},
+	}
+}
+
+func (a *iterationAST) Eval(e *Env) (*Value, error) {
+	value, err := eval(e, a.exprAST)
+	if err != nil {
+		return nil, err
+	}
+	var n int
+	var item func(i int) *Value
+	last := Nil
+	if a.name == "dotimes" {
+		var ok bool
+		n, ok = value.AsInt()
+		if !ok || value.valueType != SExp {
+			return nil, newCondition(typeErrorCondition, "dotimes count is not int: %v", value)
+		}
+		item = newIntValue
+		if n < 0 {
+			n = 0
+		}
+		last = newIntValue(n)
+	} else {
+		list, err := asList("dolist", value)
+		if err != nil {
+			return nil, err
+		}
+		n = len(list)
+		item = func(i int) *Value { return newSExpValue(list[i]) }
+	}
+	for i := 0; i < n; i++ {
+		// Iterations are interruptible and counted as steps even if body
+		// is empty.
+		if err := e.checkInterrupt(); err != nil {
+			return nil, err
+		}
+		if err := e.consumeStep(); err != nil {
+			return nil, err
+		}
+		// Each iteration gets its own environment, so that lambdas made in
+		// body keep the value of the iteration.
+		iterationEnv := newFrameEnv(e, a.frame)
+		iterationEnv.slots[0] = item(i)
+		if _, err := evalSequence(iterationEnv, a.bodyASTs); err != nil {
+			return nil, err
+		}
+	}
+	if a.resultAST == nil {
+		return Nil, nil
+	}
+	resultEnv := newFrameEnv(e, a.frame)
+	resultEnv.slots[0] = last
+	return eval(resultEnv, a.resultAST)
+}
+
+// makeIterationAST makes AST for (dotimes (var count [result]) body ...) or
+// (dolist (var list [result]) body ...).
+func makeIterationAST(sexps []*sexpressions.SExp, sc *scope) (ast, error) {
+	name, _ := sexps[0].AsSymbol()
+	if len(sexps) < 2 {
+		return nil, fmt.Errorf("%v requires (var expr [result]): %+v", name, sexps)
+	}
+	spec, ok := sexps[1].AsList()
+	if !ok || len(spec) < 2 || len(spec) > 3 {
+		return nil, fmt.Errorf("%v requires (var expr [result]): %v", name, sexps[1])
+	}
+	symbol, ok := spec[0].AsSymbolObject()
+	if !ok {
+		return nil, fmt.Errorf("%v variable is not symbol: %v", name, spec[0])
+	}
+	exprAST, err := makeAST(spec[1], sc)
+	if err != nil {
+		return nil, err
+	}
+	f := &frame{symbols: []*sexpressions.Symbol{symbol}}
+	bodyScope := newScope(f, sc)
+	a := &iterationAST{name: name, frame: f, varPos: spec[0].Pos, exprAST: exprAST}
+	if len(spec) == 3 {
+		if a.resultAST, err = makeAST(spec[2], bodyScope); err != nil {
+			return nil, err
+		}
+	}
+	if a.bodyASTs, err = makeASTs(sexps[2:], bodyScope); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
@@ -0,0 +1,44 @@
+package evaluator
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIteration(t *testing.T) {
+	tests := []evalTest{
+		{src: "(let ((sum 0)) (dotimes (i 4 sum) (set! sum (add sum i))))", want: "6"},
+		{src: "(dotimes (i -1 i))", want: "0"},
+		{src: "(dotimes (i 3))", want: "()"},
+		{src: "(let ((sum 0)) (dolist (x (list 1 2 3) sum) (set! sum (add sum x))))", want: "6"},
+		{src: "(dolist (x nil x))", want: "()"},
+		{src: "(dotimes (i :a))", condition: typeErrorCondition},
+		{src: "(dolist (x 1))", condition: typeErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
+
+func TestIterationStepLimit(t *testing.T) {
+	for _, src := range []string{"(dotimes (i 1000000000000))", "(dolist (x xs))"} {
+		e := NewEnv()
+		if _, err := e.EvalString("(set xs nil) (dotimes (i 2000) (set! xs (cons i xs)))"); err != nil {
+			t.Fatal(err)
+		}
+		e.SetStepLimit(1000)
+		if _, err := e.EvalString(src); !errors.Is(err, ErrFuelExhausted) {
+			t.Errorf("%v: got error %v, want %v", src, err, ErrFuelExhausted)
+		}
+	}
+}
+
+func TestIterationInterrupt(t *testing.T) {
+	e := NewEnv()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		e.Interrupt()
+	}()
+	if _, err := e.EvalString("(dotimes (i 1000000000000))"); !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("got error %v, want %v", err, ErrInterrupted)
+	}
+}
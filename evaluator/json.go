@@ -0,0 +1,67 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// jsonPrimitives are primitives that convert values from and to JSON. See
+// sexpressions.ToJSON and sexpressions.FromJSON for how values are mapped.
+var jsonPrimitives = map[string]builtin{
+	// (json-decode str) parses str as JSON. Objects become maps with string
+	// keys, arrays become lists and null becomes nil.
+	"json-decode": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		str, ok := args[0].AsString()
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "json-decode argument is not string: %v", args[0])
+		}
+		d := json.NewDecoder(strings.NewReader(str))
+		d.UseNumber()
+		var v interface{}
+		if err := d.Decode(&v); err != nil {
+			return nil, newCondition(errorCondition, "json-decode: %v", err)
+		}
+		if d.More() {
+			return nil, newCondition(errorCondition, "json-decode: extra data after JSON value")
+		}
+		if err := e.allocate(0, len(str)); err != nil {
+			return nil, err
+		}
+		sexp, err := sexpressions.FromJSON(v)
+		if err != nil {
+			return nil, newCondition(errorCondition, "json-decode: %v", err)
+		}
+		return newSExpValue(sexp), nil
+	}},
+	// (json-encode x [indent]) returns x as a JSON string. Lists become
+	// arrays, and maps and alists become objects. If indent is given, the
+	// result is indented by the string.
+	"json-encode": {1, 2, func(e *Env, args []*Value) (*Value, error) {
+		if args[0].valueType != SExp {
+			return nil, newCondition(typeErrorCondition, "json-encode: %v can't be represented in JSON", args[0])
+		}
+		v, err := sexpressions.ToJSON(args[0].SExp)
+		if err != nil {
+			return nil, newCondition(typeErrorCondition, "json-encode: %v", err)
+		}
+		var b []byte
+		if len(args) == 2 {
+			indent, ok := args[1].AsString()
+			if !ok {
+				return nil, newCondition(typeErrorCondition, "json-encode indent is not string: %v", args[1])
+			}
+			b, err = json.MarshalIndent(v, "", indent)
+		} else {
+			b, err = json.Marshal(v)
+		}
+		if err != nil {
+			return nil, newCondition(errorCondition, "json-encode: %v", err)
+		}
+		if err := e.allocate(0, len(b)); err != nil {
+			return nil, err
+		}
+		return newStringValue(string(b)), nil
+	}},
+}
@@ -0,0 +1,14 @@
+package evaluator
+
+import "testing"
+
+func TestJSON(t *testing.T) {
+	tests := []evalTest{
+		{src: "(json-encode (json-decode \"{\\\"a\\\": [1, 2.5, true]}\"))", want: "\"{\\\"a\\\":[1,2.5,true]}\""},
+		{src: "(json-decode \"[]\")", want: "()"},
+		{src: "(json-encode '())", want: "\"[]\""},
+		{src: "(json-decode \"null\")", want: "()"},
+		{src: "(json-decode \"{\")", condition: errorCondition},
+	}
+	runEvalTests(t, tests)
+}
@@ -0,0 +1,183 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// lambdaList is a parsed parameter list of lambda.
+type lambdaList struct {
+	// args are the required positional parameters.
+	args []*sexpressions.Symbol
+	// keys are the parameters following &key, which are passed as
+	// :name value pairs after the positional arguments.
+	keys []*keyParam
+	// rest is the parameter that receives the arguments after the positional
+	// ones as a list, or nil if there is no such parameter.
+	rest *sexpressions.Symbol
+}
+
+func (l *lambdaList) dumpFields() []dumpField {
+	return []dumpField{{"args", l.args}, {"keys", l.keys}, {"rest", l.rest}}
+}
+
+// keySymbol is &key, which starts keyword parameters in lambda lists.
+var keySymbol = sexpressions.Intern("&key")
+
+type keyParam struct {
+	symbol *sexpressions.Symbol
+	// defaultAST is evaluated when the argument is not supplied. It may be nil.
+	defaultAST ast
+}
+
+func (p *keyParam) dumpFields() []dumpField {
+	return []dumpField{{"symbol", p.symbol}, {"defaultAST", p.defaultAST}}
+}
+
+// parseLambdaList parses the parameter list of lambda. It accepts
+// (a b), (a b . rest), (a &key b (c default)), and a bare symbol that
+// receives all arguments. Default values are compiled in sc, which is the
+// scope of the body.
+func parseLambdaList(sexp *sexpressions.SExp, sc *scope) (*lambdaList, error) {
+	if symbol, ok := sexp.AsSymbolObject(); ok {
+		return &lambdaList{rest: symbol}, nil
+	}
+	var params []*sexpressions.SExp
+	for {
+		if pair, ok := sexp.AsPair(); ok {
+			params = append(params, pair.Car)
+			sexp = pair.Cdr
+			continue
+		}
+		break
+	}
+	result := &lambdaList{}
+	if list, ok := sexp.AsList(); ok {
+		params = append(params, list...)
+	} else if rest, ok := sexp.AsSymbolObject(); ok && rest != keySymbol {
+		result.rest = rest
+	} else {
+		return nil, fmt.Errorf("1st argument to lambda must be a symbol or a list of symbols")
+	}
+	inKeys := false
+	for i := range params {
+		if symbol, ok := params[i].AsSymbolObject(); ok && symbol == keySymbol {
+			if inKeys {
+				return nil, fmt.Errorf("&key appears more than once in lambda list")
+			}
+			inKeys = true
+			continue
+		}
+		if !inKeys {
+			symbol, ok := params[i].AsSymbolObject()
+			if !ok {
+				return nil, fmt.Errorf("1st argument to lambda must be a symbol or a list of symbols")
+			}
+			result.args = append(result.args, symbol)
+			continue
+		}
+		key, err := parseKeyParam(params[i], sc)
+		if err != nil {
+			return nil, err
+		}
+		result.keys = append(result.keys, key)
+	}
+	return result, nil
+}
+
+// parseKeyParam parses either name or (name default).
+func parseKeyParam(sexp *sexpressions.SExp, sc *scope) (*keyParam, error) {
+	if symbol, ok := sexp.AsSymbolObject(); ok {
+		return &keyParam{symbol: symbol}, nil
+	}
+	list, ok := sexp.AsList()
+	if !ok || len(list) != 2 {
+		return nil, fmt.Errorf("&key parameter must be a symbol or (symbol default): %v", sexp)
+	}
+	symbol, ok := list[0].AsSymbolObject()
+	if !ok {
+		return nil, fmt.Errorf("&key parameter must be a symbol or (symbol default): %v", sexp)
+	}
+	defaultAST, err := makeAST(list[1], sc)
+	if err != nil {
+		return nil, err
+	}
+	return &keyParam{symbol: symbol, defaultAST: defaultAST}, nil
+}
+
+// bind sets the parameters in env to args. Default values of keyword
+// parameters are evaluated in env after the supplied arguments are bound.
+func (l *lambdaList) bind(env *Env, args []*Value) error {
+	if l.rest == nil && len(l.keys) == 0 && len(l.args) != len(args) {
+		return newCondition(arityErrorCondition, "requires %v arguments, but got %v", len(l.args), len(args))
+	}
+	if len(l.args) > len(args) {
+		return newCondition(arityErrorCondition, "requires at least %v arguments, but got %v", len(l.args), len(args))
+	}
+	for i := range l.args {
+		env.setSymbol(l.args[i], args[i])
+	}
+	rest := args[len(l.args):]
+	if l.rest != nil {
+		env.setSymbol(l.rest, newListValue(rest))
+	}
+	if len(l.keys) == 0 {
+		return nil
+	}
+
+	supplied := make(map[string]*Value)
+	if len(rest)%2 != 0 {
+		return newCondition(arityErrorCondition, "odd number of keyword arguments: %v", newListValue(rest))
+	}
+	for i := 0; i < len(rest); i += 2 {
+		keyword, ok := rest[i].AsKeyword()
+		if !ok {
+			return newCondition(arityErrorCondition, "expected keyword but got %v", rest[i])
+		}
+		if l.keyParam(keyword) == nil && l.rest == nil {
+			return newCondition(arityErrorCondition, "unknown keyword argument %v", rest[i])
+		}
+		supplied[keyword] = rest[i+1]
+	}
+	for _, key := range l.keys {
+		if value, ok := supplied[key.symbol.Name]; ok {
+			env.setSymbol(key.symbol, value)
+			continue
+		}
+		if key.defaultAST == nil {
+			env.setSymbol(key.symbol, Nil)
+			continue
+		}
+		value, err := eval(env, key.defaultAST)
+		if err != nil {
+			return err
+		}
+		env.setSymbol(key.symbol, value)
+	}
+	return nil
+}
+
+// symbols returns the symbols bound by l in the order of their slots: the
+// positional parameters, the &key parameters and the &rest one.
+func (l *lambdaList) symbols() []*sexpressions.Symbol {
+	symbols := append([]*sexpressions.Symbol{}, l.args...)
+	for _, key := range l.keys {
+		symbols = append(symbols, key.symbol)
+	}
+	if l.rest != nil {
+		symbols = append(symbols, l.rest)
+	}
+	return symbols
+}
+
+// keyParam returns the &key parameter for the keyword :name, or nil if there
+// is none.
+func (l *lambdaList) keyParam(name string) *keyParam {
+	for _, key := range l.keys {
+		if key.symbol.Name == name {
+			return key
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package evaluator
+
+import "testing"
+
+func TestRestParameters(t *testing.T) {
+	runEvalTests(t, []evalTest{
+		{src: "((lambda (a b . rest) (list a b rest)) 1 2 3 4)", want: "(1 2 (3 4))"},
+		{src: "((lambda (a . rest) rest) 1)", want: "()"},
+		{src: "((lambda args args) 1 2 3)", want: "(1 2 3)"},
+		{src: "((lambda args args))", want: "()"},
+		{src: "((lambda (a b . rest) a) 1)", condition: arityErrorCondition},
+		{src: "((lambda (a b) a) 1 2 3)", condition: arityErrorCondition},
+		{src: "(lambda (a . 1) a)", condition: syntaxErrorCondition},
+	})
+}
+
+func TestKeyParameters(t *testing.T) {
+	const f = "(define f (lambda (a &key name (size (add a 1))) (list a name size))) "
+	runEvalTests(t, []evalTest{
+		{src: f + `(f 1 :name "x" :size 3)`, want: `(1 "x" 3)`},
+		{src: f + `(f 1 :size 3 :name "x")`, want: `(1 "x" 3)`},
+		{src: f + "(f 1)", want: "(1 () 2)"},
+		{src: f + "(f 1 :name)", condition: arityErrorCondition},
+		{src: f + "(f 1 :color 2)", condition: arityErrorCondition},
+		{src: f + "(f 1 2 3)", condition: arityErrorCondition},
+		{src: "((lambda (&key a . rest) rest) :a 1 :b 2)", want: "(:a 1 :b 2)"},
+		{src: "(lambda (&key a &key b) a)", condition: syntaxErrorCondition},
+		{src: "(lambda (&key (a)) a)", condition: syntaxErrorCondition},
+	})
+}
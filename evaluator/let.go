@@ -0,0 +1,122 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// letAST is (let [name] ((var init) ...) body ...), which evaluates body with
+// each var bound to the value of its init. The inits are evaluated in order
+// outside of the scope of the vars. Named lets also bind name in body to a
+// function that evaluates body again with its arguments bound to the vars,
+// so that loops are written as recursive calls of name.
+type letAST struct {
+	astNode
+	// name is the function bound by named lets, or nil.
+	name    *sexpressions.Symbol
+	namePos sexpressions.Pos
+	// frame is the layout of the environment binding name, or nil.
+	frame     *frame
+	initASTs  []ast
+	lambdaAST ast
+}
+
+func (a *letAST) dumpFields() []dumpField {
+	return []dumpField{
+		{"name", a.name},
+		{"namePos", a.namePos},
+		{"frame", a.frame},
+		{"initASTs", a.initASTs},
+		{"lambdaAST", a.lambdaAST},
+	}
+}
+
+func (a *letAST) Eval(e *Env) (*Value, error) {
+	fn, args, err := a.evalLambda(e)
+	if err != nil {
+		return nil, err
+	}
+	value, err := apply(e, fn, args)
+	if err != nil && a.name != nil {
+		return nil, withFrame(err, Frame{Name: a.name.Name, Pos: a.form().Pos})
+	}
+	return value, err
+}
+
+// evalLambda evaluates the inits and makes the lambda of the body, which is
+// bound to the name of named lets.
+func (a *letAST) evalLambda(e *Env) (fn *Value, args []*Value, err error) {
+	for _, initAST := range a.initASTs {
+		arg, err := eval(e, initAST)
+		if err != nil {
+			return nil, nil, err
+		}
+		args = append(args, arg)
+	}
+	lambdaEnv := e
+	if a.frame != nil {
+		lambdaEnv = newFrameEnv(e, a.frame)
+	}
+	fn, err = eval(lambdaEnv, a.lambdaAST)
+	if err != nil {
+		return nil, nil, err
+	}
+	if a.name != nil {
+		lambdaEnv.setSymbol(a.name, fn)
+	}
+	return fn, args, nil
+}
+
+// makeLetAST makes AST for (let [name] ((var init) ...) body ...). The body
+// is compiled as the lambda (lambda (var ...) body ...) made when the let is
+// evaluated.
+func makeLetAST(sexps []*sexpressions.SExp, sc *scope) (ast, error) {
+	a := &letAST{}
+	rest := sexps[1:]
+	if len(rest) > 0 {
+		if name, ok := rest[0].AsSymbolObject(); ok {
+			a.name, a.namePos = name, rest[0].Pos
+			rest = rest[1:]
+		}
+	}
+	if len(rest) < 2 {
+		return nil, fmt.Errorf("let requires bindings and a body: %+v", sexps)
+	}
+	bindings, ok := rest[0].AsList()
+	if !ok {
+		return nil, fmt.Errorf("let bindings must be a list: %v", rest[0])
+	}
+	var vars []*sexpressions.SExp
+	for _, binding := range bindings {
+		pair, ok := binding.AsList()
+		if !ok || len(pair) != 2 {
+			return nil, fmt.Errorf("let binding must be (var init): %v", binding)
+		}
+		if _, ok := pair[0].AsSymbolObject(); !ok {
+			return nil, fmt.Errorf("let binding must be (var init): %v", binding)
+		}
+		initAST, err := makeAST(pair[1], sc)
+		if err != nil {
+			return nil, err
+		}
+		vars = append(vars, pair[0])
+		a.initASTs = append(a.initASTs, initAST)
+	}
+
+	lambdaScope := sc
+	if a.name != nil {
+		a.frame = &frame{symbols: []*sexpressions.Symbol{a.name}}
+		lambdaScope = newScope(a.frame, sc)
+	}
+	lambda := sexpressions.NewList(append([]*sexpressions.SExp{
+		sexpressions.NewSymbol("lambda"), sexpressions.NewList(vars...),
+	}, rest[1:]...)...)
+	lambda.Pos = sexps[0].Pos
+	lambdaAST, err := makeAST(lambda, lambdaScope)
+	if err != nil {
+		return nil, err
+	}
+	a.lambdaAST = lambdaAST
+	return a, nil
+}
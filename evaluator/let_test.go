@@ -0,0 +1,53 @@
+package evaluator
+
+import "testing"
+
+func TestLet(t *testing.T) {
+	tests := []evalTest{
+		{src: "(let ((a 1) (b 2)) (add a b))", want: "3"},
+		{src: "(let () 1)", want: "1"},
+		{src: "(let ((a 1)) (let ((a 2) (b a)) b))", want: "1"},
+		{src: "(let loop ((i 0) (acc '())) (if (< i 3) (loop (add i 1) (cons i acc)) acc))", want: "(2 1 0)"},
+		{src: "(let ((a)) a)", condition: syntaxErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []evalTest{
+		{src: "(let loop ((i 0)) (if (< i 1000000) (loop (add i 1)) i))", want: "1000000"},
+		{src: "(set f (lambda (n) (when (> n 0) (f (sub n 1))))) (f 300000)", want: "()"},
+		{src: "(set even (lambda (n) (if (= n 0) #t (odd (sub n 1))))) (set odd (lambda (n) (if (= n 0) #f (even (sub n 1))))) (even 300001)", want: "#f"},
+		{src: "(set f (lambda (n) (if (= n 0) 0 (add 1 (f (sub n 1)))))) (f 200000)", condition: stackOverflowCondition},
+	}
+	runEvalTests(t, tests)
+}
+
+func TestTailCallBacktrace(t *testing.T) {
+	_, err := NewEnv().EvalString("(let loop ((i 0)) (if (< i 10) (loop (add i 1)) (car 1)))")
+	frames := Backtrace(err)
+	if len(frames) == 0 || frames[len(frames)-1].Name != "loop" {
+		t.Errorf("got backtrace %v, want one ending with loop", frames)
+	}
+}
+
+func TestDo(t *testing.T) {
+	tests := []evalTest{
+		{src: "(do ((i 0 (add i 1)) (s 0 (add s i))) ((= i 4) s))", want: "6"},
+		{src: "(do ((i 0 (add i 1))) ((= i 3)))", want: "()"},
+		{src: "(do ((i 5)) (#t i))", want: "5"},
+		{src: "(set n 0) (do ((i 0 (add i 1))) ((= i 3) n) (set! n (add n 10)))", want: "30"},
+	}
+	runEvalTests(t, tests)
+}
+
+func TestWhen(t *testing.T) {
+	tests := []evalTest{
+		{src: "(when #f 1)", want: "()"},
+		{src: "(when #t 1 2)", want: "2"},
+		{src: "(when #t)", want: "()"},
+		{src: "(unless #f 1 2)", want: "2"},
+		{src: "(unless #t 1)", want: "()"},
+	}
+	runEvalTests(t, tests)
+}
@@ -0,0 +1,169 @@
+package evaluator
+
+import (
+	"bytes"
+	"errors"
+	"sync/atomic"
+)
+
+// ErrFuelExhausted is returned by evaluations that exceed the step limit set
+// by SetStepLimit. Like ErrInterrupted, it isn't caught by try.
+var ErrFuelExhausted = errors.New("fuel exhausted")
+
+// SetStepLimit limits the number of ASTs each call to Eval and the like may
+// evaluate, so that untrusted programs can't loop forever. Zero or less
+// means no limit, which is the default.
+func (e *Env) SetStepLimit(n int64) {
+	atomic.StoreInt64(&e.interp.stepLimit, n)
+}
+
+// enterEval marks the start of a call to Eval, and returns a function that
+// marks its end. Steps and allocations are counted from the outermost call,
+// so that nested calls by eval and load share the budget.
+func (e *Env) enterEval() (exit func()) {
+	if atomic.AddInt32(&e.interp.evalDepth, 1) == 1 {
+		atomic.StoreInt64(&e.interp.steps, 0)
+		atomic.StoreInt64(&e.interp.allocated, 0)
+	}
+	return func() {
+		atomic.AddInt32(&e.interp.evalDepth, -1)
+	}
+}
+
+// consumeStep counts an AST evaluation and fails if it exceeds the limit.
+func (e *Env) consumeStep() error {
+	if a := e.interp.loadAllowance(); a != nil {
+		if atomic.AddInt64(&a.steps, -1) < 0 {
+			return ErrFuelExhausted
+		}
+		return nil
+	}
+	steps := atomic.AddInt64(&e.interp.steps, 1)
+	if limit := atomic.LoadInt64(&e.interp.stepLimit); limit > 0 && steps > limit {
+		return ErrFuelExhausted
+	}
+	return nil
+}
+
+// ErrMemoryLimitExceeded is returned by evaluations that allocate more than
+// the limit set by SetMemoryLimit. Like ErrInterrupted, it isn't caught by
+// try.
+var ErrMemoryLimitExceeded = errors.New("memory limit exceeded")
+
+// cellSize is the approximate number of bytes each element of lists,
+// vectors, maps and structs takes.
+const cellSize = 32
+
+// SetMemoryLimit limits the approximate number of bytes of lists, vectors,
+// maps, structs, strings and bignums that primitives may allocate in each
+// call to Eval and the like. Zero or less means no limit, which is the default.
+func (e *Env) SetMemoryLimit(bytes int64) {
+	atomic.StoreInt64(&e.interp.memoryLimit, bytes)
+}
+
+// allocate accounts for the given number of cells and bytes about to be
+// allocated, and fails if they exceed the limit. Primitives call it before
+// allocating so that huge allocations fail before happening.
+func (e *Env) allocate(cells, bytes int) error {
+	if a := e.interp.loadAllowance(); a != nil {
+		if atomic.AddInt64(&a.bytes, -(int64(cells)*cellSize+int64(bytes))) < 0 {
+			return ErrMemoryLimitExceeded
+		}
+		return nil
+	}
+	allocated := atomic.AddInt64(&e.interp.allocated, int64(cells)*cellSize+int64(bytes))
+	if limit := atomic.LoadInt64(&e.interp.memoryLimit); limit > 0 && allocated > limit {
+		return ErrMemoryLimitExceeded
+	}
+	return nil
+}
+
+// allocateCells is allocate for cells only, which is the signature
+// sexpressions.ConsAlloc takes.
+func (e *Env) allocateCells(cells int) error {
+	return e.allocate(cells, 0)
+}
+
+// allocatingWriter is a buffer that accounts for the bytes written to it
+// before keeping them, so that reading huge outputs fails as soon as they
+// exceed the memory limit instead of after reading them whole.
+type allocatingWriter struct {
+	e   *Env
+	buf bytes.Buffer
+	// err is the error of the allocation that failed, after which writes
+	// fail.
+	err error
+}
+
+func (w *allocatingWriter) Write(p []byte) (int, error) {
+	if w.err == nil {
+		w.err = w.e.allocate(0, len(p))
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.buf.Write(p)
+}
+
+// maxLength is the largest number of elements primitives such as make-vector
+// allocate at once, whether or not a memory limit is set, so that huge lengths
+// fail instead of crashing the process.
+const maxLength = 1 << 26
+
+// checkLength fails with a range error if the length n of what name makes
+// exceeds maxLength.
+func checkLength(name string, n int) error {
+	if n > maxLength {
+		return newCondition(rangeErrorCondition, "%v length is too large: %v", name, n)
+	}
+	return nil
+}
+
+// allowance is the budget of the cleanup forms of unwind-protect run after
+// the protected form is stopped by an interrupt or an exceeded limit. They
+// are evaluated despite the interrupt and the limits, but only within the
+// allowance, so that they can't run forever either.
+type allowance struct {
+	// steps and bytes are the remaining numbers of ASTs that may be
+	// evaluated and bytes that may be allocated.
+	steps, bytes int64
+}
+
+// Allowances of the cleanup forms of unwind-protect.
+const (
+	cleanupSteps = 10000
+	cleanupBytes = 1 << 20
+)
+
+// setAllowance makes evaluations in e's interpreter ignore the context and
+// the limits, and be limited by a new allowance instead, until the returned
+// function is called. Nested cleanup forms share the outermost allowance so
+// that they can't renew it.
+func (e *Env) setAllowance() (restore func()) {
+	if e.interp.loadAllowance() != nil {
+		return func() {}
+	}
+	e.interp.allowance.Store(&allowance{steps: cleanupSteps, bytes: cleanupBytes})
+	return func() {
+		e.interp.allowance.Store((*allowance)(nil))
+	}
+}
+
+func (i *interpreter) loadAllowance() *allowance {
+	return i.allowance.Load().(*allowance)
+}
+
+// stopsEvaluation reports whether err stops the evaluation so that any
+// further evaluation in it fails too, which are interrupts and exceeded
+// limits.
+func stopsEvaluation(err error) bool {
+	return errors.Is(err, ErrInterrupted) || errors.Is(err, ErrFuelExhausted) || errors.Is(err, ErrMemoryLimitExceeded)
+}
+
+// isAbort reports whether err stops evaluation without being caught by try,
+// such as interrupts, exceeded limits, closing generators and exit.
+func isAbort(err error) bool {
+	var exit *ExitError
+	return errors.Is(err, ErrInterrupted) || errors.Is(err, ErrFuelExhausted) || errors.Is(err, ErrMemoryLimitExceeded) ||
+		errors.Is(err, errGeneratorClosed) || errors.As(err, &exit)
+}
@@ -0,0 +1,38 @@
+package evaluator
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestMemoryLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big")
+	if err := os.WriteFile(path, make([]byte, 1<<20), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		src  string
+		fail bool
+	}{
+		{src: "(let loop ((n 2) (i 0)) (if (< i 10) (loop (mul n n) (add i 1)) (< 0 n)))"},
+		{src: "(let loop ((n 2) (i 0)) (if (< i 20) (loop (mul n n) (add i 1)) (< 0 n)))", fail: true},
+		{src: "(let loop ((n (mul 99999999999 99999999999)) (i 0)) (if (< i 100000) (loop (add n n) (add i 1)) (< 0 n)))", fail: true},
+		{src: "(length (read-file " + strconv.Quote(path) + "))", fail: true},
+		{src: "(map-get (shell \"head -c 10000000 /dev/zero\") :exit-code)", fail: true},
+		{src: "(map-get (shell \"head -c 1000 /dev/zero\") :exit-code)"},
+	}
+	for _, test := range tests {
+		e := NewEnv()
+		e.SetMemoryLimit(100000)
+		_, err := e.EvalString(test.src)
+		if test.fail && !errors.Is(err, ErrMemoryLimitExceeded) {
+			t.Errorf("%v: got error %v, want %v", test.src, err, ErrMemoryLimitExceeded)
+		}
+		if !test.fail && err != nil {
+			t.Errorf("%v: got error %v", test.src, err)
+		}
+	}
+}
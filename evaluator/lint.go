@@ -0,0 +1,217 @@
+package evaluator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// Warning is a problem found in a program that doesn't stop it from running,
+// such as a variable that is never used.
+type Warning struct {
+	Pos     sexpressions.Pos
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%v: %v", w.Pos, w.Message)
+}
+
+// Lint checks sexp as Check does, and returns warnings about the parameters of
+// lambdas and the variables of catch clauses that shadow an enclosing one or
+// are never referenced. Variables whose names start with _ aren't reported as
+// unused. The warnings are sorted by position.
+func Lint(sexp *sexpressions.SExp) ([]Warning, error) {
+	a, err := makeAST(sexp, nil)
+	if err != nil {
+		return nil, err
+	}
+	l := &linter{}
+	l.walk(a)
+	sort.SliceStable(l.warnings, func(i, j int) bool {
+		pi, pj := l.warnings[i].Pos, l.warnings[j].Pos
+		return pi.Line < pj.Line || pi.Line == pj.Line && pi.Column < pj.Column
+	})
+	return l.warnings, nil
+}
+
+// binding is a variable in scope while linting.
+type binding struct {
+	// kind describes the variable in warnings, such as "parameter".
+	kind   string
+	symbol *sexpressions.Symbol
+	pos    sexpressions.Pos
+	used   bool
+}
+
+type linter struct {
+	// bindings are the variables in scope, innermost last.
+	bindings []*binding
+	warnings []Warning
+}
+
+func (l *linter) walk(a ast) {
+	switch a := a.(type) {
+	case *lookupAST:
+		l.use(a.symbol)
+	case *lambdaAST:
+		n := len(l.bindings)
+		for _, symbol := range a.params.symbols() {
+			l.bind("parameter", symbol, paramPos(a, symbol))
+		}
+		replaceChildren(a, func(child ast) ast {
+			l.walk(child)
+			return child
+		})
+		l.unbind(n)
+		return
+	case *tryAST:
+		for _, bodyAST := range a.bodyASTs {
+			l.walk(bodyAST)
+		}
+		for _, clause := range a.clauses {
+			n := len(l.bindings)
+			l.bind("catch variable", clause.symbol, clause.pos)
+			for _, handlerAST := range clause.handlerASTs {
+				l.walk(handlerAST)
+			}
+			l.unbind(n)
+		}
+		return
+	case *letAST:
+		for _, initAST := range a.initASTs {
+			l.walk(initAST)
+		}
+		n := len(l.bindings)
+		if a.name != nil {
+			l.bind("loop name", a.name, a.namePos)
+		}
+		l.walk(a.lambdaAST)
+		l.unbind(n)
+		return
+	case *iterationAST:
+		l.walk(a.exprAST)
+		n := len(l.bindings)
+		l.bind("loop variable", a.frame.symbols[0], a.varPos)
+		if a.resultAST != nil {
+			l.walk(a.resultAST)
+		}
+		for _, bodyAST := range a.bodyASTs {
+			l.walk(bodyAST)
+		}
+		l.unbind(n)
+		return
+	case *doAST:
+		for _, initAST := range a.initASTs {
+			l.walk(initAST)
+		}
+		n := len(l.bindings)
+		for i, symbol := range a.frame.symbols {
+			l.bind("loop variable", symbol, a.varPos[i])
+		}
+		for _, stepAST := range a.stepASTs {
+			if stepAST != nil {
+				l.walk(stepAST)
+			}
+		}
+		l.walk(a.testAST)
+		for _, resultAST := range a.resultASTs {
+			l.walk(resultAST)
+		}
+		for _, bodyAST := range a.bodyASTs {
+			l.walk(bodyAST)
+		}
+		l.unbind(n)
+		return
+	case *selectAST:
+		for _, clause := range a.clauses {
+			l.walk(clause.chanAST)
+			if clause.valueAST != nil {
+				l.walk(clause.valueAST)
+			}
+			n := len(l.bindings)
+			if clause.frame != nil {
+				for i, symbol := range clause.frame.symbols {
+					l.bind("received variable", symbol, clause.varPos[i])
+				}
+			}
+			for _, bodyAST := range clause.bodyASTs {
+				l.walk(bodyAST)
+			}
+			l.unbind(n)
+		}
+		for _, defaultAST := range a.defaultASTs {
+			l.walk(defaultAST)
+		}
+		return
+	}
+	replaceChildren(a, func(child ast) ast {
+		l.walk(child)
+		return child
+	})
+}
+
+// bind brings a variable into scope, and warns if it shadows another one.
+func (l *linter) bind(kind string, symbol *sexpressions.Symbol, pos sexpressions.Pos) {
+	if outer := l.find(symbol); outer != nil {
+		l.warn(pos, "%v %v shadows %v %v at %v", kind, symbol, outer.kind, symbol, outer.pos)
+	}
+	l.bindings = append(l.bindings, &binding{kind: kind, symbol: symbol, pos: pos})
+}
+
+// unbind removes the variables bound after the first n from scope, and warns
+// about the ones that weren't used.
+func (l *linter) unbind(n int) {
+	for _, b := range l.bindings[n:] {
+		if !b.used && !strings.HasPrefix(b.symbol.Name, "_") {
+			l.warn(b.pos, "%v %v is never used", b.kind, b.symbol)
+		}
+	}
+	l.bindings = l.bindings[:n]
+}
+
+// use marks the innermost variable named symbol as used.
+func (l *linter) use(symbol *sexpressions.Symbol) {
+	if b := l.find(symbol); b != nil {
+		b.used = true
+	}
+}
+
+// find returns the innermost variable named symbol in scope, or nil.
+func (l *linter) find(symbol *sexpressions.Symbol) *binding {
+	for i := len(l.bindings) - 1; i >= 0; i-- {
+		if l.bindings[i].symbol == symbol {
+			return l.bindings[i]
+		}
+	}
+	return nil
+}
+
+func (l *linter) warn(pos sexpressions.Pos, format string, args ...interface{}) {
+	l.warnings = append(l.warnings, Warning{Pos: pos, Message: fmt.Sprintf(format, args...)})
+}
+
+// paramPos returns the position of the parameter symbol in the lambda list of
+// a, or the position of a if it isn't found. Parameters come before the
+// default values that may refer to them, so the first occurrence is the
+// parameter itself.
+func paramPos(a *lambdaAST, symbol *sexpressions.Symbol) sexpressions.Pos {
+	pos := a.form().Pos
+	list, ok := a.form().AsList()
+	if !ok || len(list) < 2 {
+		return pos
+	}
+	found := false
+	sexpressions.Inspect(list[1], func(s *sexpressions.SExp) bool {
+		if found {
+			return false
+		}
+		if sym, ok := s.AsSymbolObject(); ok && sym == symbol {
+			pos, found = s.Pos, true
+		}
+		return true
+	})
+	return pos
+}
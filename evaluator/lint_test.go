@@ -0,0 +1,42 @@
+package evaluator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{src: "(lambda (x) x)"},
+		{src: "(lambda (x y) x)", want: []string{"1:12: parameter y is never used"}},
+		{src: "(lambda (x _y) x)"},
+		{src: "(lambda (x)\n  (lambda (x) x))", want: []string{
+			"1:10: parameter x is never used",
+			"2:12: parameter x shadows parameter x at 1:10",
+		}},
+		{src: "(lambda (x &key (y x)) y)"},
+		{src: "(try (car 1) (catch (e) 1))", want: []string{"1:22: catch variable e is never used"}},
+		{src: "(dotimes (i 3) 1)", want: []string{"1:11: loop variable i is never used"}},
+		{src: "(let ((a 1)) (add a b))"},
+	}
+	for _, tt := range tests {
+		warnings, err := Lint(parseForm(t, tt.src))
+		if err != nil {
+			t.Errorf("%v: %v", tt.src, err)
+			continue
+		}
+		var got []string
+		for _, w := range warnings {
+			got = append(got, w.String())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Lint(%v) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+	if _, err := Lint(parseForm(t, "(lambda 1)")); err == nil {
+		t.Error("Lint of a malformed form succeeded")
+	}
+}
@@ -0,0 +1,308 @@
+package evaluator
+
+import (
+	"sort"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// listPrimitives are primitives that build and take apart lists.
+var listPrimitives = map[string]builtin{
+	"cons": {2, 2, func(e *Env, args []*Value) (*Value, error) {
+		pair, err := sexpressions.ConsAlloc(toSExp(args[0]), toSExp(args[1]), e.allocateCells)
+		if err != nil {
+			return nil, err
+		}
+		return newSExpValue(pair), nil
+	}},
+	"car": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		car, _, err := carCdr("car", args[0])
+		if err != nil {
+			return nil, err
+		}
+		return car, nil
+	}},
+	"cdr": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		_, cdr, err := carCdr("cdr", args[0])
+		if err != nil {
+			return nil, err
+		}
+		return cdr, nil
+	}},
+	"list": {0, Variadic, func(e *Env, args []*Value) (*Value, error) {
+		if err := e.allocate(len(args), 0); err != nil {
+			return nil, err
+		}
+		return newListValue(args), nil
+	}},
+	"length": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		list, err := asList("length", args[0])
+		if err != nil {
+			return nil, err
+		}
+		return newIntValue(len(list)), nil
+	}},
+	"append": {0, Variadic, func(e *Env, args []*Value) (*Value, error) {
+		if len(args) == 0 {
+			return Nil, nil
+		}
+		lists := make([][]*sexpressions.SExp, len(args)-1)
+		n := 0
+		for i := range lists {
+			l, err := asList("append", args[i])
+			if err != nil {
+				return nil, err
+			}
+			lists[i] = l
+			n += len(l)
+		}
+		last := toSExp(args[len(args)-1])
+		tail, ok := last.AsList()
+		if !ok {
+			// The last argument may be a non-list, in which case the result
+			// is a dotted list ending with it.
+			if err := e.allocate(n, 0); err != nil {
+				return nil, err
+			}
+			result := last
+			for i := len(lists) - 1; i >= 0; i-- {
+				for j := len(lists[i]) - 1; j >= 0; j-- {
+					result = sexpressions.Cons(lists[i][j], result)
+				}
+			}
+			return newSExpValue(result), nil
+		}
+		if n == 0 {
+			return args[len(args)-1], nil
+		}
+		if err := e.allocate(n+len(tail), 0); err != nil {
+			return nil, err
+		}
+		result := make([]*sexpressions.SExp, 0, n+len(tail))
+		for _, l := range lists {
+			result = append(result, l...)
+		}
+		result = append(result, tail...)
+		return newSExpValue(sexpressions.NewList(result...)), nil
+	}},
+	"reverse": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		list, err := asList("reverse", args[0])
+		if err != nil {
+			return nil, err
+		}
+		if err := e.allocate(len(list), 0); err != nil {
+			return nil, err
+		}
+		reversed := make([]*Value, len(list))
+		for i := range list {
+			reversed[len(list)-1-i] = newSExpValue(list[i])
+		}
+		return newListValue(reversed), nil
+	}},
+	"nth": {2, 2, func(e *Env, args []*Value) (*Value, error) {
+		n, ok := args[0].AsInt()
+		if !ok || n < 0 {
+			return nil, newCondition(typeErrorCondition, "nth index is not non-negative int: %v", args[0])
+		}
+		list, err := asList("nth", args[1])
+		if err != nil {
+			return nil, err
+		}
+		if n >= len(list) {
+			return Nil, nil
+		}
+		return newSExpValue(list[n]), nil
+	}},
+	"last": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		list, err := asList("last", args[0])
+		if err != nil {
+			return nil, err
+		}
+		if len(list) == 0 {
+			return Nil, nil
+		}
+		return newSExpValue(list[len(list)-1]), nil
+	}},
+	"map": {2, Variadic, func(e *Env, args []*Value) (*Value, error) {
+		// With several lists, f is applied to their elements in parallel
+		// until the shortest list runs out.
+		var lists [][]*sexpressions.SExp
+		n := -1
+		for i := 1; i < len(args); i++ {
+			list, err := asList("map", args[i])
+			if err != nil {
+				return nil, err
+			}
+			lists = append(lists, list)
+			if n < 0 || len(list) < n {
+				n = len(list)
+			}
+		}
+		results := make([]*Value, n)
+		for i := 0; i < n; i++ {
+			fArgs := make([]*Value, len(lists))
+			for j := range lists {
+				fArgs[j] = newSExpValue(lists[j][i])
+			}
+			result, err := apply(e, args[0], fArgs)
+			if err != nil {
+				return nil, err
+			}
+			results[i] = result
+		}
+		if err := e.allocate(len(results), 0); err != nil {
+			return nil, err
+		}
+		return newListValue(results), nil
+	}},
+	"filter": {2, 2, func(e *Env, args []*Value) (*Value, error) {
+		list, err := asList("filter", args[1])
+		if err != nil {
+			return nil, err
+		}
+		var results []*Value
+		for i := range list {
+			elem := newSExpValue(list[i])
+			keep, err := apply(e, args[0], []*Value{elem})
+			if err != nil {
+				return nil, err
+			}
+			if isTrue(keep) {
+				results = append(results, elem)
+			}
+		}
+		if err := e.allocate(len(results), 0); err != nil {
+			return nil, err
+		}
+		return newListValue(results), nil
+	}},
+	// (reduce f init list) folds list from the left, computing
+	// (f (f init x0) x1) and so on.
+	"reduce": {3, 3, func(e *Env, args []*Value) (*Value, error) {
+		list, err := asList("reduce", args[2])
+		if err != nil {
+			return nil, err
+		}
+		acc := args[1]
+		for i := range list {
+			acc, err = apply(e, args[0], []*Value{acc, newSExpValue(list[i])})
+			if err != nil {
+				return nil, err
+			}
+		}
+		return acc, nil
+	}},
+	// (sort list less) returns a new list sorted by less, which is called
+	// with 2 elements and returns true if the 1st must come first.
+	"sort": {2, 2, func(e *Env, args []*Value) (*Value, error) {
+		list, err := asList("sort", args[0])
+		if err != nil {
+			return nil, err
+		}
+		values := make([]*Value, len(list))
+		for i := range list {
+			values[i] = newSExpValue(list[i])
+		}
+		var lessErr error
+		sort.SliceStable(values, func(i, j int) bool {
+			if lessErr != nil {
+				return false
+			}
+			less, err := apply(e, args[1], []*Value{values[i], values[j]})
+			if err != nil {
+				lessErr = err
+				return false
+			}
+			return isTrue(less)
+		})
+		if lessErr != nil {
+			return nil, lessErr
+		}
+		if err := e.allocate(len(values), 0); err != nil {
+			return nil, err
+		}
+		return newListValue(values), nil
+	}},
+	"assoc": {2, 2, func(e *Env, args []*Value) (*Value, error) {
+		entry, _, err := assoc("assoc", args[0], args[1])
+		if err != nil {
+			return nil, err
+		}
+		return entry, nil
+	}},
+	"acons": {3, 3, func(e *Env, args []*Value) (*Value, error) {
+		entry, err := sexpressions.ConsAlloc(toSExp(args[0]), toSExp(args[1]), e.allocateCells)
+		if err != nil {
+			return nil, err
+		}
+		alist, err := sexpressions.ConsAlloc(entry, toSExp(args[2]), e.allocateCells)
+		if err != nil {
+			return nil, err
+		}
+		return newSExpValue(alist), nil
+	}},
+	// (alist-get key alist [default]) returns the cdr of the entry for key,
+	// or default (nil if omitted) if there is no such entry.
+	"alist-get": {2, 3, func(e *Env, args []*Value) (*Value, error) {
+		entry, cdr, err := assoc("alist-get", args[0], args[1])
+		if err != nil {
+			return nil, err
+		}
+		if entry == Nil {
+			if len(args) == 3 {
+				return args[2], nil
+			}
+			return Nil, nil
+		}
+		return cdr, nil
+	}},
+}
+
+// assoc finds the first entry of alist whose car is equal to key. It returns
+// the entry and its cdr, or Nil if there is no such entry.
+func assoc(name string, key, alist *Value) (entry, cdr *Value, err error) {
+	list, err := asList(name, alist)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range list {
+		entry := newSExpValue(list[i])
+		car, cdr, err := carCdr(name, entry)
+		if err != nil || entry.IsNil() {
+			return nil, nil, newCondition(typeErrorCondition, "%v: element of alist is not pair: %v", name, entry)
+		}
+		if toSExp(car).Equal(toSExp(key)) {
+			return entry, cdr, nil
+		}
+	}
+	return Nil, Nil, nil
+}
+
+// asList returns the elements of v, which must be a proper list. name is
+// used in the error message.
+func asList(name string, v *Value) ([]*sexpressions.SExp, error) {
+	list, ok := v.AsList()
+	if !ok {
+		return nil, newCondition(typeErrorCondition, "%v argument is not list: %v", name, v)
+	}
+	return list, nil
+}
+
+// carCdr splits v into its first element and the rest. name is used in the
+// error message. Both car and cdr of nil are nil.
+func carCdr(name string, v *Value) (car, cdr *Value, err error) {
+	if pair, ok := v.AsPair(); ok {
+		return newSExpValue(pair.Car), newSExpValue(pair.Cdr), nil
+	}
+	list, ok := v.AsList()
+	if !ok {
+		return nil, nil, newCondition(typeErrorCondition, "%v argument is not list: %v", name, v)
+	}
+	if len(list) == 0 {
+		return Nil, Nil, nil
+	}
+	if len(list) == 1 {
+		return newSExpValue(list[0]), Nil, nil
+	}
+	return newSExpValue(list[0]), newSExpValue(sexpressions.NewList(list[1:]...)), nil
+}
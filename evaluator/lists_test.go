@@ -0,0 +1,91 @@
+package evaluator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConsMemory(t *testing.T) {
+	e := NewEnv()
+	e.SetMemoryLimit(200000 * cellSize)
+	got, err := e.EvalString("(define l nil) (dotimes (i 40000) (set! l (cons i l))) (length l)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != "40000" {
+		t.Errorf("got %v, want 40000", got)
+	}
+	// Consing onto a list that can't be shared copies it each time.
+	_, err = e.EvalString("(define m (map (lambda (x) x) l)) (dotimes (i 100) (cons i m))")
+	if !errors.Is(err, ErrMemoryLimitExceeded) {
+		t.Errorf("got error %v, want %v", err, ErrMemoryLimitExceeded)
+	}
+}
+
+func TestCarCdr(t *testing.T) {
+	tests := []evalTest{
+		{src: "(car '(1 2))", want: "1"},
+		{src: "(cdr '(1 2))", want: "(2)"},
+		{src: "(cdr (cons 1 2))", want: "2"},
+		{src: "(car '())", want: "()"},
+		{src: "(cdr '())", want: "()"},
+		{src: "(car 1)", condition: typeErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
+
+func TestLists(t *testing.T) {
+	tests := []evalTest{
+		{src: "(length '())", want: "0"},
+		{src: "(length '(1 2))", want: "2"},
+		{src: "(reverse '(1 2 3))", want: "(3 2 1)"},
+		{src: "(append)", want: "()"},
+		{src: "(append '() '(1) '(2 3))", want: "(1 2 3)"},
+		{src: "(append '(1) '(2) 3)", want: "(1 2 . 3)"},
+		{src: "(append '() 3)", want: "3"},
+		{src: "(append '(1 2) '())", want: "(1 2)"},
+		{src: "(append 1 '(2))", condition: typeErrorCondition},
+		{src: "(nth 1 '(1 2))", want: "2"},
+		{src: "(nth 5 '(1 2))", want: "()"},
+		{src: "(nth -1 '(1 2))", condition: typeErrorCondition},
+		{src: "(last '(1 2))", want: "2"},
+		{src: "(last '())", want: "()"},
+	}
+	runEvalTests(t, tests)
+}
+
+func TestListFunctions(t *testing.T) {
+	tests := []evalTest{
+		{src: "(map (lambda (x) (add x 1)) '())", want: "()"},
+		{src: "(map add '(1 2) '(10 20 30))", want: "(11 22)"},
+		{src: "(filter (lambda (x) (< x 2)) '(1 2 0))", want: "(1 0)"},
+		{src: "(reduce add 0 '())", want: "0"},
+		{src: "(reduce add 0 '(1 2 3))", want: "6"},
+		{src: "(map 1 '(1))", condition: typeErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
+
+func TestSort(t *testing.T) {
+	tests := []evalTest{
+		{src: "(sort '(3 1 2) <)", want: "(1 2 3)"},
+		{src: "(sort '(3 1 2) >)", want: "(3 2 1)"},
+		{src: "(sort '((b . 1) (a . 1) (c . 0)) (lambda (x y) (< (cdr x) (cdr y))))", want: "((c . 0) (b . 1) (a . 1))"},
+		{src: "(sort '() <)", want: "()"},
+		{src: "(sort < '(3 1 2))", condition: typeErrorCondition},
+		{src: "(sort '(1 a) <)", condition: typeErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
+
+func TestAlists(t *testing.T) {
+	tests := []evalTest{
+		{src: "(assoc 'b '((a 1) (b 2)))", want: "(b 2)"},
+		{src: "(assoc 'c '())", want: "()"},
+		{src: "(assoc 'a '(1))", condition: typeErrorCondition},
+		{src: "(acons 'a 1 '())", want: "((a . 1))"},
+		{src: "(alist-get 'a '((a . 1)))", want: "1"},
+		{src: "(alist-get 'c '((a . 1)) 9)", want: "9"},
+	}
+	runEvalTests(t, tests)
+}
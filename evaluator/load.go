@@ -0,0 +1,192 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/soishi1/toylisp/parser"
+	"github.com/soishi1/toylisp/tokenizer"
+)
+
+// EvalString reads all s-expressions in src and evaluates them in e in order.
+// It returns the value of the last one, or nil if src has none.
+func (e *Env) EvalString(src string) (*Value, error) {
+	tokens, err := tokenizer.Tokenize(src)
+	if err != nil {
+		return nil, newCondition(syntaxErrorCondition, "%v", err)
+	}
+	sexps, err := parser.Parse(tokens)
+	if err != nil {
+		return nil, newCondition(syntaxErrorCondition, "%v", err)
+	}
+	defer e.enterEval()()
+	result := Nil
+	for _, sexp := range sexps {
+		result, err = e.Eval(sexp)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// EvalSource is like EvalString, but positions in errors are reported as in
+// the file named name.
+func (e *Env) EvalSource(name, src string) (*Value, error) {
+	result, err := e.EvalString(src)
+	if err != nil {
+		return nil, withFile(err, name)
+	}
+	return result, nil
+}
+
+// EvalReader is like EvalSource, but reads the s-expressions from r one at a
+// time and evaluates each as soon as it is read, so that the input isn't read
+// into memory all at once. The ones before a syntax error are evaluated.
+func (e *Env) EvalReader(name string, r io.Reader) (*Value, error) {
+	result, err := e.evalReader(r)
+	if err != nil {
+		return nil, withFile(err, name)
+	}
+	return result, nil
+}
+
+func (e *Env) evalReader(r io.Reader) (*Value, error) {
+	reader := parser.NewReader(r)
+	defer e.enterEval()()
+	result := Nil
+	for {
+		sexp, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+		var readErr *parser.ReadError
+		if errors.As(err, &readErr) {
+			return nil, newCondition(errorCondition, "read: %v", readErr.Err)
+		}
+		if err != nil {
+			return nil, newCondition(syntaxErrorCondition, "%v", err)
+		}
+		result, err = e.Eval(sexp)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// LoadFile evaluates the file at path in e.
+func (e *Env) LoadFile(path string) (*Value, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, newCondition(errorCondition, "load: %v", err)
+	}
+	defer f.Close()
+	result, err := e.EvalReader(path, f)
+	if err != nil {
+		var positioned *PositionError
+		if errors.As(err, &positioned) && positioned.File == path {
+			return nil, err
+		}
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+	return result, nil
+}
+
+// loadPrimitives are primitives that evaluate code in files.
+var loadPrimitives = map[string]builtin{
+	// (load path) evaluates the file at path at the top level, like eval,
+	// and returns the value of its last expression.
+	"load": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		path, ok := args[0].AsString()
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "load argument is not string: %v", args[0])
+		}
+		return e.topLevel().LoadFile(path)
+	}},
+	// (require name) loads name.lisp from the load path unless it has already
+	// been loaded, and returns name. name is a symbol or a string.
+	"require": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		name, ok := args[0].AsSymbol()
+		if !ok {
+			name, ok = args[0].AsString()
+		}
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "require argument is not symbol or string: %v", args[0])
+		}
+		if err := e.require(name); err != nil {
+			return nil, err
+		}
+		return args[0], nil
+	}},
+}
+
+// LoadPathEnvVar is the environment variable that lists directories require
+// searches, separated by os.PathListSeparator.
+const LoadPathEnvVar = "TOYLISP_PATH"
+
+func defaultLoadPath() []string {
+	return append([]string{"."}, filepath.SplitList(os.Getenv(LoadPathEnvVar))...)
+}
+
+// SetLoadPath sets the directories require searches in order. By default they
+// are the current directory followed by the ones in $TOYLISP_PATH.
+func (e *Env) SetLoadPath(dirs []string) {
+	e.interp.requireMu.Lock()
+	defer e.interp.requireMu.Unlock()
+	e.interp.loadPath = append([]string(nil), dirs...)
+}
+
+// findInLoadPath returns the path of name.lisp in the first directory of the
+// load path that contains it.
+func (e *Env) findInLoadPath(name string) (string, error) {
+	e.interp.requireMu.Lock()
+	dirs := e.interp.loadPath
+	e.interp.requireMu.Unlock()
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name+".lisp")
+		if _, err := os.Stat(path); err == nil {
+			return filepath.Abs(path)
+		}
+	}
+	return "", fmt.Errorf("%s.lisp not found in load path %v", name, dirs)
+}
+
+// require loads name.lisp in the top-level environment unless it has already
+// been loaded.
+func (e *Env) require(name string) error {
+	path, err := e.findInLoadPath(name)
+	if err != nil {
+		return newCondition(errorCondition, "require %s: %v", name, err)
+	}
+	e.interp.requireMu.Lock()
+	done, ok := e.interp.required[path]
+	if !ok {
+		e.interp.required[path] = false
+	}
+	e.interp.requireMu.Unlock()
+	if ok && done {
+		return nil
+	}
+	if ok {
+		return newCondition(errorCondition, "require %s: circular dependency on %s", name, path)
+	}
+
+	root := e
+	for root.parent != nil {
+		root = root.parent
+	}
+	_, err = root.LoadFile(path)
+
+	e.interp.requireMu.Lock()
+	defer e.interp.requireMu.Unlock()
+	if err != nil {
+		// Forget the failed attempt so that it can be retried after fixing it.
+		delete(e.interp.required, path)
+		return fmt.Errorf("require %s: %w", name, err)
+	}
+	e.interp.required[path] = true
+	return nil
+}
@@ -0,0 +1,103 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestEvalReader(t *testing.T) {
+	e := NewEnv()
+	got, err := e.EvalReader("", iotest.OneByteReader(strings.NewReader("(set x 1)\n(add x\n  2)")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != "3" {
+		t.Errorf("got %v, want 3", got)
+	}
+	// The forms before a syntax error are evaluated.
+	_, err = e.EvalReader("in.lisp", strings.NewReader("(set y 1) (y"))
+	if conditionType(err) != syntaxErrorCondition {
+		t.Errorf("got error %v, want %v", err, syntaxErrorCondition)
+	}
+	if y, ok := e.Lookup("y"); !ok || y.String() != "1" {
+		t.Errorf("y = %v, want 1", y)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.lisp")
+	if err := os.WriteFile(path, []byte("(set x 1)\n\n(car x)"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := NewEnv().LoadFile(path)
+	var positioned *PositionError
+	if !errors.As(err, &positioned) || positioned.File != path || positioned.Pos.Line != 3 {
+		t.Errorf("got error %v, want one at %v:3", err, path)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.lisp")
+	if err := os.WriteFile(lib, []byte("(define double (lambda (x) (mul x 2)))\n(double 21)\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runEvalTests(t, []evalTest{
+		{src: fmt.Sprintf("(load %q)", lib), want: "42"},
+		{src: fmt.Sprintf("(load %q) (double 5)", lib), want: "10"},
+		{src: fmt.Sprintf("(load %q)", filepath.Join(dir, "missing.lisp")), condition: errorCondition},
+		{src: "(load 'lib)", condition: typeErrorCondition},
+	})
+}
+
+func TestRequire(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	files := map[string]string{
+		filepath.Join(first, "counter.lisp"):  "(set loads (add loads 1))\n",
+		filepath.Join(second, "counter.lisp"): "(set loads 100)\n",
+		filepath.Join(second, "env.lisp"):     "(define from-env #t)\n",
+		filepath.Join(first, "broken.lisp"):   "(require 'dep)\n",
+		filepath.Join(first, "dep.lisp"):      "(car 1)\n",
+		filepath.Join(first, "loop.lisp"):     "(require 'loop)\n",
+	}
+	for path, src := range files {
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := NewEnv()
+	e.SetLoadPath([]string{first, second})
+	got, err := e.EvalString("(define loads 0) (require 'counter) (require \"counter\") loads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// counter.lisp is loaded once, from the first directory of the path.
+	if got.String() != "1" {
+		t.Errorf("loads = %v, want 1", got)
+	}
+
+	_, err = e.EvalString("(require 'broken)")
+	if err == nil || !strings.Contains(err.Error(), "require broken") || !strings.Contains(err.Error(), "dep.lisp") {
+		t.Errorf("got error %v, want one naming broken and dep.lisp", err)
+	}
+	if _, err := e.EvalString("(require 'loop)"); err == nil || !strings.Contains(err.Error(), "circular") {
+		t.Errorf("got error %v, want a circular dependency", err)
+	}
+	if _, err := e.EvalString("(require 1)"); conditionType(err) != typeErrorCondition {
+		t.Errorf("got error %v, want %v", err, typeErrorCondition)
+	}
+	if _, err := e.EvalString("(require 'missing)"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("got error %v, want not found", err)
+	}
+
+	t.Setenv(LoadPathEnvVar, second)
+	if _, err := NewEnv().EvalString("(require 'env) from-env"); err != nil {
+		t.Errorf("require from %v: %v", LoadPathEnvVar, err)
+	}
+}
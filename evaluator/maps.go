@@ -0,0 +1,81 @@
+package evaluator
+
+import (
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// mapPrimitives are primitives that operate on hash maps.
+var mapPrimitives = map[string]builtin{
+	// (make-map [alist]) returns a new map, optionally filled with the
+	// entries of alist.
+	"make-map": {0, 1, func(e *Env, args []*Value) (*Value, error) {
+		m := sexpressions.NewMap()
+		if len(args) == 1 {
+			list, err := asList("make-map", args[0])
+			if err != nil {
+				return nil, err
+			}
+			if err := e.allocate(len(list), 0); err != nil {
+				return nil, err
+			}
+			for i := range list {
+				car, cdr, err := carCdr("make-map", newSExpValue(list[i]))
+				if err != nil || list[i].IsNil() {
+					return nil, newCondition(typeErrorCondition, "make-map: element of alist is not pair: %v", list[i])
+				}
+				m.Set(toSExp(car), toSExp(cdr))
+			}
+		}
+		return newSExpValue(sexpressions.NewMapSExp(m)), nil
+	}},
+	// (map-get map key [default]) returns the value for key, or default (nil
+	// if omitted) if there is no such key.
+	"map-get": {2, 3, func(e *Env, args []*Value) (*Value, error) {
+		m, err := asMap("map-get", args[0])
+		if err != nil {
+			return nil, err
+		}
+		value, ok := m.Get(toSExp(args[1]))
+		if ok {
+			return newSExpValue(value), nil
+		}
+		if len(args) == 3 {
+			return args[2], nil
+		}
+		return Nil, nil
+	}},
+	"map-set": {3, 3, func(e *Env, args []*Value) (*Value, error) {
+		m, err := asMap("map-set", args[0])
+		if err != nil {
+			return nil, err
+		}
+		if err := e.allocate(1, 0); err != nil {
+			return nil, err
+		}
+		m.Set(toSExp(args[1]), toSExp(args[2]))
+		return args[2], nil
+	}},
+	"map-keys": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		m, err := asMap("map-keys", args[0])
+		if err != nil {
+			return nil, err
+		}
+		if err := e.allocate(m.Len(), 0); err != nil {
+			return nil, err
+		}
+		keys := m.Keys()
+		values := make([]*Value, len(keys))
+		for i := range keys {
+			values[i] = newSExpValue(keys[i])
+		}
+		return newListValue(values), nil
+	}},
+}
+
+func asMap(name string, v *Value) (*sexpressions.Map, error) {
+	m, ok := v.AsMap()
+	if !ok {
+		return nil, newCondition(typeErrorCondition, "%v argument is not map: %v", name, v)
+	}
+	return m, nil
+}
@@ -0,0 +1,16 @@
+package evaluator
+
+import "testing"
+
+func TestMaps(t *testing.T) {
+	tests := []evalTest{
+		{src: "(map-get (make-map) 'a)", want: "()"},
+		{src: "(map-get (make-map) 'a 0)", want: "0"},
+		{src: "(map-get (make-map '((a . 1))) 'a)", want: "1"},
+		{src: "(map-keys (make-map))", want: "()"},
+		{src: "(let ((m (make-map))) (map-set m 'a 1) (map-get m 'a))", want: "1"},
+		{src: "(let ((m (make-map))) (map-set m '(1 2) 3) (map-get m (list 1 2)))", want: "3"},
+		{src: "(map-get 1 'a)", condition: typeErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
@@ -0,0 +1,193 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// module is a namespace defined by (module name body ...). Definitions in
+// body land in the module's own environment, and only the exported ones are
+// visible from outside through import or module:name.
+type module struct {
+	name    string
+	env     *Env
+	exports map[*sexpressions.Symbol]bool
+}
+
+// lookup returns the value of symbol if the module exports it.
+func (m *module) lookup(symbol *sexpressions.Symbol) (*Value, bool) {
+	m.env.mu.RLock()
+	defer m.env.mu.RUnlock()
+	if !m.exports[symbol] {
+		return nil, false
+	}
+	value, ok := m.env.vars[symbol]
+	return value, ok
+}
+
+// exported returns the symbols exported by the module.
+func (m *module) exported() []*sexpressions.Symbol {
+	m.env.mu.RLock()
+	defer m.env.mu.RUnlock()
+	symbols := make([]*sexpressions.Symbol, 0, len(m.exports))
+	for symbol := range m.exports {
+		symbols = append(symbols, symbol)
+	}
+	return symbols
+}
+
+func (e *Env) findModule(name string) (*module, bool) {
+	e.interp.modulesMu.Lock()
+	defer e.interp.modulesMu.Unlock()
+	m, ok := e.interp.modules[name]
+	return m, ok
+}
+
+// lookupQualified looks up a symbol of the form module:name.
+func (e *Env) lookupQualified(symbol *sexpressions.Symbol) (*Value, bool) {
+	i := strings.Index(symbol.Name, ":")
+	if i <= 0 {
+		return nil, false
+	}
+	m, ok := e.findModule(symbol.Name[:i])
+	if !ok {
+		return nil, false
+	}
+	name, ok := sexpressions.Interned(symbol.Name[i+1:])
+	if !ok {
+		return nil, false
+	}
+	return m.lookup(name)
+}
+
+type moduleAST struct {
+	astNode
+	name     string
+	bodyASTs []ast
+}
+
+func (a *moduleAST) dumpFields() []dumpField {
+	return []dumpField{{"name", a.name}, {"bodyASTs", a.bodyASTs}}
+}
+
+func (a *moduleAST) Eval(e *Env) (*Value, error) {
+	m := &module{
+		name:    a.name,
+		env:     newEnvWithParent(e),
+		exports: make(map[*sexpressions.Symbol]bool),
+	}
+	m.env.module = m
+	if _, err := evalSequence(m.env, a.bodyASTs); err != nil {
+		return nil, err
+	}
+	e.interp.modulesMu.Lock()
+	e.interp.modules[a.name] = m
+	e.interp.modulesMu.Unlock()
+	return newSExpValue(sexpressions.NewSymbol(a.name)), nil
+}
+
+// makeModuleAST makes AST for (module name body ...).
+func makeModuleAST(sexps []*sexpressions.SExp, sc *scope) (ast, error) {
+	if len(sexps) < 2 {
+		return nil, fmt.Errorf("module requires at least 1 arg: %+v", sexps)
+	}
+	name, ok := sexps[1].AsSymbol()
+	if !ok {
+		return nil, fmt.Errorf("1st argument to module must be a symbol: %+v", sexps)
+	}
+	// The body is evaluated in the environment of the module, whose
+	// variables are all defined by set.
+	bodyASTs, err := makeASTs(sexps[2:], newScope(nil, sc))
+	if err != nil {
+		return nil, err
+	}
+	return &moduleAST{name: name, bodyASTs: bodyASTs}, nil
+}
+
+type exportAST struct {
+	astNode
+	symbols []*sexpressions.Symbol
+}
+
+func (a *exportAST) dumpFields() []dumpField {
+	return []dumpField{{"symbols", a.symbols}}
+}
+
+func (a *exportAST) Eval(e *Env) (*Value, error) {
+	for cursor := e; cursor != nil; cursor = cursor.parent {
+		if cursor.module == nil {
+			continue
+		}
+		cursor.mu.Lock()
+		for _, symbol := range a.symbols {
+			cursor.module.exports[symbol] = true
+		}
+		cursor.mu.Unlock()
+		return Nil, nil
+	}
+	return nil, newCondition(errorCondition, "export used outside of module")
+}
+
+// makeExportAST makes AST for (export name ...).
+func makeExportAST(sexps []*sexpressions.SExp) (ast, error) {
+	a := &exportAST{}
+	for _, sexp := range sexps[1:] {
+		symbol, ok := sexp.AsSymbolObject()
+		if !ok {
+			return nil, fmt.Errorf("arguments to export must be symbols: %+v", sexps)
+		}
+		a.symbols = append(a.symbols, symbol)
+	}
+	return a, nil
+}
+
+type importAST struct {
+	astNode
+	name string
+}
+
+func (a *importAST) dumpFields() []dumpField {
+	return []dumpField{{"name", a.name}}
+}
+
+func (a *importAST) Eval(e *Env) (*Value, error) {
+	m, ok := e.findModule(a.name)
+	if !ok {
+		return nil, newCondition(errorCondition, "unknown module %v", a.name)
+	}
+	e.mu.Lock()
+	e.imports = withImport(e.imports, m)
+	e.mu.Unlock()
+	return newSExpValue(sexpressions.NewSymbol(a.name)), nil
+}
+
+// withImport returns imports with m added. Importing a module again, or a
+// module redefined with the same name, replaces the earlier import in place,
+// so that evaluating an import repeatedly doesn't grow the list. imports
+// isn't modified, since importedModules returns it to readers without the
+// lock.
+func withImport(imports []*module, m *module) []*module {
+	result := make([]*module, len(imports), len(imports)+1)
+	copy(result, imports)
+	for i, imported := range result {
+		if imported.name == m.name {
+			result[i] = m
+			return result
+		}
+	}
+	return append(result, m)
+}
+
+// makeImportAST makes AST for (import name).
+func makeImportAST(sexps []*sexpressions.SExp) (ast, error) {
+	if len(sexps) != 2 {
+		return nil, fmt.Errorf("import requires 1 arg: %+v", sexps)
+	}
+	name, ok := sexps[1].AsSymbol()
+	if !ok {
+		return nil, fmt.Errorf("1st argument to import must be a symbol: %+v", sexps)
+	}
+	return &importAST{name: name}, nil
+}
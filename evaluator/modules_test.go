@@ -0,0 +1,28 @@
+package evaluator
+
+import "testing"
+
+func TestImport(t *testing.T) {
+	tests := []evalTest{
+		{src: "(module m (export x) (set x 1)) (import m) x", want: "1"},
+		{src: "(module m (export x) (set x 1)) (import m) (import m) (import m) x", want: "1"},
+		{src: "(module m (export x) (set x 1)) (import m) (module m (export x) (set x 2)) (import m) x", want: "2"},
+		{src: "(module a (export x) (set x 1)) (module b (export x) (set x 2)) (import a) (import b) (import a) x", want: "1"},
+	}
+	runEvalTests(t, tests)
+}
+
+func TestImportRepeatedly(t *testing.T) {
+	e := NewEnv()
+	if _, err := e.EvalString("(module m (export x) (set x 1))"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := e.EvalString("(import m)"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(e.importedModules()); n != 1 {
+		t.Errorf("got %v imports, want 1", n)
+	}
+}
@@ -0,0 +1,339 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+	"math/bits"
+	"strconv"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// numberPrimitives are arithmetic and comparison primitives.
+var numberPrimitives = map[string]builtin{
+	"add": {0, Variadic, func(e *Env, args []*Value) (*Value, error) {
+		sum := number{}
+		for i := range args {
+			x, ok := asNumber(args[i])
+			if !ok {
+				return nil, newCondition(typeErrorCondition, "add argument[%v] is not number: %v", i, args[i])
+			}
+			if err := e.allocateBignum(sum, x, addBits(sum, x)); err != nil {
+				return nil, err
+			}
+			sum = addNumbers(sum, x)
+		}
+		return sum.value(), nil
+	}},
+	// (sub x) returns -x, and (sub x y z) returns x - y - z.
+	"sub": {1, Variadic, func(e *Env, args []*Value) (*Value, error) {
+		numbers, err := asNumbers("sub", args)
+		if err != nil {
+			return nil, err
+		}
+		if len(numbers) == 1 {
+			return subNumbers(number{}, numbers[0]).value(), nil
+		}
+		result := numbers[0]
+		for _, x := range numbers[1:] {
+			if err := e.allocateBignum(result, x, addBits(result, x)); err != nil {
+				return nil, err
+			}
+			result = subNumbers(result, x)
+		}
+		return result.value(), nil
+	}},
+	"mul": {0, Variadic, func(e *Env, args []*Value) (*Value, error) {
+		numbers, err := asNumbers("mul", args)
+		if err != nil {
+			return nil, err
+		}
+		result := number{i: 1}
+		for _, x := range numbers {
+			if err := e.allocateBignum(result, x, result.bitLen()+x.bitLen()); err != nil {
+				return nil, err
+			}
+			result = mulNumbers(result, x)
+		}
+		return result.value(), nil
+	}},
+	// (div x y z) returns x / y / z. Division of ints truncates toward zero.
+	"div": {2, Variadic, func(e *Env, args []*Value) (*Value, error) {
+		numbers, err := asNumbers("div", args)
+		if err != nil {
+			return nil, err
+		}
+		result := numbers[0]
+		for _, x := range numbers[1:] {
+			result, err = divNumbers(result, x)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return result.value(), nil
+	}},
+	// (mod x y) returns x modulo y, which has the same sign as y.
+	"mod": {2, 2, func(e *Env, args []*Value) (*Value, error) {
+		numbers, err := asNumbers("mod", args)
+		if err != nil {
+			return nil, err
+		}
+		result, err := modNumbers(numbers[0], numbers[1])
+		if err != nil {
+			return nil, err
+		}
+		return result.value(), nil
+	}},
+	"=":  {1, Variadic, makeComparison("=", func(c int) bool { return c == 0 })},
+	"<":  {1, Variadic, makeComparison("<", func(c int) bool { return c < 0 })},
+	">":  {1, Variadic, makeComparison(">", func(c int) bool { return c > 0 })},
+	"<=": {1, Variadic, makeComparison("<=", func(c int) bool { return c <= 0 })},
+	">=": {1, Variadic, makeComparison(">=", func(c int) bool { return c >= 0 })},
+}
+
+// asNumbers converts args to numbers.
+func asNumbers(name string, args []*Value) ([]number, error) {
+	numbers := make([]number, len(args))
+	for i := range args {
+		var ok bool
+		numbers[i], ok = asNumber(args[i])
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "%v argument[%v] is not number: %v", name, i, args[i])
+		}
+	}
+	return numbers, nil
+}
+
+// makeComparison returns a primitive that returns #t if ok holds for the
+// comparison result of every adjacent pair of arguments, as in (< 1 2 3).
+func makeComparison(name string, ok func(c int) bool) PrimitiveFunc {
+	return func(e *Env, args []*Value) (*Value, error) {
+		numbers, err := asNumbers(name, args)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i+1 < len(numbers); i++ {
+			c, comparable := compareNumbers(numbers[i], numbers[i+1])
+			if !comparable || !ok(c) {
+				return False, nil
+			}
+		}
+		return True, nil
+	}
+}
+
+// number is a numeric value used by arithmetic primitives. It is a float if
+// isFloat is true, a bignum if big is not nil, and an int otherwise.
+// Arithmetic on ints is promoted to bignums on overflow, and bignums are
+// demoted back to ints when they fit.
+type number struct {
+	i       int
+	f       float64
+	isFloat bool
+	big     *big.Int
+}
+
+func asNumber(v *Value) (n number, ok bool) {
+	if i, ok := v.AsInt(); ok {
+		return number{i: i}, true
+	}
+	if f, ok := v.AsFloat(); ok {
+		return number{f: f, isFloat: true}, true
+	}
+	if b, ok := v.AsBigInt(); ok {
+		return number{big: b}, true
+	}
+	return number{}, false
+}
+
+func (n number) float() float64 {
+	if n.isFloat {
+		return n.f
+	}
+	if n.big != nil {
+		f, _ := new(big.Float).SetInt(n.big).Float64()
+		return f
+	}
+	return float64(n.i)
+}
+
+// bigInt returns n as a big.Int. n must not be a float.
+func (n number) bigInt() *big.Int {
+	if n.big != nil {
+		return n.big
+	}
+	return big.NewInt(int64(n.i))
+}
+
+// bitLen returns the number of bits of the absolute value of n, which must
+// not be a float.
+func (n number) bitLen() int {
+	if n.big != nil {
+		return n.big.BitLen()
+	}
+	if n.i < 0 {
+		return bits.Len64(uint64(-int64(n.i)))
+	}
+	return bits.Len64(uint64(n.i))
+}
+
+// addBits returns the most bits the sum or difference of x and y may have.
+func addBits(x, y number) int {
+	if x.isFloat || y.isFloat {
+		return 0
+	}
+	if x.bitLen() > y.bitLen() {
+		return x.bitLen() + 1
+	}
+	return y.bitLen() + 1
+}
+
+// allocateBignum accounts for the bignum of up to size bits that an
+// operation on x and y is about to allocate, unless the result is a float or
+// fits in an int.
+func (e *Env) allocateBignum(x, y number, size int) error {
+	if x.isFloat || y.isFloat || size < strconv.IntSize {
+		return nil
+	}
+	return e.allocate(0, (size+7)/8)
+}
+
+// newBigNumber returns b as a number, demoting it to an int if it fits.
+func newBigNumber(b *big.Int) number {
+	if b.IsInt64() && int64(int(b.Int64())) == b.Int64() {
+		return number{i: int(b.Int64())}
+	}
+	return number{big: b}
+}
+
+func (n number) value() *Value {
+	if n.isFloat {
+		return newSExpValue(sexpressions.NewFloat(n.f))
+	}
+	if n.big != nil {
+		return newSExpValue(sexpressions.NewBigInt(n.big))
+	}
+	return newSExpValue(sexpressions.NewInt(n.i))
+}
+
+func newIntValue(i int) *Value {
+	return number{i: i}.value()
+}
+
+// addNumbers returns x + y. The result is a float if either is a float.
+func addNumbers(x, y number) number {
+	if x.isFloat || y.isFloat {
+		return number{f: x.float() + y.float(), isFloat: true}
+	}
+	if x.big == nil && y.big == nil {
+		sum := x.i + y.i
+		if (sum^x.i)&(sum^y.i) >= 0 {
+			return number{i: sum}
+		}
+	}
+	return newBigNumber(new(big.Int).Add(x.bigInt(), y.bigInt()))
+}
+
+// subNumbers returns x - y.
+func subNumbers(x, y number) number {
+	if x.isFloat || y.isFloat {
+		return number{f: x.float() - y.float(), isFloat: true}
+	}
+	if x.big == nil && y.big == nil {
+		diff := x.i - y.i
+		if (x.i^y.i)&(x.i^diff) >= 0 {
+			return number{i: diff}
+		}
+	}
+	return newBigNumber(new(big.Int).Sub(x.bigInt(), y.bigInt()))
+}
+
+// mulNumbers returns x * y.
+func mulNumbers(x, y number) number {
+	if x.isFloat || y.isFloat {
+		return number{f: x.float() * y.float(), isFloat: true}
+	}
+	if x.big == nil && y.big == nil {
+		product := x.i * y.i
+		overflow := x.i != 0 && (product/x.i != y.i || (x.i == -1 && y.i == math.MinInt))
+		if !overflow {
+			return number{i: product}
+		}
+	}
+	return newBigNumber(new(big.Int).Mul(x.bigInt(), y.bigInt()))
+}
+
+// divNumbers returns x / y. Division of ints truncates toward zero.
+func divNumbers(x, y number) (number, error) {
+	if isZero(y) {
+		return number{}, newCondition(divisionByZeroCondition, "division by zero: %v / %v", x.value(), y.value())
+	}
+	if x.isFloat || y.isFloat {
+		return number{f: x.float() / y.float(), isFloat: true}, nil
+	}
+	if x.big == nil && y.big == nil && !(x.i == math.MinInt && y.i == -1) {
+		return number{i: x.i / y.i}, nil
+	}
+	return newBigNumber(new(big.Int).Quo(x.bigInt(), y.bigInt())), nil
+}
+
+// modNumbers returns x modulo y, which has the same sign as y.
+func modNumbers(x, y number) (number, error) {
+	if isZero(y) {
+		return number{}, newCondition(divisionByZeroCondition, "division by zero: %v mod %v", x.value(), y.value())
+	}
+	if x.isFloat || y.isFloat {
+		xf, yf := x.float(), y.float()
+		return number{f: xf - yf*math.Floor(xf/yf), isFloat: true}, nil
+	}
+	if x.big == nil && y.big == nil {
+		r := x.i % y.i
+		if r != 0 && (r < 0) != (y.i < 0) {
+			r += y.i
+		}
+		return number{i: r}, nil
+	}
+	// big.Int.Mod returns a non-negative result.
+	r := new(big.Int).Mod(x.bigInt(), y.bigInt())
+	if r.Sign() != 0 && y.bigInt().Sign() < 0 {
+		r.Add(r, y.bigInt())
+	}
+	return newBigNumber(r), nil
+}
+
+func isZero(n number) bool {
+	if n.isFloat {
+		return n.f == 0
+	}
+	if n.big != nil {
+		return n.big.Sign() == 0
+	}
+	return n.i == 0
+}
+
+// compareNumbers returns -1, 0, or 1 depending on whether x is less than,
+// equal to, or greater than y. comparable is false if either is NaN.
+func compareNumbers(x, y number) (c int, comparable bool) {
+	if x.isFloat || y.isFloat {
+		xf, yf := x.float(), y.float()
+		switch {
+		case xf < yf:
+			return -1, true
+		case xf > yf:
+			return 1, true
+		case xf == yf:
+			return 0, true
+		}
+		return 0, false
+	}
+	if x.big != nil || y.big != nil {
+		return x.bigInt().Cmp(y.bigInt()), true
+	}
+	switch {
+	case x.i < y.i:
+		return -1, true
+	case x.i > y.i:
+		return 1, true
+	}
+	return 0, true
+}
@@ -0,0 +1,65 @@
+package evaluator
+
+import "testing"
+
+func TestComparison(t *testing.T) {
+	tests := []evalTest{
+		{src: "(< 1 2 3)", want: "#t"},
+		{src: "(< 1 3 2)", want: "#f"},
+		{src: "(<= 1 1 2)", want: "#t"},
+		{src: "(> 3 2 2)", want: "#f"},
+		{src: "(= 1 1.0)", want: "#t"},
+		{src: "(>= 2)", want: "#t"},
+		{src: "(< 1 'a)", condition: typeErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
+
+func TestArithmetic(t *testing.T) {
+	tests := []evalTest{
+		{src: "(add)", want: "0"},
+		{src: "(mul)", want: "1"},
+		{src: "(sub 5)", want: "-5"},
+		{src: "(sub 10 1 2)", want: "7"},
+		{src: "(mul 2 3 4)", want: "24"},
+		{src: "(add 1 2.5)", want: "3.5"},
+		{src: "(div 7 2)", want: "3"},
+		{src: "(mod 7 -2)", want: "-1"},
+		{src: "(div 1 0)", condition: divisionByZeroCondition},
+		{src: "(add 1 'a)", condition: typeErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
+
+func TestNegativeLiterals(t *testing.T) {
+	tests := []evalTest{
+		{src: "-2", want: "-2"},
+		{src: "(add 1 -2)", want: "-1"},
+		{src: "(sub 0 -5)", want: "5"},
+		{src: "-1.5", want: "-1.5"},
+		{src: "-0", want: "0"},
+		{src: "'-", want: "-"},
+		{src: "'-x", want: "-x"},
+		{src: "(eq? '-1 -1)", want: "#t"},
+	}
+	runEvalTests(t, tests)
+}
+
+func TestRadixLiterals(t *testing.T) {
+	tests := []evalTest{
+		{src: "0xFF", want: "255"},
+		{src: "0xff", want: "255"},
+		{src: "0o17", want: "15"},
+		{src: "0b1010", want: "10"},
+		{src: "-0x10", want: "-16"},
+		{src: "(add 0b1 0o1 0x1)", want: "3"},
+		{src: "0xffffffffffffffffff", want: "4722366482869645213695"},
+	}
+	runEvalTests(t, tests)
+
+	for _, src := range []string{"0b102", "0o8", "0xg", "0x"} {
+		if got, err := NewEnv().EvalString(src); err == nil {
+			t.Errorf("%v = %v, want an error", src, got)
+		}
+	}
+}
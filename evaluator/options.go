@@ -0,0 +1,64 @@
+package evaluator
+
+// Option customizes environments created by NewEnv.
+type Option func(*envOptions)
+
+type envOptions struct {
+	prelude bool
+	// capabilities are the allowed capabilities, or nil if all are allowed.
+	capabilities  map[Capability]bool
+	foldConstants bool
+}
+
+// WithoutPrelude makes NewEnv skip loading the prelude, so that the
+// environment only has the builtin primitives.
+func WithoutPrelude() Option {
+	return func(o *envOptions) {
+		o.prelude = false
+	}
+}
+
+// WithConstantFolding makes environments evaluate applications of the
+// builtin arithmetic primitives to constants, such as (add 1 2), and if with
+// a constant condition when forms are compiled rather than every time they
+// are evaluated. Folding assumes that the primitives are not rebound by forms
+// evaluated later, which is why it is optional.
+func WithConstantFolding() Option {
+	return func(o *envOptions) {
+		o.foldConstants = true
+	}
+}
+
+// Capability names a group of primitives that reach outside of the
+// interpreter, such as by doing I/O.
+type Capability string
+
+const (
+	// CapIO allows input and output primitives, and reading, writing and
+	// loading files. Without it, format only returns strings.
+	CapIO Capability = "io"
+	// CapOS allows access to the operating system such as environment
+	// variables and processes.
+	CapOS Capability = "os"
+	// CapTime allows reading clocks and sleeping.
+	CapTime Capability = "time"
+)
+
+// WithCapabilities makes NewEnv define only the primitives that require no
+// capability or one of caps. Without this option, all primitives are defined.
+// WithCapabilities() with no arguments gives an environment for pure
+// computation.
+func WithCapabilities(caps ...Capability) Option {
+	return func(o *envOptions) {
+		o.capabilities = make(map[Capability]bool)
+		for _, c := range caps {
+			o.capabilities[c] = true
+		}
+	}
+}
+
+// allows reports whether primitives requiring c are defined. The empty
+// capability is always allowed.
+func (o *envOptions) allows(c Capability) bool {
+	return c == "" || o.capabilities == nil || o.capabilities[c]
+}
@@ -0,0 +1,14 @@
+package evaluator
+
+import "testing"
+
+func TestWithCapabilities(t *testing.T) {
+	tests := []evalTest{
+		{src: "(format #f \"~a-~a\" 1 2)", want: "\"1-2\""},
+		{src: "(format nil \"~a\" 'x)", want: "\"x\""},
+		{src: "(format #t \"~a\" 1)", condition: typeErrorCondition},
+		{src: "(read-file \"/dev/null\")", condition: unboundVariableCondition},
+		{src: "(getenv \"HOME\")", condition: unboundVariableCondition},
+	}
+	runEvalTestsIn(t, func() *Env { return NewEnv(WithCapabilities()) }, tests)
+}
@@ -0,0 +1,87 @@
+package evaluator
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// ExitError is returned by evaluations stopped by the exit primitive. Like
+// ErrInterrupted, it isn't caught by try, but unwind-protect sees it. The
+// evaluator never exits the process itself; it is up to the embedder to do
+// so with Code, as the toylisp command does.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// SetCommandLine sets the list returned by the command-line primitive, which
+// is the program name followed by its arguments. It is os.Args by default.
+func (e *Env) SetCommandLine(args []string) {
+	e.interp.commandLine = append([]string(nil), args...)
+}
+
+// osPrimitives are primitives that interact with the process and its
+// environment.
+var osPrimitives = map[string]builtin{
+	// (getenv name) returns the value of the environment variable name, or
+	// nil if it isn't set.
+	"getenv": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		name, ok := args[0].AsString()
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "getenv argument is not string: %v", args[0])
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return Nil, nil
+		}
+		return newStringValue(value), nil
+	}},
+	// (setenv name value) sets the environment variable name to value, or
+	// unsets it if value is nil.
+	"setenv": {2, 2, func(e *Env, args []*Value) (*Value, error) {
+		name, ok := args[0].AsString()
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "setenv name is not string: %v", args[0])
+		}
+		if args[1].IsNil() {
+			if err := os.Unsetenv(name); err != nil {
+				return nil, fmt.Errorf("setenv: %w", err)
+			}
+			return Nil, nil
+		}
+		value, ok := args[1].AsString()
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "setenv value is not string or nil: %v", args[1])
+		}
+		if err := os.Setenv(name, value); err != nil {
+			return nil, fmt.Errorf("setenv: %w", err)
+		}
+		return Nil, nil
+	}},
+	// (exit [code]) stops the evaluation with an *ExitError, so that the
+	// program exits with code, which is 0 by default.
+	"exit": {0, 1, func(e *Env, args []*Value) (*Value, error) {
+		code := 0
+		if len(args) == 1 {
+			var ok bool
+			if code, ok = args[0].AsInt(); !ok || args[0].valueType != SExp {
+				return nil, newCondition(typeErrorCondition, "exit code is not int: %v", args[0])
+			}
+		}
+		return nil, &ExitError{Code: code}
+	}},
+	// (command-line) returns the program name and its arguments as a list of
+	// strings.
+	"command-line": {0, 0, func(e *Env, args []*Value) (*Value, error) {
+		sexps := make([]*sexpressions.SExp, len(e.interp.commandLine))
+		for i, arg := range e.interp.commandLine {
+			sexps[i] = sexpressions.NewString(arg)
+		}
+		return newSExpValue(sexpressions.NewList(sexps...)), nil
+	}},
+}
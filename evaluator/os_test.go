@@ -0,0 +1,53 @@
+package evaluator
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestGetenvSetenv(t *testing.T) {
+	t.Setenv("TOYLISP_TEST_VAR", "old")
+	tests := []evalTest{
+		{src: "(getenv \"TOYLISP_TEST_VAR\")", want: "\"old\""},
+		{src: "(setenv \"TOYLISP_TEST_VAR\" \"new\") (getenv \"TOYLISP_TEST_VAR\")", want: "\"new\""},
+		{src: "(setenv \"TOYLISP_TEST_VAR\" nil) (getenv \"TOYLISP_TEST_VAR\")", want: "()"},
+		{src: "(getenv 1)", condition: typeErrorCondition},
+		{src: "(setenv \"TOYLISP_TEST_VAR\" 1)", condition: typeErrorCondition},
+	}
+	runEvalTests(t, tests)
+	if _, ok := os.LookupEnv("TOYLISP_TEST_VAR"); ok {
+		t.Error("setenv with nil didn't unset the variable")
+	}
+}
+
+func TestExit(t *testing.T) {
+	tests := []struct {
+		src  string
+		code int
+	}{
+		{src: "(exit)", code: 0},
+		{src: "(exit 3)", code: 3},
+		{src: "(try (exit 4) (catch (c) 1))", code: 4},
+	}
+	for _, tt := range tests {
+		_, err := NewEnv().EvalString(tt.src)
+		var exitErr *ExitError
+		if !errors.As(err, &exitErr) || exitErr.Code != tt.code {
+			t.Errorf("%v: got error %v, want exit status %v", tt.src, err, tt.code)
+		}
+	}
+	runEvalTests(t, []evalTest{{src: "(exit \"1\")", condition: typeErrorCondition}})
+}
+
+func TestCommandLine(t *testing.T) {
+	e := NewEnv()
+	e.SetCommandLine([]string{"prog", "a", "b"})
+	got, err := e.EvalString("(command-line)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `("prog" "a" "b")`; got.String() != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,146 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// parameter is a dynamically scoped variable created by make-parameter.
+// Calling it returns its current value, and parameterize rebinds it for the
+// extent of a body.
+type parameter struct {
+	// value is the value of the parameter where parameterize doesn't bind
+	// it. It is never changed, since parameters are shared by tasks.
+	value *Value
+	// converter is applied to values given to make-parameter and
+	// parameterize. It may be nil.
+	converter *Value
+}
+
+func (p *parameter) convert(e *Env, v *Value) (*Value, error) {
+	if p.converter == nil {
+		return v, nil
+	}
+	return apply(e, p.converter, []*Value{v})
+}
+
+// dynamicBinding is a value bound to a parameter by parameterize. The
+// bindings visible from an environment form a list from the innermost one,
+// which is never changed, so that tasks can share its tail.
+type dynamicBinding struct {
+	param *parameter
+	value *Value
+	next  *dynamicBinding
+}
+
+// parameterValue returns the value of p in e.
+func (e *Env) parameterValue(p *parameter) *Value {
+	if e != nil {
+		for b := e.dynamic; b != nil; b = b.next {
+			if b.param == p {
+				return b.value
+			}
+		}
+	}
+	return p.value
+}
+
+// parameterPrimitives are primitives that create parameters.
+var parameterPrimitives = map[string]builtin{
+	// (make-parameter value [converter]) returns a parameter whose initial
+	// value is (converter value).
+	"make-parameter": {1, 2, func(e *Env, args []*Value) (*Value, error) {
+		p := &parameter{}
+		if len(args) == 2 {
+			p.converter = args[1]
+		}
+		value, err := p.convert(e, args[0])
+		if err != nil {
+			return nil, err
+		}
+		p.value = value
+		return &Value{valueType: Parameter, value: p}, nil
+	}},
+}
+
+// parameterizeAST rebinds parameters while evaluating body, and restores
+// their values when body returns or fails.
+type parameterizeAST struct {
+	astNode
+	paramASTs []ast
+	valueASTs []ast
+	bodyASTs  []ast
+}
+
+func (a *parameterizeAST) dumpFields() []dumpField {
+	return []dumpField{{"paramASTs", a.paramASTs}, {"valueASTs", a.valueASTs}, {"bodyASTs", a.bodyASTs}}
+}
+
+func (a *parameterizeAST) Eval(e *Env) (*Value, error) {
+	params := make([]*parameter, len(a.paramASTs))
+	values := make([]*Value, len(a.paramASTs))
+	for i := range a.paramASTs {
+		paramValue, err := eval(e, a.paramASTs[i])
+		if err != nil {
+			return nil, err
+		}
+		if paramValue.valueType != Parameter {
+			return nil, newCondition(typeErrorCondition, "parameterize target is not parameter: %v", paramValue)
+		}
+		params[i] = paramValue.value.(*parameter)
+		value, err := eval(e, a.valueASTs[i])
+		if err != nil {
+			return nil, err
+		}
+		values[i], err = params[i].convert(e, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// The bindings are added to e for the extent of the body rather than to
+	// the parameters, so that they are only visible from the calls the body
+	// makes and not from other tasks.
+	old := e.dynamic
+	for i := range params {
+		e.dynamic = &dynamicBinding{param: params[i], value: values[i], next: e.dynamic}
+	}
+	defer func() {
+		e.dynamic = old
+	}()
+	return evalSequence(e, a.bodyASTs)
+}
+
+// makeParameterizeAST makes AST for (parameterize ((param value) ...) body ...).
+func makeParameterizeAST(sexps []*sexpressions.SExp, sc *scope) (ast, error) {
+	if len(sexps) < 2 {
+		return nil, fmt.Errorf("parameterize requires at least 1 arg: %+v", sexps)
+	}
+	bindings, ok := sexps[1].AsList()
+	if !ok {
+		return nil, fmt.Errorf("1st argument to parameterize must be a list of (param value): %+v", sexps)
+	}
+	a := &parameterizeAST{}
+	for i := range bindings {
+		binding, ok := bindings[i].AsList()
+		if !ok || len(binding) != 2 {
+			return nil, fmt.Errorf("1st argument to parameterize must be a list of (param value): %+v", sexps)
+		}
+		paramAST, err := makeAST(binding[0], sc)
+		if err != nil {
+			return nil, err
+		}
+		valueAST, err := makeAST(binding[1], sc)
+		if err != nil {
+			return nil, err
+		}
+		a.paramASTs = append(a.paramASTs, paramAST)
+		a.valueASTs = append(a.valueASTs, valueAST)
+	}
+	bodyASTs, err := makeASTs(sexps[2:], sc)
+	if err != nil {
+		return nil, err
+	}
+	a.bodyASTs = bodyASTs
+	return a, nil
+}
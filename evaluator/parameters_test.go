@@ -0,0 +1,36 @@
+package evaluator
+
+import "testing"
+
+func TestParameterize(t *testing.T) {
+	tests := []evalTest{
+		{src: "(set p (make-parameter 1)) (list (p) (parameterize ((p 2)) (p)) (p))", want: "(1 2 1)"},
+		{src: "(set p (make-parameter 1 (lambda (x) (mul x 10)))) (list (p) (parameterize ((p 2)) (p)))", want: "(10 20)"},
+		{src: "(set p (make-parameter 1)) (set f (lambda () (p))) (parameterize ((p 2)) (f))", want: "2"},
+		{src: "(set p (make-parameter 1)) (try (parameterize ((p 2)) (car 1)) (catch (c) (p)))", want: "1"},
+		{src: "(set p (make-parameter 1)) (p 2)", condition: arityErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
+
+// TestParameterizeTasks checks that a parameter bound by parameterize in a
+// task isn't visible from other tasks. Run with -race, which also reports
+// unsynchronized bindings.
+func TestParameterizeTasks(t *testing.T) {
+	tests := []evalTest{
+		{src: `
+(define p (make-parameter 0))
+(define t (spawn (lambda () (parameterize ((p 1)) (sleep 0.1) (p)))))
+(sleep 0.02)
+(list (p) (join t))`, want: "(0 1)"},
+		{src: `
+(define p (make-parameter 0))
+(define t (parameterize ((p 1)) (spawn (lambda () (sleep 0.05) (p)))))
+(list (p) (join t))`, want: "(0 1)"},
+		{src: `
+(define p (make-parameter 0))
+(define t (spawn (lambda () (sleep 0.02) (p))))
+(parameterize ((p 1)) (sleep 0.05) (list (p) (join t)))`, want: "(1 0)"},
+	}
+	runEvalTests(t, tests)
+}
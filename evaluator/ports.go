@@ -0,0 +1,201 @@
+package evaluator
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// errPortClosed is returned by reads and writes of closed ports.
+var errPortClosed = errors.New("port is closed")
+
+// port is a source of input or a destination of output. Output written to
+// string ports made by open-output-string is kept in a buffer instead of
+// being written out.
+type port struct {
+	// mu serializes reads and writes, which tasks may make concurrently.
+	mu sync.Mutex
+	// r is where input is read from, or nil for output ports.
+	r *bufio.Reader
+	// w is where output is written, or nil for input ports.
+	w io.Writer
+	// buf is the buffer of string ports, or nil.
+	buf *strings.Builder
+	// closer closes the file of file ports, or nil.
+	closer io.Closer
+	closed bool
+}
+
+func newStringPort() *port {
+	buf := &strings.Builder{}
+	return &port{w: buf, buf: buf}
+}
+
+func (p *port) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return 0, errPortClosed
+	}
+	if p.w == nil {
+		return 0, newCondition(typeErrorCondition, "port is not output port")
+	}
+	return p.w.Write(b)
+}
+
+// readLine reads a line from p as by read-line.
+func (p *port) readLine() (line string, ok bool, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return "", false, errPortClosed
+	}
+	if p.r == nil {
+		return "", false, newCondition(typeErrorCondition, "port is not input port")
+	}
+	return readLine(p.r)
+}
+
+// close closes p and its file, if any. Closing a closed port does nothing.
+func (p *port) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	if p.closer != nil {
+		return p.closer.Close()
+	}
+	return nil
+}
+
+// String returns the output written to a string port so far.
+func (p *port) String() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.buf.String()
+}
+
+func newPortValue(p *port) *Value {
+	return &Value{valueType: Port, value: p}
+}
+
+func asPort(name string, v *Value) (*port, error) {
+	if v.valueType != Port {
+		return nil, newCondition(typeErrorCondition, "%v argument is not port: %v", name, v)
+	}
+	return v.value.(*port), nil
+}
+
+func asStringPort(name string, v *Value) (*port, error) {
+	p, err := asPort(name, v)
+	if err != nil {
+		return nil, err
+	}
+	if p.buf == nil {
+		return nil, newCondition(typeErrorCondition, "%v argument is not string port: %v", name, v)
+	}
+	return p, nil
+}
+
+// output returns where output primitives called in e write to.
+func (e *Env) output() io.Writer {
+	if e.out != nil {
+		return e.out
+	}
+	return e.interp.stdout
+}
+
+// withOutput returns an environment inside e whose output is redirected to
+// p, so that output primitives called from it write to p. Other evaluations
+// in e, such as tasks, keep writing to the output of e.
+func (e *Env) withOutput(p *port) *Env {
+	return &Env{parent: e, interp: e.interp, out: p, depth: e.depth, dynamic: e.dynamic}
+}
+
+// portPrimitives are primitives that make and write to ports.
+var portPrimitives = map[string]builtin{
+	// (open-output-string) returns a string port, whose output is returned by
+	// get-output-string.
+	"open-output-string": {0, 0, func(e *Env, args []*Value) (*Value, error) {
+		return newPortValue(newStringPort()), nil
+	}},
+	// (get-output-string port) returns the output written to the string port
+	// so far.
+	"get-output-string": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		p, err := asStringPort("get-output-string", args[0])
+		if err != nil {
+			return nil, err
+		}
+		s := p.String()
+		if err := e.allocate(0, len(s)); err != nil {
+			return nil, err
+		}
+		return newStringValue(s), nil
+	}},
+	// (with-output-to-string thunk) calls thunk with the output redirected
+	// to a string port, and returns the output it wrote.
+	"with-output-to-string": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		p := newStringPort()
+		if _, err := apply(e.withOutput(p), args[0], nil); err != nil {
+			return nil, err
+		}
+		s := p.String()
+		if err := e.allocate(0, len(s)); err != nil {
+			return nil, err
+		}
+		return newStringValue(s), nil
+	}},
+	// (call-with-output-string fn) calls fn with a string port, and returns
+	// the output written to it.
+	"call-with-output-string": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		p := newStringPort()
+		if _, err := apply(e, args[0], []*Value{newPortValue(p)}); err != nil {
+			return nil, err
+		}
+		s := p.String()
+		if err := e.allocate(0, len(s)); err != nil {
+			return nil, err
+		}
+		return newStringValue(s), nil
+	}},
+	// (current-output-port) returns a port writing to the output that print
+	// writes to.
+	"current-output-port": {0, 0, func(e *Env, args []*Value) (*Value, error) {
+		w := e.output()
+		if p, ok := w.(*port); ok {
+			return newPortValue(p), nil
+		}
+		return newPortValue(&port{w: w}), nil
+	}},
+	// (write-string str [port]) writes str as by display to port, or to the
+	// output if it is omitted.
+	"write-string": {1, 2, func(e *Env, args []*Value) (*Value, error) {
+		s, ok := args[0].AsString()
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "write-string argument is not string: %v", args[0])
+		}
+		w := e.output()
+		if len(args) == 2 {
+			p, err := asPort("write-string", args[1])
+			if err != nil {
+				return nil, err
+			}
+			w = p
+		}
+		if _, err := io.WriteString(w, s); err != nil {
+			return nil, fmt.Errorf("write-string: %w", err)
+		}
+		return Nil, nil
+	}},
+	"port?": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		if args[0].valueType == Port {
+			return True, nil
+		}
+		return False, nil
+	}},
+}
@@ -0,0 +1,56 @@
+package evaluator
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWithOutputToString(t *testing.T) {
+	tests := []struct {
+		src    string
+		want   string
+		output string
+	}{
+		{src: `(with-output-to-string (lambda () (print 1) (display "a")))`, want: `"1\na"`},
+		{src: `(with-output-to-string (lambda () (display (with-output-to-string (lambda () (display 1)))) (display 2)))`, want: `"12"`},
+		{src: `(set f (lambda () (display "x"))) (list (with-output-to-string f) (f))`, want: `("x" "x")`, output: "x"},
+		{src: `(with-output-to-string (lambda () (write-string "s" (current-output-port))))`, want: `"s"`},
+		{src: `(try (with-output-to-string (lambda () (display 1) (error "e"))) (catch error (c) (display 2)))`, want: `2`, output: "2"},
+	}
+	for _, tt := range tests {
+		e := NewEnv()
+		var out bytes.Buffer
+		e.SetOutput(&out)
+		got, err := e.EvalString(tt.src)
+		if err != nil {
+			t.Errorf("%v: %v", tt.src, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%v = %v, want %v", tt.src, got, tt.want)
+		}
+		if out.String() != tt.output {
+			t.Errorf("%v: output %q, want %q", tt.src, out.String(), tt.output)
+		}
+	}
+}
+
+func TestWithOutputToStringTasks(t *testing.T) {
+	e := NewEnv()
+	var out bytes.Buffer
+	e.SetOutput(&out)
+	got, err := e.EvalString(`
+(set ch (chan))
+(set done (chan))
+(set task (spawn (lambda () (recv ch) (display "task") (send done 1))))
+(with-output-to-string (lambda () (send ch 1) (recv done) (display "main")))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != `"main"` {
+		t.Errorf("got %v, want %q", got, "main")
+	}
+	if out.String() != "task" {
+		t.Errorf("output %q, want %q", out.String(), "task")
+	}
+}
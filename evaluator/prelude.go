@@ -0,0 +1,42 @@
+package evaluator
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/soishi1/toylisp/parser"
+	"github.com/soishi1/toylisp/sexpressions"
+	"github.com/soishi1/toylisp/tokenizer"
+)
+
+// prelude is toylisp code that defines functions derived from primitives.
+//
+//go:embed prelude.lisp
+var prelude string
+
+// preludeForms are the s-expressions of prelude. They are parsed once and
+// evaluated by every NewEnv, which only reads them.
+var preludeForms = parsePrelude()
+
+func parsePrelude() []*sexpressions.SExp {
+	tokens, err := tokenizer.Tokenize(prelude)
+	if err != nil {
+		panic(fmt.Sprintf("failed to tokenize prelude: %v", err))
+	}
+	sexps, err := parser.Parse(tokens)
+	if err != nil {
+		panic(fmt.Sprintf("failed to parse prelude: %v", err))
+	}
+	return sexps
+}
+
+// loadPrelude evaluates the prelude in e.
+func (e *Env) loadPrelude() error {
+	defer e.enterEval()()
+	for _, sexp := range preludeForms {
+		if _, err := e.Eval(sexp); err != nil {
+			return withFile(err, "prelude.lisp")
+		}
+	}
+	return nil
+}
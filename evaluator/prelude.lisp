@@ -0,0 +1,75 @@
+; Prelude: functions derived from primitives, loaded into every NewEnv
+; unless WithoutPrelude is given.
+
+(set not (lambda (x) (if x #f #t)))
+(set null? (lambda (x) (eq? x nil)))
+(set zero? (lambda (x) (= x 0)))
+(set identity (lambda (x) x))
+
+(set caar (lambda (x) (car (car x))))
+(set cadr (lambda (x) (car (cdr x))))
+(set cdar (lambda (x) (cdr (car x))))
+(set cddr (lambda (x) (cdr (cdr x))))
+(set caddr (lambda (x) (car (cdr (cdr x)))))
+
+; (compose f g) returns a function that applies g and then f.
+(set compose
+  (lambda (f g)
+    (lambda args (f (apply g args)))))
+
+; (for-each f list) calls f on each element of list for side effects.
+(set for-each
+  (lambda (f list)
+    (map f list)
+    nil))
+
+; (remove pred list) returns elements of list for which pred is false.
+(set remove
+  (lambda (pred list)
+    (filter (lambda (x) (not (pred x))) list)))
+
+; (any? pred list) reports whether pred is true for some element of list.
+(set any?
+  (lambda (pred list)
+    (if (null? list)
+      #f
+      (if (pred (car list)) #t (any? pred (cdr list))))))
+
+; (every? pred list) reports whether pred is true for all elements of list.
+(set every?
+  (lambda (pred list)
+    (if (null? list)
+      #t
+      (if (pred (car list)) (every? pred (cdr list)) #f))))
+
+; Streams are lazy lists made by the stream-cons special form, whose tail is
+; evaluated when stream-cdr is first called on it. nil is the empty stream.
+(set stream-null? null?)
+(set stream-car car)
+(set stream-cdr (lambda (s) (force (cdr s))))
+
+; (stream-map f s) returns a stream of f applied to each element of s.
+(set stream-map
+  (lambda (f s)
+    (if (stream-null? s)
+      nil
+      (stream-cons (f (stream-car s)) (stream-map f (stream-cdr s))))))
+
+; (stream-filter pred s) returns a stream of the elements of s for which
+; pred is true.
+(set stream-filter
+  (lambda (pred s)
+    (if (stream-null? s)
+      nil
+      (if (pred (stream-car s))
+        (stream-cons (stream-car s) (stream-filter pred (stream-cdr s)))
+        (stream-filter pred (stream-cdr s))))))
+
+; (stream-take n s) returns a list of the first n elements of s, or all of
+; them if s has fewer. Elements after them aren't evaluated.
+(set stream-take
+  (lambda (n s)
+    (if (if (<= n 0) #t (stream-null? s))
+      nil
+      (cons (stream-car s)
+            (if (= n 1) nil (stream-take (sub n 1) (stream-cdr s)))))))
@@ -0,0 +1,41 @@
+package evaluator
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestPrelude checks that the prelude loads under every combination of
+// options, since NewEnv panics otherwise.
+func TestPrelude(t *testing.T) {
+	caps := []Capability{CapIO, CapOS, CapTime}
+	for mask := 0; mask < 1<<len(caps); mask++ {
+		var allowed []Capability
+		for i, c := range caps {
+			if mask&(1<<i) != 0 {
+				allowed = append(allowed, c)
+			}
+		}
+		for _, fold := range []bool{false, true} {
+			opts := []Option{WithCapabilities(allowed...)}
+			if fold {
+				opts = append(opts, WithConstantFolding())
+			}
+			t.Run(fmt.Sprintf("%v fold=%v", allowed, fold), func(t *testing.T) {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Fatal(r)
+					}
+				}()
+				e := NewEnv(opts...)
+				got, err := e.EvalString("(list (not #f) (cadr (list 1 2)) (identity 3))")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.String() != "(#t 2 3)" {
+					t.Errorf("got %v, want (#t 2 3)", got)
+				}
+			})
+		}
+	}
+}
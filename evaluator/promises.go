@@ -0,0 +1,104 @@
+package evaluator
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// promise is a value computed on demand by force, made by delay or
+// make-promise.
+type promise struct {
+	mu   sync.Mutex
+	done bool
+	// exprAST and env are the expression to compute the value and the
+	// environment to evaluate it in. They are dropped once the value is
+	// computed.
+	exprAST ast
+	env     *Env
+	value   *Value
+}
+
+// force returns the value of p, evaluating its expression if it hasn't been
+// yet. If the evaluation fails, the next force evaluates it again. If p is
+// forced again during its own evaluation, the value computed first is kept.
+func (p *promise) force() (*Value, error) {
+	p.mu.Lock()
+	if p.done {
+		p.mu.Unlock()
+		return p.value, nil
+	}
+	exprAST, env := p.exprAST, p.env
+	p.mu.Unlock()
+
+	value, err := eval(env, exprAST)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.done {
+		p.done, p.value = true, value
+		p.exprAST, p.env = nil, nil
+	}
+	return p.value, nil
+}
+
+func newPromiseValue(p *promise) *Value {
+	return &Value{valueType: Promise, value: p}
+}
+
+// delayAST is (delay expr), which returns a promise to evaluate expr in the
+// current environment when forced.
+type delayAST struct {
+	astNode
+	exprAST ast
+}
+
+func (a *delayAST) dumpFields() []dumpField {
+	return []dumpField{{"exprAST", a.exprAST}}
+}
+
+func (a *delayAST) Eval(e *Env) (*Value, error) {
+	return newPromiseValue(&promise{exprAST: a.exprAST, env: e}), nil
+}
+
+// makeDelayAST makes AST for (delay expr).
+func makeDelayAST(sexps []*sexpressions.SExp, sc *scope) (ast, error) {
+	if len(sexps) != 2 {
+		return nil, fmt.Errorf("delay requires 1 arg: %+v", sexps)
+	}
+	exprAST, err := makeAST(sexps[1], sc)
+	if err != nil {
+		return nil, err
+	}
+	return &delayAST{exprAST: exprAST}, nil
+}
+
+// promisePrimitives are primitives that create and force promises.
+var promisePrimitives = map[string]builtin{
+	// (force value) returns the value of value if it is a promise, computing
+	// it on the first call, and value itself otherwise.
+	"force": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		if args[0].valueType != Promise {
+			return args[0], nil
+		}
+		return args[0].value.(*promise).force()
+	}},
+	// (make-promise value) returns a promise already forced to value, or
+	// value itself if it is a promise.
+	"make-promise": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		if args[0].valueType == Promise {
+			return args[0], nil
+		}
+		return newPromiseValue(&promise{done: true, value: args[0]}), nil
+	}},
+	"promise?": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		if args[0].valueType == Promise {
+			return True, nil
+		}
+		return False, nil
+	}},
+}
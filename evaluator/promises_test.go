@@ -0,0 +1,26 @@
+package evaluator
+
+import "testing"
+
+func TestPromises(t *testing.T) {
+	tests := []evalTest{
+		{src: "(force (delay (add 1 2)))", want: "3"},
+		{src: "(set n 0) (set p (delay (set n (add n 1)))) (force p) (force p) n", want: "1"},
+		{src: "(force (make-promise 1))", want: "1"},
+		{src: "(force 1)", want: "1"},
+		{src: "(promise? (make-promise 1))", want: "#t"},
+		{src: "(promise? 1)", want: "#f"},
+		{src: "(force (delay (car 1)))", condition: typeErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
+
+func TestStreams(t *testing.T) {
+	tests := []evalTest{
+		{src: "(stream-car (stream-cdr (stream-cons 1 (stream-cons 2 '()))))", want: "2"},
+		{src: "(stream-car (stream-cons 1 (car 1)))", want: "1"},
+		{src: "(set ints (lambda (n) (stream-cons n (ints (add n 1))))) (stream-take 3 (stream-map (lambda (x) (mul x x)) (ints 1)))", want: "(1 4 9)"},
+		{src: "(set ints (lambda (n) (stream-cons n (ints (add n 1))))) (stream-take 2 (stream-filter (lambda (x) (= (mod x 2) 0)) (ints 1)))", want: "(2 4)"},
+	}
+	runEvalTests(t, tests)
+}
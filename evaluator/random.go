@@ -0,0 +1,39 @@
+package evaluator
+
+import (
+	"math/big"
+)
+
+// randomPrimitives are primitives that use the interpreter's random number
+// generator.
+var randomPrimitives = map[string]builtin{
+	// (random n) returns a random number in [0, n). The result is a float if n
+	// is a float.
+	"random": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		n, ok := asNumber(args[0])
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "random argument is not number: %v", args[0])
+		}
+		if c, _ := compareNumbers(n, number{}); c <= 0 {
+			return nil, newCondition(typeErrorCondition, "random argument is not positive: %v", args[0])
+		}
+		e.interp.randMu.Lock()
+		defer e.interp.randMu.Unlock()
+		r := e.interp.rand
+		if n.isFloat {
+			return number{f: r.Float64() * n.f, isFloat: true}.value(), nil
+		}
+		if n.big != nil {
+			return newBigNumber(new(big.Int).Rand(r, n.big)).value(), nil
+		}
+		return newIntValue(r.Intn(n.i)), nil
+	}},
+	"set-random-seed": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		seed, ok := args[0].AsInt()
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "set-random-seed argument is not int: %v", args[0])
+		}
+		e.SetRandomSeed(int64(seed))
+		return args[0], nil
+	}},
+}
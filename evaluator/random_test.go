@@ -0,0 +1,39 @@
+package evaluator
+
+import "testing"
+
+func TestRandom(t *testing.T) {
+	const draws = "(list (random 1000000) (random 1000000) (random 1.0) (random 100000000000000000000))"
+	run := func(e *Env, src string) string {
+		t.Helper()
+		got, err := e.EvalString(src)
+		if err != nil {
+			t.Fatalf("%v: %v", src, err)
+		}
+		return got.String()
+	}
+
+	a, b := NewEnv(), NewEnv()
+	run(a, "(set-random-seed 42)")
+	b.SetRandomSeed(42)
+	first := run(a, draws)
+	if second := run(b, draws); first != second {
+		t.Errorf("draws after the same seed differ: %v and %v", first, second)
+	}
+	// Drawing from another interpreter doesn't advance this one.
+	run(a, "(set-random-seed 7)")
+	b.SetRandomSeed(7)
+	run(NewEnv(), draws)
+	if x, y := run(a, draws), run(b, draws); x != y {
+		t.Errorf("draws after the same seed differ: %v and %v", x, y)
+	}
+
+	runEvalTests(t, []evalTest{
+		{src: "(let ((x (random 3))) (if (< x 3) (<= 0 x) #f))", want: "#t"},
+		{src: "(random 1)", want: "0"},
+		{src: "(random 0)", condition: typeErrorCondition},
+		{src: "(random -1.5)", condition: typeErrorCondition},
+		{src: "(random 'a)", condition: typeErrorCondition},
+		{src: "(set-random-seed 1.5)", condition: typeErrorCondition},
+	})
+}
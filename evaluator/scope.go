@@ -0,0 +1,200 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// frame is the layout of the variables bound by a lambda or a catch clause.
+// Environments made for it store the values in slots in the same order, so
+// that variables resolved at compile time are looked up by index instead of
+// by name.
+type frame struct {
+	symbols []*sexpressions.Symbol
+	// open is true if the environments of the frame may get variables other
+	// than the slots, such as by define in the body.
+	open bool
+	// captures are the depths of the enclosing frames whose variables the
+	// body, including lambdas in it, refers to, counted from the environment
+	// a lambda with the frame is defined in.
+	captures []int
+}
+
+func (f *frame) dumpFields() []dumpField {
+	return []dumpField{{"symbols", f.symbols}, {"open", f.open}, {"captures", f.captures}}
+}
+
+// capture records that the body refers to the frame depth levels up.
+func (f *frame) capture(depth int) {
+	if !f.captured(depth) {
+		f.captures = append(f.captures, depth)
+	}
+}
+
+// captured reports whether the body refers to the frame depth levels up.
+func (f *frame) captured(depth int) bool {
+	for _, d := range f.captures {
+		if d == depth {
+			return true
+		}
+	}
+	return false
+}
+
+// emptyFrame is the frame of the environments closureEnv puts in place of
+// the ones a lambda doesn't refer to, so that the depths of addresses stay
+// the same.
+var emptyFrame = &frame{}
+
+// index returns the slot of symbol in f, or -1 if f doesn't bind it.
+func (f *frame) index(symbol *sexpressions.Symbol) int {
+	if f == nil {
+		return -1
+	}
+	for i, s := range f.symbols {
+		if s == symbol {
+			return i
+		}
+	}
+	return -1
+}
+
+// scope is the chain of frames an AST is compiled in. Each scope corresponds
+// to an environment created at run time, and the nil scope to the
+// environment passed to Eval.
+type scope struct {
+	// frame is nil for environments whose variables are only bound by set,
+	// such as the one of a module.
+	frame  *frame
+	parent *scope
+}
+
+// newScope returns the scope of f inside parent.
+func newScope(f *frame, parent *scope) *scope {
+	return &scope{frame: f, parent: parent}
+}
+
+// address is the location of a variable resolved at compile time: the slot
+// of the environment depth levels up from the one an AST is evaluated in.
+type address struct {
+	depth, slot int
+}
+
+// noAddress means the variable is looked up by name.
+var noAddress = address{depth: -1, slot: -1}
+
+func (a address) resolved() bool {
+	return a.slot >= 0
+}
+
+func (a address) String() string {
+	if !a.resolved() {
+		return "global"
+	}
+	return fmt.Sprintf("%v:%v", a.depth, a.slot)
+}
+
+// resolve returns the address of symbol in s, or noAddress if it isn't bound
+// by any of the frames and so is global. The frames inside the one binding
+// symbol record that they capture it.
+func (s *scope) resolve(symbol *sexpressions.Symbol) address {
+	depth := 0
+	for cursor := s; cursor != nil; cursor = cursor.parent {
+		if i := cursor.frame.index(symbol); i >= 0 {
+			d := depth - 1
+			for inner := s; inner != cursor; inner = inner.parent {
+				if inner.frame != nil {
+					inner.frame.capture(d)
+				}
+				d--
+			}
+			return address{depth: depth, slot: i}
+		}
+		depth++
+	}
+	return noAddress
+}
+
+// markDefines records that the innermost environment of s may get variables
+// defined at run time.
+func (s *scope) markDefines() {
+	if s != nil && s.frame != nil {
+		s.frame.open = true
+	}
+}
+
+// closureEnv returns the environment a lambda with frame f defined in e
+// keeps. Only the slots of the enclosing frames the body refers to are kept,
+// shared with the environments of the frames so that set! on either side is
+// seen by the other, and the other frames are replaced by empty ones, so that
+// their values can be garbage collected while the lambda is alive. Frames
+// that may get other variables, and the environments outside of them, are
+// kept as they are.
+func closureEnv(e *Env, f *frame) *Env {
+	top, n, all := e, 0, true
+	for top.frame != nil && !top.frame.open && !top.hasVars() {
+		all = all && f.captured(n)
+		top = top.parent
+		n++
+	}
+	if all {
+		return e
+	}
+	// Frames beyond the last captured one aren't needed at all.
+	kept := 0
+	for _, d := range f.captures {
+		if d >= kept {
+			kept = d + 1
+		}
+	}
+	if kept > n {
+		kept = n
+	}
+	frames := make([]*Env, kept)
+	for i, cursor := 0, e; i < kept; i, cursor = i+1, cursor.parent {
+		frames[i] = cursor
+	}
+	env := top
+	for i := kept - 1; i >= 0; i-- {
+		c := &Env{frame: emptyFrame, parent: env, interp: frames[i].interp, out: frames[i].out, depth: frames[i].depth, dynamic: frames[i].dynamic}
+		if f.captured(i) {
+			c.frame, c.slots = frames[i].frame, frames[i].slots
+		}
+		env = c
+	}
+	return env
+}
+
+// lookupAddress returns the value of symbol at addr. It fails if the
+// variable isn't bound yet, or may be shadowed by a variable defined by set
+// or import in an environment in between, so that the caller falls back to
+// lookupSymbol.
+func (e *Env) lookupAddress(symbol *sexpressions.Symbol, addr address) (*Value, bool) {
+	cursor := e
+	for i := 0; i < addr.depth; i++ {
+		if cursor.mayShadow(symbol) {
+			return nil, false
+		}
+		cursor = cursor.parent
+	}
+	value := cursor.slots[addr.slot]
+	return value, value != nil
+}
+
+// assignAddress sets the variable at addr to value under the same conditions
+// as lookupAddress, and reports whether it did.
+func (e *Env) assignAddress(symbol *sexpressions.Symbol, addr address, value *Value) bool {
+	cursor := e
+	for i := 0; i < addr.depth; i++ {
+		if cursor.mayShadow(symbol) {
+			return false
+		}
+		cursor = cursor.parent
+	}
+	if cursor.slots[addr.slot] == nil {
+		return false
+	}
+	cursor.slots[addr.slot] = value
+	return true
+}
@@ -0,0 +1,85 @@
+package evaluator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClosureCaptures(t *testing.T) {
+	tests := []evalTest{
+		{src: "(((lambda (x) ((lambda (y) (lambda () x)) 2)) 1))", want: "1"},
+		{src: "((((lambda (x) ((lambda (y) (lambda () (lambda () (list x y)))) 2)) 1)))", want: "(1 2)"},
+		{src: "(define f ((lambda (x) ((lambda (y) (lambda () (set! x (add x 1)) x)) 0)) 1)) (f) (f)", want: "3"},
+		{src: "((lambda (x) ((lambda (y) ((lambda (get inc) (inc) (get)) (lambda () x) (lambda () (set! x (add x y))))) 5)) 1)", want: "6"},
+		{src: "(((lambda (x) ((lambda (y) (set z 3) (lambda () (list x z))) 2)) 1))", want: "(1 3)"},
+		{src: "(set ev eval) (set x 0) (((lambda (x) (lambda () (ev 'x))) 42))", want: "0"},
+		{src: "(define ops (list eval)) (set x 0) ((lambda (x) ((lambda () ((car ops) 'x)))) 42)", want: "0"},
+	}
+	runEvalTests(t, tests)
+}
+
+// keptFrames returns the symbols of the frames whose slots lambda keeps.
+func keptFrames(lambda *LambdaValue) [][]string {
+	kept := [][]string{}
+	for e := lambda.env; e.frame != nil; e = e.parent {
+		if e.slots == nil {
+			continue
+		}
+		var names []string
+		for _, s := range e.frame.symbols {
+			names = append(names, s.Name)
+		}
+		kept = append(kept, names)
+	}
+	return kept
+}
+
+func TestClosureEnv(t *testing.T) {
+	tests := []struct {
+		src  string
+		kept [][]string
+	}{
+		{src: "((lambda (x) (lambda () 1)) 0)", kept: [][]string{}},
+		{src: "((lambda (x) (lambda (y) (if y 1 2))) 0)", kept: [][]string{}},
+		{src: "((lambda (x) (lambda () (add 1 2))) 0)", kept: [][]string{}},
+		{src: "((lambda (x) (lambda () (lambda () (add 1 2)))) 0)", kept: [][]string{}},
+		{src: "((lambda (x) (lambda () (undefined-yet))) 0)", kept: [][]string{}},
+		{src: "(set ev eval) ((lambda (x) (lambda () (ev 1))) 0)", kept: [][]string{}},
+		{src: "((lambda (x) (lambda () x)) 0)", kept: [][]string{{"x"}}},
+		{src: "((lambda (x) (lambda () (lambda () x))) 0)", kept: [][]string{{"x"}}},
+		{src: "((lambda (x) ((lambda (y) (lambda () x)) 0)) 0)", kept: [][]string{{"x"}}},
+		{src: "((lambda (x) ((lambda (y) (lambda () y)) 0)) 0)", kept: [][]string{{"y"}}},
+		{src: "((lambda (x) ((lambda (y) (lambda () (list x y))) 0)) 0)", kept: [][]string{{"y"}, {"x"}}},
+		{src: "((lambda (x) (set z 1) (lambda () 1)) 0)", kept: [][]string{{"x"}}},
+	}
+	for _, tt := range tests {
+		e := NewEnv()
+		got, err := e.EvalString(tt.src)
+		if err != nil {
+			t.Errorf("%v: %v", tt.src, err)
+			continue
+		}
+		lambda, ok := got.value.(*LambdaValue)
+		if !ok {
+			t.Errorf("%v = %v, want lambda", tt.src, got)
+			continue
+		}
+		if kept := keptFrames(lambda); !reflect.DeepEqual(kept, tt.kept) {
+			t.Errorf("%v: kept %v, want %v", tt.src, kept, tt.kept)
+		}
+	}
+}
+
+func TestAssign(t *testing.T) {
+	tests := []evalTest{
+		{src: "(define x 1) ((lambda () (set! x 2))) x", want: "2"},
+		{src: "(define x 1) ((lambda () (set x 2))) x", want: "1"},
+		{src: "(define x 1) ((lambda (x) (set! x 2)) 0) x", want: "1"},
+		{src: "(define counter ((lambda (n) (lambda () (set! n (add n 1)))) 0)) (counter) (counter)", want: "2"},
+		{src: "(define x 1) (set! x 5)", want: "5"},
+		{src: "(set! assign-test-unbound 1)", condition: unboundVariableCondition},
+		{src: "((lambda () (set! assign-test-unbound 1)))", condition: unboundVariableCondition},
+		{src: "(set! 1 2)", condition: syntaxErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
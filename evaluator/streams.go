@@ -0,0 +1,47 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// streamConsAST is (stream-cons head tail), which returns a pair of the value
+// of head and a promise to evaluate tail. Streams are such pairs or nil, and
+// the functions to use them are defined in the prelude.
+type streamConsAST struct {
+	astNode
+	headAST, tailAST ast
+}
+
+func (a *streamConsAST) dumpFields() []dumpField {
+	return []dumpField{{"headAST", a.headAST}, {"tailAST", a.tailAST}}
+}
+
+func (a *streamConsAST) Eval(e *Env) (*Value, error) {
+	head, err := eval(e, a.headAST)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.allocate(1, 0); err != nil {
+		return nil, err
+	}
+	tail := newPromiseValue(&promise{exprAST: a.tailAST, env: e})
+	return newSExpValue(sexpressions.Cons(toSExp(head), toSExp(tail))), nil
+}
+
+// makeStreamConsAST makes AST for (stream-cons head tail).
+func makeStreamConsAST(sexps []*sexpressions.SExp, sc *scope) (ast, error) {
+	if len(sexps) != 3 {
+		return nil, fmt.Errorf("stream-cons requires 2 args: %+v", sexps)
+	}
+	headAST, err := makeAST(sexps[1], sc)
+	if err != nil {
+		return nil, err
+	}
+	tailAST, err := makeAST(sexps[2], sc)
+	if err != nil {
+		return nil, err
+	}
+	return &streamConsAST{headAST: headAST, tailAST: tailAST}, nil
+}
@@ -0,0 +1,110 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// structType is a record type defined by defstruct.
+type structType struct {
+	name   string
+	fields []*sexpressions.Symbol
+}
+
+func (t *structType) dumpFields() []dumpField {
+	return []dumpField{{"name", t.name}, {"fields", t.fields}}
+}
+
+// structValue is an instance of a structType.
+type structValue struct {
+	typ    *structType
+	fields []*Value
+}
+
+func (s *structValue) String() string {
+	strs := []string{s.typ.name}
+	for i := range s.fields {
+		strs = append(strs, ":"+s.typ.fields[i].String(), s.fields[i].String())
+	}
+	return fmt.Sprintf("#S(%s)", strings.Join(strs, " "))
+}
+
+// defstructAST defines (make-NAME field ...), (NAME? x), (NAME-FIELD x) and
+// (set-NAME-FIELD! x value) for a record type NAME.
+type defstructAST struct {
+	astNode
+	typ *structType
+}
+
+func (a *defstructAST) dumpFields() []dumpField {
+	return []dumpField{{"typ", a.typ}}
+}
+
+func (a *defstructAST) Eval(e *Env) (*Value, error) {
+	typ := a.typ
+	e.RegisterPrimitive("make-"+typ.name, len(typ.fields), len(typ.fields), func(e *Env, args []*Value) (*Value, error) {
+		if err := e.allocate(len(args), 0); err != nil {
+			return nil, err
+		}
+		fields := make([]*Value, len(args))
+		copy(fields, args)
+		return &Value{valueType: Struct, value: &structValue{typ: typ, fields: fields}}, nil
+	})
+	e.RegisterPrimitive(typ.name+"?", 1, 1, func(e *Env, args []*Value) (*Value, error) {
+		if s, ok := args[0].value.(*structValue); ok && args[0].valueType == Struct && s.typ == typ {
+			return True, nil
+		}
+		return False, nil
+	})
+	for i, field := range typ.fields {
+		i := i
+		accessor := typ.name + "-" + field.String()
+		e.RegisterPrimitive(accessor, 1, 1, func(e *Env, args []*Value) (*Value, error) {
+			s, err := asStruct(accessor, typ, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return s.fields[i], nil
+		})
+		setter := "set-" + accessor + "!"
+		e.RegisterPrimitive(setter, 2, 2, func(e *Env, args []*Value) (*Value, error) {
+			s, err := asStruct(setter, typ, args[0])
+			if err != nil {
+				return nil, err
+			}
+			s.fields[i] = args[1]
+			return args[1], nil
+		})
+	}
+	return newSExpValue(sexpressions.NewSymbol(typ.name)), nil
+}
+
+func asStruct(name string, typ *structType, v *Value) (*structValue, error) {
+	s, ok := v.value.(*structValue)
+	if v.valueType != Struct || !ok || s.typ != typ {
+		return nil, newCondition(typeErrorCondition, "%s: argument is not %s: %v", name, typ.name, v)
+	}
+	return s, nil
+}
+
+// makeDefstructAST makes AST for (defstruct name field ...).
+func makeDefstructAST(sexps []*sexpressions.SExp) (ast, error) {
+	if len(sexps) < 2 {
+		return nil, fmt.Errorf("defstruct requires at least 1 arg: %+v", sexps)
+	}
+	name, ok := sexps[1].AsSymbol()
+	if !ok {
+		return nil, fmt.Errorf("1st argument to defstruct must be a symbol: %+v", sexps)
+	}
+	typ := &structType{name: name}
+	for _, sexp := range sexps[2:] {
+		field, ok := sexp.AsSymbolObject()
+		if !ok {
+			return nil, fmt.Errorf("fields of defstruct must be symbols: %+v", sexps)
+		}
+		typ.fields = append(typ.fields, field)
+	}
+	return &defstructAST{typ: typ}, nil
+}
@@ -0,0 +1,15 @@
+package evaluator
+
+import "testing"
+
+func TestDefstruct(t *testing.T) {
+	tests := []evalTest{
+		{src: "(defstruct point x y) (point-x (make-point 1 2))", want: "1"},
+		{src: "(defstruct point x y) (point? (make-point 1 2))", want: "#t"},
+		{src: "(defstruct point x y) (point? 1)", want: "#f"},
+		{src: "(defstruct point x y) (set p (make-point 1 2)) (set-point-x! p 5) (point-x p)", want: "5"},
+		{src: "(defstruct point x y) (make-point 1)", condition: arityErrorCondition},
+		{src: "(defstruct point x y) (point-x 1)", condition: typeErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
@@ -0,0 +1,115 @@
+package evaluator
+
+// maxCallDepth is the largest number of lambda calls that may be nested,
+// other than calls in tail position, so that deep recursions fail with a
+// condition instead of overflowing the Go stack and crashing the process.
+const maxCallDepth = 100000
+
+// tailCall is a lambda call in tail position, which is returned to the
+// lambda being applied instead of being applied in it.
+type tailCall struct {
+	lambda *LambdaValue
+	args   []*Value
+	// frame is the backtrace frame of the call, or nil if it has none.
+	frame *Frame
+}
+
+// tailEvaler is implemented by ASTs whose evaluation may end with a lambda
+// call.
+type tailEvaler interface {
+	// evalTail is like Eval, but returns the lambda call the evaluation
+	// ends with, if any, without applying it.
+	evalTail(e *Env) (*Value, *tailCall, error)
+}
+
+// evalTail is like eval, but returns the lambda call in tail position of a
+// without applying it. Calls aren't returned while hooks are set, so that
+// the hooks see each of them.
+func evalTail(e *Env, a ast) (*Value, *tailCall, error) {
+	t, ok := a.(tailEvaler)
+	if !ok || e.interp.loadHooks() != nil {
+		value, err := eval(e, a)
+		return value, nil, err
+	}
+	if err := e.checkInterrupt(); err != nil {
+		return nil, nil, err
+	}
+	if err := e.consumeStep(); err != nil {
+		return nil, nil, err
+	}
+	value, call, err := t.evalTail(e)
+	if err != nil {
+		return nil, nil, withPosition(err, a.form())
+	}
+	return value, call, nil
+}
+
+// evalSequenceTail is like evalSequence, but returns the lambda call in tail
+// position of the last AST without applying it.
+func evalSequenceTail(e *Env, asts []ast) (*Value, *tailCall, error) {
+	if len(asts) == 0 {
+		return Nil, nil, nil
+	}
+	if _, err := evalSequence(e, asts[:len(asts)-1]); err != nil {
+		return nil, nil, err
+	}
+	return evalTail(e, asts[len(asts)-1])
+}
+
+func (a *applicationAST) evalTail(e *Env) (*Value, *tailCall, error) {
+	funcValue, args, err := a.evalOperands(e)
+	if err != nil {
+		return nil, nil, err
+	}
+	frame := a.frame(funcValue)
+	if funcValue.valueType == Lambda {
+		return nil, &tailCall{lambda: funcValue.value.(*LambdaValue), args: args, frame: &frame}, nil
+	}
+	value, err := apply(e, funcValue, args)
+	if err != nil {
+		return nil, nil, withFrame(err, frame)
+	}
+	return value, nil, nil
+}
+
+func (a *ifAST) evalTail(e *Env) (*Value, *tailCall, error) {
+	condValue, err := eval(e, a.condAST)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !isTrue(condValue) {
+		return evalTail(e, a.elseAST)
+	}
+	return evalTail(e, a.thenAST)
+}
+
+func (a *whenAST) evalTail(e *Env) (*Value, *tailCall, error) {
+	condValue, err := eval(e, a.condAST)
+	if err != nil {
+		return nil, nil, err
+	}
+	if isTrue(condValue) == a.unless {
+		return Nil, nil, nil
+	}
+	return evalSequenceTail(e, a.bodyASTs)
+}
+
+func (a *letAST) evalTail(e *Env) (*Value, *tailCall, error) {
+	fn, args, err := a.evalLambda(e)
+	if err != nil {
+		return nil, nil, err
+	}
+	var frame *Frame
+	if a.name != nil {
+		frame = &Frame{Name: a.name.Name, Pos: a.form().Pos}
+	}
+	if fn.valueType != Lambda {
+		// Hooks set meanwhile may have replaced the lambda.
+		value, err := apply(e, fn, args)
+		if err != nil && frame != nil {
+			return nil, nil, withFrame(err, *frame)
+		}
+		return value, nil, err
+	}
+	return nil, &tailCall{lambda: fn.value.(*LambdaValue), args: args, frame: frame}, nil
+}
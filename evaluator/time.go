@@ -0,0 +1,91 @@
+package evaluator
+
+import (
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// Clock is the source of time of the time primitives, which can be replaced
+// by SetClock so that programs can be run with fake time.
+type Clock interface {
+	Now() time.Time
+	// After returns a channel that gets the time once d has passed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the Clock of the real time.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// clockState is a clock and the time monotonic-clock counts from, which are
+// replaced together.
+type clockState struct {
+	clock Clock
+	start time.Time
+}
+
+// SetClock sets the clock the time primitives read and sleep with. It is the
+// system clock by default. monotonic-clock counts from the time of c when
+// SetClock is called. It may be called while primitives are running.
+func (e *Env) SetClock(c Clock) {
+	e.interp.clock.Store(&clockState{clock: c, start: c.Now()})
+}
+
+// loadClock returns the clock set by SetClock.
+func (i *interpreter) loadClock() *clockState {
+	return i.clock.Load().(*clockState)
+}
+
+// seconds returns the number of seconds in d as a float.
+func seconds(d time.Duration) *Value {
+	return newSExpValue(sexpressions.NewFloat(d.Seconds()))
+}
+
+// timePrimitives are primitives that read clocks and sleep.
+var timePrimitives = map[string]builtin{
+	// (now) returns the number of seconds since the Unix epoch as a float.
+	"now": {0, 0, func(e *Env, args []*Value) (*Value, error) {
+		return seconds(time.Duration(e.interp.loadClock().clock.Now().UnixNano())), nil
+	}},
+	// (monotonic-clock) returns the number of seconds since the interpreter
+	// was created as a float. Unlike now, it never goes backwards, so it is
+	// suited for measuring elapsed time.
+	"monotonic-clock": {0, 0, func(e *Env, args []*Value) (*Value, error) {
+		c := e.interp.loadClock()
+		return seconds(c.clock.Now().Sub(c.start)), nil
+	}},
+	// (sleep seconds) waits for seconds, which may be a float, and returns
+	// nil. Sleeping can be interrupted.
+	"sleep": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		n, ok := asNumber(args[0])
+		if !ok {
+			return nil, newCondition(typeErrorCondition, "sleep argument is not number: %v", args[0])
+		}
+		if n.float() < 0 {
+			return nil, newCondition(rangeErrorCondition, "sleep argument is negative: %v", args[0])
+		}
+		// Converting larger values, infinity and NaN to time.Duration
+		// overflows.
+		if !(n.float() < float64(math.MaxInt64)/float64(time.Second)) {
+			return nil, newCondition(rangeErrorCondition, "sleep argument is too large: %v", args[0])
+		}
+		d := time.Duration(n.float() * float64(time.Second))
+		after := e.interp.loadClock().clock.After(d)
+		if _, _, _, err := e.block([]reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(after)},
+		}); err != nil {
+			return nil, err
+		}
+		return Nil, nil
+	}},
+}
@@ -0,0 +1,58 @@
+package evaluator
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock whose time only advances by sleeping.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func TestTime(t *testing.T) {
+	tests := []evalTest{
+		{src: "(now)", want: "1.5"},
+		{src: "(sleep 2.5)", want: "()"},
+		{src: "(sleep 2.5) (now)", want: "4.0"},
+		{src: "(sleep 2) (monotonic-clock)", want: "2.0"},
+		{src: "(sleep 0)", want: "()"},
+		{src: "(sleep -1)", condition: rangeErrorCondition},
+		{src: "(sleep 1e300)", condition: rangeErrorCondition},
+		{src: "(sleep 10000000000)", condition: rangeErrorCondition},
+		{src: "(sleep :a)", condition: typeErrorCondition},
+	}
+	runEvalTestsIn(t, func() *Env {
+		e := NewEnv()
+		e.SetClock(&fakeClock{now: time.Unix(1, 500000000)})
+		return e
+	}, tests)
+}
+
+// TestSetClockConcurrently checks that the clock can be replaced while time
+// primitives run in other goroutines, which go test -race reports otherwise.
+func TestSetClockConcurrently(t *testing.T) {
+	e := NewEnv()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			e.SetClock(systemClock{})
+		}
+	}()
+	if _, err := e.EvalString("(join (spawn (lambda () (dotimes (i 1000) (now) (monotonic-clock)))))"); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+}
@@ -0,0 +1,84 @@
+package evaluator
+
+import (
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// vectorPrimitives are primitives that operate on vectors.
+var vectorPrimitives = map[string]builtin{
+	"vector": {0, Variadic, func(e *Env, args []*Value) (*Value, error) {
+		if err := e.allocate(len(args), 0); err != nil {
+			return nil, err
+		}
+		vector := make([]*sexpressions.SExp, len(args))
+		for i := range args {
+			vector[i] = toSExp(args[i])
+		}
+		return newVectorValue(vector), nil
+	}},
+	// (make-vector n [fill]) returns a vector of length n whose elements are
+	// fill (nil if omitted).
+	"make-vector": {1, 2, func(e *Env, args []*Value) (*Value, error) {
+		n, ok := args[0].AsInt()
+		if !ok || n < 0 {
+			return nil, newCondition(typeErrorCondition, "make-vector length is not non-negative int: %v", args[0])
+		}
+		fill := Nil
+		if len(args) == 2 {
+			fill = args[1]
+		}
+		if err := checkLength("make-vector", n); err != nil {
+			return nil, err
+		}
+		if err := e.allocate(n, 0); err != nil {
+			return nil, err
+		}
+		vector := make([]*sexpressions.SExp, n)
+		for i := range vector {
+			vector[i] = toSExp(fill)
+		}
+		return newVectorValue(vector), nil
+	}},
+	"vector-ref": {2, 2, func(e *Env, args []*Value) (*Value, error) {
+		vector, i, err := vectorIndex("vector-ref", args[0], args[1])
+		if err != nil {
+			return nil, err
+		}
+		return newSExpValue(vector.VectorRef(i)), nil
+	}},
+	"vector-set!": {3, 3, func(e *Env, args []*Value) (*Value, error) {
+		vector, i, err := vectorIndex("vector-set!", args[0], args[1])
+		if err != nil {
+			return nil, err
+		}
+		vector.VectorSet(i, toSExp(args[2]))
+		return args[2], nil
+	}},
+	"vector-length": {1, 1, func(e *Env, args []*Value) (*Value, error) {
+		vector := toSExp(args[0])
+		if vector.Type != sexpressions.VectorType {
+			return nil, newCondition(typeErrorCondition, "vector-length argument is not vector: %v", args[0])
+		}
+		return newIntValue(vector.VectorLen()), nil
+	}},
+}
+
+func newVectorValue(vector []*sexpressions.SExp) *Value {
+	return newSExpValue(sexpressions.NewVector(vector...))
+}
+
+// vectorIndex checks that v is a vector and index is within its bounds.
+func vectorIndex(name string, v, index *Value) (vector *sexpressions.SExp, i int, err error) {
+	vector = toSExp(v)
+	if vector.Type != sexpressions.VectorType {
+		return nil, 0, newCondition(typeErrorCondition, "%v argument is not vector: %v", name, v)
+	}
+	i, ok := index.AsInt()
+	if !ok {
+		return nil, 0, newCondition(typeErrorCondition, "%v index is not int: %v", name, index)
+	}
+	if n := vector.VectorLen(); i < 0 || i >= n {
+		return nil, 0, newCondition(rangeErrorCondition, "%v index %v is out of range for vector of length %v", name, i, n)
+	}
+	return vector, i, nil
+}
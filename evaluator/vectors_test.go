@@ -0,0 +1,13 @@
+package evaluator
+
+import "testing"
+
+func TestMakeVector(t *testing.T) {
+	tests := []evalTest{
+		{src: "(make-vector 0)", want: "#()"},
+		{src: "(make-vector 3 1)", want: "#(1 1 1)"},
+		{src: "(make-vector -1)", condition: typeErrorCondition},
+		{src: "(make-vector 100000000000000)", condition: rangeErrorCondition},
+	}
+	runEvalTests(t, tests)
+}
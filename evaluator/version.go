@@ -0,0 +1,6 @@
+package evaluator
+
+// Version is the version of the interpreter. Its minor version is increased
+// by each change that adds primitives or special forms, so that programs can
+// check for them with (version).
+const Version = "0.3.0"
@@ -1,3 +1,3 @@
 module github.com/soishi1/toylisp
 
-go 1.17
+go 1.18
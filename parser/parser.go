@@ -3,7 +3,10 @@ package parser
 
 import (
 	"fmt"
+	"math/big"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/soishi1/toylisp/sexpressions"
 	"github.com/soishi1/toylisp/tokenizer"
@@ -11,7 +14,7 @@ import (
 
 func Parse(tokens []*tokenizer.Token) ([]*sexpressions.SExp, error) {
 	var result []*sexpressions.SExp
-	rest := tokens
+	rest := skipComments(tokens)
 	needSpace := false
 	for len(rest) > 0 {
 		if needSpace {
@@ -20,6 +23,9 @@ func Parse(tokens []*tokenizer.Token) ([]*sexpressions.SExp, error) {
 			if err != nil {
 				return nil, err
 			}
+			if len(rest) == 0 {
+				break
+			}
 		}
 
 		sexp, nextRest, err := parse1(rest)
@@ -39,30 +45,90 @@ func Parse(tokens []*tokenizer.Token) ([]*sexpressions.SExp, error) {
 	return result, nil
 }
 
+// skipComments returns tokens with comments treated as spaces. Consecutive
+// spaces are merged into one, and leading spaces are removed.
+func skipComments(tokens []*tokenizer.Token) []*tokenizer.Token {
+	var result []*tokenizer.Token
+	for _, t := range tokens {
+		if t.Type != tokenizer.Space && t.Type != tokenizer.Comment {
+			result = append(result, t)
+			continue
+		}
+		if len(result) == 0 || result[len(result)-1].Type == tokenizer.Space {
+			continue
+		}
+		result = append(result, &tokenizer.Token{Type: tokenizer.Space, Str: " "})
+	}
+	return result
+}
+
+// parse1 parses the s-expression at the head of tokens and records the
+// position of its first token.
 func parse1(tokens []*tokenizer.Token) (sexp *sexpressions.SExp, rest []*tokenizer.Token, err error) {
+	sexp, rest, err = parseExp(tokens)
+	if err != nil {
+		return nil, nil, err
+	}
+	sexp.Pos = sexpressions.Pos{Line: tokens[0].Line, Column: tokens[0].Column}
+	return sexp, rest, nil
+}
+
+func parseExp(tokens []*tokenizer.Token) (sexp *sexpressions.SExp, rest []*tokenizer.Token, err error) {
 	firstToken := tokens[0]
 	switch firstToken.Type {
-	case tokenizer.OpenParen:
+	case tokenizer.OpenParen, tokenizer.OpenVector:
 		return parseList(tokens)
+	case tokenizer.Quote:
+		return parseQuote(tokens)
 	case tokenizer.Symbol:
-		return &sexpressions.SExp{Type: sexpressions.SymbolType, Value: firstToken.Str}, tokens[1:], nil
+		return sexpressions.NewSymbol(firstToken.Str), tokens[1:], nil
+	case tokenizer.Keyword:
+		return sexpressions.NewKeyword(firstToken.Str[1:]), tokens[1:], nil
 	case tokenizer.StringLiteral:
-		// TODO(soishi): handle escaped characters.
-		return &sexpressions.SExp{Type: sexpressions.StringType, Value: firstToken.Str[1 : len(firstToken.Str)-1]}, tokens[1:], nil
-	case tokenizer.NumberLiteral:
-		// TODO(soishi): handle non integers.
-		value, err := strconv.ParseInt(firstToken.Str, 10, 64)
+		value, err := sexpressions.UnquoteString(firstToken.Str)
 		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse token %v as string: %v", firstToken, err)
+		}
+		return sexpressions.NewString(value), tokens[1:], nil
+	case tokenizer.BoolLiteral:
+		return sexpressions.NewBool(firstToken.Str == "#t"), tokens[1:], nil
+	case tokenizer.CharLiteral:
+		return parseChar(tokens)
+	case tokenizer.NumberLiteral:
+		// With base 0, strconv and math/big take the radix from the 0x, 0o, and 0b
+		// prefixes. Decimals are parsed with base 10 so that 017 is 17, not 15.
+		base := 10
+		if hasRadixPrefix(firstToken.Str) {
+			base = 0
+		} else if strings.ContainsAny(firstToken.Str, ".eE") {
+			value, err := strconv.ParseFloat(firstToken.Str, 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to parse token %v as float", firstToken)
+			}
+			return sexpressions.NewFloat(value), tokens[1:], nil
+		}
+		value, err := strconv.ParseInt(firstToken.Str, base, strconv.IntSize)
+		if err == nil {
+			return sexpressions.NewInt(int(value)), tokens[1:], nil
+		}
+		bigValue, ok := new(big.Int).SetString(firstToken.Str, base)
+		if !ok {
 			return nil, nil, fmt.Errorf("failed to parse token %v as int", firstToken)
 		}
-		return &sexpressions.SExp{Type: sexpressions.IntType, Value: int(value)}, tokens[1:], nil
+		return sexpressions.NewBigInt(bigValue), tokens[1:], nil
 	default:
 		return nil, nil, fmt.Errorf("unexpected token at %v", tokens)
 	}
 }
 
+// parseList parses a list, or a vector if it starts with '#('.
 func parseList(tokens []*tokenizer.Token) (sexp *sexpressions.SExp, rest []*tokenizer.Token, err error) {
-	rest, err = consume(tokenizer.OpenParen, tokens)
+	isVector := tokens[0].Type == tokenizer.OpenVector
+	if isVector {
+		rest, err = consume(tokenizer.OpenVector, tokens)
+	} else {
+		rest, err = consume(tokenizer.OpenParen, tokens)
+	}
 	if err != nil {
 		return nil, nil, err
 	}
@@ -75,12 +141,15 @@ func parseList(tokens []*tokenizer.Token) (sexp *sexpressions.SExp, rest []*toke
 
 		var ok bool
 		rest, ok = consumeIf(tokenizer.CloseParen, rest)
+		if ok && isVector {
+			return sexpressions.NewVector(list...), rest, nil
+		}
 		if ok {
-			sexp := &sexpressions.SExp{
-				Type:  sexpressions.ListType,
-				Value: list,
-			}
-			return sexp, rest, nil
+			return sexpressions.NewList(list...), rest, nil
+		}
+
+		if len(rest) == 0 {
+			break
 		}
 
 		if needSpace && !hasSpace {
@@ -88,6 +157,13 @@ func parseList(tokens []*tokenizer.Token) (sexp *sexpressions.SExp, rest []*toke
 			return nil, nil, err
 		}
 
+		if rest[0].Type == tokenizer.Symbol && rest[0].Str == "." {
+			if isVector {
+				return nil, nil, fmt.Errorf("unexpected . in vector at %v", rest)
+			}
+			return parseDottedTail(list, rest)
+		}
+
 		sexp, nextRest, err := parse1(rest)
 		if err != nil {
 			return nil, nil, err
@@ -99,6 +175,75 @@ func parseList(tokens []*tokenizer.Token) (sexp *sexpressions.SExp, rest []*toke
 	return nil, nil, fmt.Errorf("unmatched parens: tokens: %+v", tokens)
 }
 
+// parseDottedTail parses ". cdr)" which ends a dotted list whose preceding
+// elements are list.
+func parseDottedTail(list []*sexpressions.SExp, tokens []*tokenizer.Token) (sexp *sexpressions.SExp, rest []*tokenizer.Token, err error) {
+	if len(list) == 0 {
+		return nil, nil, fmt.Errorf("no element before . at %v", tokens)
+	}
+	rest = tokens[1:]
+	rest, err = consume(tokenizer.Space, rest)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rest) == 0 {
+		return nil, nil, fmt.Errorf("unexpected end of tokens after . at %v", tokens)
+	}
+	if rest[0].Type == tokenizer.Symbol && rest[0].Str == "." {
+		return nil, nil, fmt.Errorf("unexpected . at %v", rest)
+	}
+	sexp, rest, err = parse1(rest)
+	if err != nil {
+		return nil, nil, err
+	}
+	rest, _ = consumeIf(tokenizer.Space, rest)
+	rest, err = consume(tokenizer.CloseParen, rest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("exactly 1 element must follow . in a list: %v", err)
+	}
+	for i := len(list) - 1; i >= 0; i-- {
+		sexp = sexpressions.Cons(list[i], sexp)
+	}
+	return sexp, rest, nil
+}
+
+// hasRadixPrefix reports whether the number literal s starts with 0x, 0o, or
+// 0b, optionally preceded by a sign.
+func hasRadixPrefix(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	return len(s) > 2 && s[0] == '0' && strings.ContainsRune("xXoObB", rune(s[1]))
+}
+
+func parseChar(tokens []*tokenizer.Token) (sexp *sexpressions.SExp, rest []*tokenizer.Token, err error) {
+	name := strings.TrimPrefix(tokens[0].Str, `#\`)
+	if r, ok := sexpressions.CharNames[name]; ok {
+		return sexpressions.NewChar(r), tokens[1:], nil
+	}
+	if utf8.RuneCountInString(name) != 1 {
+		return nil, nil, fmt.Errorf("unknown character name %v", tokens[0])
+	}
+	r, _ := utf8.DecodeRuneInString(name)
+	return sexpressions.NewChar(r), tokens[1:], nil
+}
+
+// parseQuote turns 'x into (quote x). Spaces and comments may separate ' and
+// x.
+func parseQuote(tokens []*tokenizer.Token) (sexp *sexpressions.SExp, rest []*tokenizer.Token, err error) {
+	rest, err = consume(tokenizer.Quote, tokens)
+	if err != nil {
+		return nil, nil, err
+	}
+	rest, _ = consumeIf(tokenizer.Space, rest)
+	if len(rest) == 0 {
+		return nil, nil, fmt.Errorf("unexpected end of tokens after quote: tokens: %v", tokens)
+	}
+	quoted, rest, err := parse1(rest)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sexpressions.NewList(sexpressions.NewSymbol("quote"), quoted), rest, nil
+}
+
 func consume(tokenType tokenizer.Type, tokens []*tokenizer.Token) (rest []*tokenizer.Token, err error) {
 	if len(tokens) == 0 {
 		return nil, fmt.Errorf("unexpected end of tokens while expecting token %v", tokenType)
@@ -109,9 +254,11 @@ func consume(tokenType tokenizer.Type, tokens []*tokenizer.Token) (rest []*token
 	return tokens[1:], nil
 }
 
+// consumeIf consumes the first token if it is of tokenType. It doesn't call
+// consume, whose error formats all the remaining tokens, since it is called
+// for most tokens and not having the token isn't an error.
 func consumeIf(tokenType tokenizer.Type, tokens []*tokenizer.Token) (rest []*tokenizer.Token, ok bool) {
-	rest, err := consume(tokenType, tokens)
-	if err != nil {
+	if len(tokens) == 0 || tokens[0].Type != tokenType {
 		return tokens, false
 	}
 	return tokens[1:], true
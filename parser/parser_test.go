@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/soishi1/toylisp/tokenizer"
+)
+
+// TestParseLong checks that parsing doesn't format the remaining tokens for
+// each token, which would take quadratic time.
+func TestParseLong(t *testing.T) {
+	const n = 100000
+	tokens, err := tokenizer.Tokenize(strings.Repeat("(a b) ", n))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sexps, err := Parse(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sexps) != n {
+		t.Errorf("got %v s-expressions, want %v", len(sexps), n)
+	}
+}
+
+func TestParseQuote(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{src: "'x", want: "(quote x)"},
+		{src: "' x", want: "(quote x)"},
+		{src: "'\n(x)", want: "(quote (x))"},
+		{src: "'#| c |# ''x", want: "(quote (quote (quote x)))"},
+		{src: "(a ' b c)", want: "(a (quote b) c)"},
+	}
+	for _, tt := range tests {
+		tokens, err := tokenizer.Tokenize(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sexps, err := Parse(tokens)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.src, err)
+			continue
+		}
+		if len(sexps) != 1 || sexps[0].String() != tt.want {
+			t.Errorf("Parse(%q) = %v, want %v", tt.src, sexps, tt.want)
+		}
+	}
+	for _, src := range []string{"'", "' ", "(a ')"} {
+		tokens, err := tokenizer.Tokenize(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sexps, err := Parse(tokens); err == nil {
+			t.Errorf("Parse(%q) = %v, want an error", src, sexps)
+		}
+	}
+}
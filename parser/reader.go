@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/soishi1/toylisp/sexpressions"
+	"github.com/soishi1/toylisp/tokenizer"
+)
+
+// Reader reads s-expressions from an io.Reader one at a time, so that large
+// inputs can be processed without reading them all into memory.
+type Reader struct {
+	r *bufio.Reader
+	t *tokenizer.Tokenizer
+	// tokens are the tokens read but not parsed yet.
+	tokens []*tokenizer.Token
+	eof    bool
+}
+
+// ReadError is an error returned by the io.Reader a Reader reads from, as
+// opposed to a syntax error in what it read.
+type ReadError struct {
+	Err error
+}
+
+func (e *ReadError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+// NewReader returns a Reader that reads from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r), t: tokenizer.NewTokenizer()}
+}
+
+// Next returns the next s-expression. It returns io.EOF when there are no
+// more s-expressions, and a *ReadError if reading the input fails.
+func (r *Reader) Next() (*sexpressions.SExp, error) {
+	for {
+		if end, ok := formEnd(r.tokens); ok || r.eof {
+			return r.parse(end)
+		}
+		if err := r.readLine(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readLine tokenizes the next line of the input.
+func (r *Reader) readLine() error {
+	line, err := r.r.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return &ReadError{Err: err}
+	}
+	tokens, tokenizeErr := r.t.Feed(line)
+	if tokenizeErr != nil {
+		return tokenizeErr
+	}
+	r.tokens = append(r.tokens, tokens...)
+	if err == nil {
+		return nil
+	}
+	r.eof = true
+	tokens, err = r.t.Close()
+	if err != nil {
+		return err
+	}
+	r.tokens = append(r.tokens, tokens...)
+	return nil
+}
+
+// parse parses and removes the first end tokens.
+func (r *Reader) parse(end int) (*sexpressions.SExp, error) {
+	if end == 0 {
+		return nil, io.EOF
+	}
+	form := r.tokens[:end]
+	r.tokens = r.tokens[end:]
+	sexps, err := Parse(form)
+	if err != nil {
+		return nil, err
+	}
+	if len(sexps) != 1 {
+		return nil, fmt.Errorf("unmatched parens: tokens: %v", form)
+	}
+	return sexps[0], nil
+}
+
+// formEnd returns the index just after the first complete form in tokens,
+// including the spaces and comments before it, or 0 if there is no form. It
+// returns false if tokens end before the form does. An unmatched ')' counts
+// as a form so that Parse reports it.
+func formEnd(tokens []*tokenizer.Token) (end int, ok bool) {
+	depth := 0
+	started := false
+	for i, t := range tokens {
+		switch t.Type {
+		case tokenizer.Space, tokenizer.Comment:
+			continue
+		case tokenizer.OpenParen, tokenizer.OpenVector:
+			depth++
+		case tokenizer.CloseParen:
+			depth--
+		}
+		started = true
+		if depth <= 0 && t.Type != tokenizer.Quote {
+			return i + 1, true
+		}
+	}
+	if !started {
+		return 0, false
+	}
+	return len(tokens), false
+}
@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func readAll(r *Reader) ([]string, error) {
+	var forms []string
+	for {
+		sexp, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return forms, nil
+		}
+		if err != nil {
+			return forms, err
+		}
+		forms = append(forms, sexp.String())
+	}
+}
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{src: "", want: nil},
+		{src: " ; comment\n", want: nil},
+		{src: "1 (a b)\n'c", want: []string{"1", "(a b)", "(quote c)"}},
+		{src: "(a\n  (b\n c)) #(1\n2)", want: []string{"(a (b c))", "#(1 2)"}},
+		{src: "\"multi\nline\" #| block\ncomment |# x", want: []string{`"multi\nline"`, "x"}},
+		{src: "'\n(x) ' y '; c\n z", want: []string{"(quote (x))", "(quote y)", "(quote z)"}},
+	}
+	for _, tt := range tests {
+		// One byte at a time, so that each form is split across reads.
+		got, err := readAll(NewReader(iotest.OneByteReader(strings.NewReader(tt.src))))
+		if err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%q: got %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestReaderPos(t *testing.T) {
+	r := NewReader(strings.NewReader("(a)\n\n  (b\n c)"))
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	sexp, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sexp.Pos.Line != 3 || sexp.Pos.Column != 3 {
+		t.Errorf("got position %v, want 3:3", sexp.Pos)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	got, err := readAll(NewReader(strings.NewReader("(a) (b")))
+	if err == nil || len(got) != 1 {
+		t.Errorf("got %q and error %v, want (a) and an error for the unclosed form", got, err)
+	}
+	_, err = readAll(NewReader(iotest.ErrReader(errors.New("broken"))))
+	var readErr *ReadError
+	if !errors.As(err, &readErr) {
+		t.Errorf("got error %v, want *ReadError", err)
+	}
+}
@@ -0,0 +1,228 @@
+// Package printer renders s-expressions as indented text over multiple lines.
+package printer
+
+import (
+	"io"
+	"strings"
+
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// DefaultWidth is the line width used by Fprint and Sprint.
+const DefaultWidth = 80
+
+// bodyForms maps the names of forms with a body to the number of arguments
+// before the body. The arguments are kept on the line of the name, and the
+// body is indented by 2 spaces.
+var bodyForms = map[string]int{
+	"lambda":         1,
+	"let":            1,
+	"let*":           1,
+	"letrec":         1,
+	"define":         1,
+	"set":            1,
+	"set!":           1,
+	"defstruct":      1,
+	"module":         1,
+	"parameterize":   1,
+	"unwind-protect": 1,
+	"with-lock":      1,
+	"when":           1,
+	"unless":         1,
+	"case":           1,
+	"dolist":         1,
+	"dotimes":        1,
+	"do":             2,
+	"try":            0,
+	"select":         0,
+	"catch":          1,
+}
+
+// bindingForms are forms whose first list argument before the body is a
+// list of bindings, which are printed one per line.
+var bindingForms = map[string]bool{
+	"let":          true,
+	"let*":         true,
+	"letrec":       true,
+	"do":           true,
+	"parameterize": true,
+}
+
+// alignedForms are forms whose arguments after the first are aligned with
+// the first even if the name is short, such as the branches of if.
+var alignedForms = map[string]bool{
+	"if": true,
+}
+
+// Config controls how s-expressions are printed.
+type Config struct {
+	// Width is the line width. Expressions are printed in one line if they
+	// fit in it.
+	Width int
+}
+
+// Fprint writes sexp to w with the default config.
+func Fprint(w io.Writer, sexp *sexpressions.SExp) error {
+	return (&Config{Width: DefaultWidth}).Fprint(w, sexp)
+}
+
+// Sprint returns sexp printed with the default config.
+func Sprint(sexp *sexpressions.SExp) string {
+	return (&Config{Width: DefaultWidth}).Sprint(sexp)
+}
+
+// Fprint writes sexp to w. Expressions that don't fit in the line are broken
+// into lines indented according to the form.
+func (c *Config) Fprint(w io.Writer, sexp *sexpressions.SExp) error {
+	_, err := io.WriteString(w, c.Sprint(sexp))
+	return err
+}
+
+// Sprint returns sexp printed as by Fprint.
+func (c *Config) Sprint(sexp *sexpressions.SExp) string {
+	p := &printer{width: c.Width}
+	p.print(sexp, 0)
+	return p.b.String()
+}
+
+type printer struct {
+	b     strings.Builder
+	width int
+	// lines is the number of line breaks written.
+	lines int
+}
+
+// print writes sexp assuming it starts at column col, counted from 0.
+func (p *printer) print(sexp *sexpressions.SExp, col int) {
+	flat := sexp.String()
+	if col+len(flat) <= p.width {
+		p.b.WriteString(flat)
+		return
+	}
+	open := "("
+	elems, ok := sexp.AsList()
+	if !ok {
+		elems, ok = sexp.AsVector()
+		open = "#("
+	}
+	if !ok || len(elems) == 0 {
+		// Atoms, pairs and maps are always printed in one line.
+		p.b.WriteString(flat)
+		return
+	}
+	p.b.WriteString(open)
+	col += len(open)
+	name, isSymbol := elems[0].AsSymbol()
+	if open != "(" || !isSymbol {
+		p.fill(elems, col)
+		p.b.WriteString(")")
+		return
+	}
+	p.b.WriteString(name)
+	args := elems[1:]
+	if n, ok := bodyForms[name]; ok {
+		if name == "let" && len(args) > 0 {
+			if _, ok := args[0].AsSymbol(); ok {
+				// Named let has the name before the bindings.
+				n++
+			}
+		}
+		if n > len(args) {
+			n = len(args)
+		}
+		bindings := bindingForms[name]
+		broken := false
+		for i, arg := range args[:n] {
+			// Arguments that don't fit in the line are aligned with the
+			// first one.
+			if i > 0 && (broken || p.lineLen()+1+len(arg.String()) > p.width) {
+				p.newline(col + len(name) + 1)
+			} else {
+				p.b.WriteString(" ")
+			}
+			lines := p.lines
+			if _, ok := arg.AsList(); ok && bindings {
+				p.printBindings(arg, p.lineLen())
+				bindings = false
+			} else {
+				p.print(arg, p.lineLen())
+			}
+			broken = p.lines > lines
+		}
+		if len(args) > n {
+			p.newline(col + 1)
+			p.printLines(args[n:], col+1)
+		}
+		p.b.WriteString(")")
+		return
+	}
+	if len(args) > 0 {
+		// Align the arguments with the first one, unless it would push them
+		// too far to the right.
+		argCol := col + len(name) + 1
+		if argCol > p.width/2 && !alignedForms[name] {
+			p.newline(col + 1)
+			p.printLines(args, col+1)
+		} else {
+			p.b.WriteString(" ")
+			p.printLines(args, argCol)
+		}
+	}
+	p.b.WriteString(")")
+}
+
+// printBindings writes the list of bindings at column col with a binding per
+// line unless it fits in the line.
+func (p *printer) printBindings(sexp *sexpressions.SExp, col int) {
+	flat := sexp.String()
+	elems, _ := sexp.AsList()
+	if col+len(flat) <= p.width || len(elems) == 0 {
+		p.b.WriteString(flat)
+		return
+	}
+	p.b.WriteString("(")
+	p.printLines(elems, col+1)
+	p.b.WriteString(")")
+}
+
+// printLines writes sexps on separate lines starting at column col.
+func (p *printer) printLines(sexps []*sexpressions.SExp, col int) {
+	for i, sexp := range sexps {
+		if i > 0 {
+			p.newline(col)
+		}
+		p.print(sexp, col)
+	}
+}
+
+// fill writes sexps starting at column col, putting as many as fit in each
+// line as in a paragraph. It is used for data rather than code.
+// An expression after one printed over multiple lines starts a new line.
+func (p *printer) fill(sexps []*sexpressions.SExp, col int) {
+	broken := false
+	for i, sexp := range sexps {
+		if i > 0 {
+			if !broken && p.lineLen()+1+len(sexp.String()) <= p.width {
+				p.b.WriteString(" ")
+			} else {
+				p.newline(col)
+			}
+		}
+		lines := p.lines
+		p.print(sexp, p.lineLen())
+		broken = p.lines > lines
+	}
+}
+
+func (p *printer) newline(col int) {
+	p.lines++
+	p.b.WriteString("\n")
+	p.b.WriteString(strings.Repeat(" ", col))
+}
+
+// lineLen returns the length of the last line written so far relative to its
+// start.
+func (p *printer) lineLen() int {
+	s := p.b.String()
+	return len(s) - strings.LastIndexByte(s, '\n') - 1
+}
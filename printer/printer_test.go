@@ -0,0 +1,87 @@
+package printer
+
+import (
+	"testing"
+
+	"github.com/soishi1/toylisp/parser"
+	"github.com/soishi1/toylisp/tokenizer"
+)
+
+func TestSprint(t *testing.T) {
+	tests := []struct {
+		src   string
+		width int
+		want  string
+	}{
+		{src: "(add 1 2)", width: 80, want: "(add 1 2)"},
+		{
+			src:   "(if (less x 0) (sub 0 x) x)",
+			width: 20,
+			want:  "(if (less x 0)\n    (sub 0 x)\n    x)",
+		},
+		{
+			src:   "(lambda (x y) (print x) (add x y))",
+			width: 20,
+			want:  "(lambda (x y)\n  (print x)\n  (add x y))",
+		},
+		{
+			src:   "(define f (lambda (x) (add x 1)))",
+			width: 25,
+			want:  "(define f\n  (lambda (x) (add x 1)))",
+		},
+		{
+			src:   "(let ((a 1) (b 2)) (add a b))",
+			width: 20,
+			want:  "(let ((a 1) (b 2))\n  (add a b))",
+		},
+		{
+			src:   "(let ((alpha 1) (beta 2)) (add alpha beta))",
+			width: 20,
+			want:  "(let ((alpha 1)\n      (beta 2))\n  (add alpha beta))",
+		},
+		{
+			src:   "(let loop ((i 0)) (when (less i 3) (loop (add i 1))))",
+			width: 30,
+			want:  "(let loop ((i 0))\n  (when (less i 3)\n    (loop (add i 1))))",
+		},
+		{
+			src:   "(let loop ((index 0) (total 0)) (loop 1 2))",
+			width: 30,
+			want:  "(let loop\n     ((index 0) (total 0))\n  (loop 1 2))",
+		},
+		{
+			src:   "(let loop ((index 0) (total 0)) (loop 1 2))",
+			width: 20,
+			want:  "(let loop\n     ((index 0)\n      (total 0))\n  (loop 1 2))",
+		},
+		{
+			src:   "(do ((i 0 (add i 1))) ((eq? i 10) i) (print i))",
+			width: 30,
+			want:  "(do ((i 0 (add i 1)))\n    ((eq? i 10) i)\n  (print i))",
+		},
+		{
+			src:   "(1 2 3 4 5 6 7 8 9 10)",
+			width: 10,
+			want:  "(1 2 3 4 5\n 6 7 8 9\n 10)",
+		},
+		{
+			src:   "#(1 2 3 4 5 6)",
+			width: 10,
+			want:  "#(1 2 3 4\n  5 6)",
+		},
+	}
+	for _, tt := range tests {
+		tokens, err := tokenizer.Tokenize(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sexps, err := parser.Parse(tokens)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := (&Config{Width: tt.width}).Sprint(sexps[0])
+		if got != tt.want {
+			t.Errorf("Sprint(%v) with width %v =\n%s\nwant\n%s", tt.src, tt.width, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package sexpressions
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+	"math"
+)
+
+// Equal reports whether s and other have the same structure and contents.
+// Objects are equal only if they are the same object, and maps only if they
+// are the same map.
+func (s *SExp) Equal(other *SExp) bool {
+	if s.IsNil() && other.IsNil() {
+		return true
+	}
+	if s.Type != other.Type {
+		return false
+	}
+	switch s.Type {
+	case ListType, VectorType:
+		sList, otherList := s.elems(), other.elems()
+		if len(sList) != len(otherList) {
+			return false
+		}
+		for i := range sList {
+			if !sList[i].Equal(otherList[i]) {
+				return false
+			}
+		}
+		return true
+	case PairType:
+		sPair, _ := s.AsPair()
+		otherPair, _ := other.AsPair()
+		return sPair.Car.Equal(otherPair.Car) && sPair.Cdr.Equal(otherPair.Cdr)
+	case BigIntType:
+		sInt, _ := s.AsBigInt()
+		otherInt, _ := other.AsBigInt()
+		return sInt.Cmp(otherInt) == 0
+	case SymbolType:
+		sSymbol, _ := s.AsSymbolObject()
+		otherSymbol, _ := other.AsSymbolObject()
+		return sSymbol == otherSymbol
+	}
+	return s.Value == other.Value
+}
+
+// Hash returns a hash of s consistent with Equal: s.Equal(other) implies
+// s.Hash() == other.Hash().
+func (s *SExp) Hash() uint64 {
+	h := fnv.New64a()
+	s.writeHash(h)
+	return h.Sum64()
+}
+
+func (s *SExp) writeHash(h hash.Hash64) {
+	if s.IsNil() {
+		h.Write([]byte{byte(ListType)})
+		return
+	}
+	h.Write([]byte{byte(s.Type)})
+	var buf [8]byte
+	switch s.Type {
+	case ListType, VectorType:
+		for _, elem := range s.elems() {
+			elem.writeHash(h)
+		}
+	case PairType:
+		pair, _ := s.AsPair()
+		pair.Car.writeHash(h)
+		pair.Cdr.writeHash(h)
+	case IntType:
+		binary.LittleEndian.PutUint64(buf[:], uint64(s.Value.(int)))
+		h.Write(buf[:])
+	case FloatType:
+		// Equal floats must hash the same although -0.0 and 0.0 differ in
+		// bits, as do NaNs with different payloads.
+		f := s.Value.(float64)
+		if f == 0 {
+			f = 0
+		} else if math.IsNaN(f) {
+			f = math.NaN()
+		}
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(f))
+		h.Write(buf[:])
+	case BigIntType:
+		b, _ := s.AsBigInt()
+		h.Write(b.Bytes())
+		if b.Sign() < 0 {
+			h.Write([]byte{'-'})
+		}
+	case SymbolType:
+		name, _ := s.AsSymbol()
+		h.Write([]byte(name))
+	case KeywordType, StringType:
+		h.Write([]byte(s.Value.(string)))
+	case BoolType:
+		if s.Value.(bool) {
+			h.Write([]byte{1})
+		}
+	case CharType:
+		binary.LittleEndian.PutUint32(buf[:4], uint32(s.Value.(rune)))
+		h.Write(buf[:4])
+	}
+	// Maps and objects are equal only to themselves, so the type is enough.
+}
@@ -0,0 +1,62 @@
+package sexpressions
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	emptyList := &SExp{Type: ListType}
+	tests := []struct {
+		name string
+		x, y *SExp
+		want bool
+	}{
+		{"nil and nil", emptyList, NewList(), true},
+		{"nil and non-empty list", emptyList, NewList(NewInt(1)), false},
+		{"non-empty list and nil", NewList(NewInt(1), NewInt(2)), emptyList, false},
+		{"empty list and non-empty list", NewList(), NewList(NewInt(1)), false},
+		{"same lists", NewList(NewInt(1), NewString("a")), NewList(NewInt(1), NewString("a")), true},
+		{"different lists", NewList(NewInt(1)), NewList(NewInt(2)), false},
+		{"nested nil", NewList(emptyList), NewList(NewList()), true},
+		{"vectors", NewVector(NewInt(1)), NewVector(NewInt(1)), true},
+		{"empty vector and vector", &SExp{Type: VectorType}, NewVector(NewInt(1)), false},
+		{"list and vector", NewList(NewInt(1)), NewVector(NewInt(1)), false},
+		{"int and float", NewInt(1), NewFloat(1), false},
+		{"symbols", NewSymbol("a"), NewSymbol("a"), true},
+		{"zero and negative zero", NewFloat(0), NewFloat(math.Copysign(0, -1)), true},
+		{"negative zeros in lists", NewList(NewFloat(math.Copysign(0, -1))), NewList(NewFloat(0)), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.x.Equal(tt.y); got != tt.want {
+				t.Errorf("%v.Equal(%v) = %v, want %v", tt.x, tt.y, got, tt.want)
+			}
+			if tt.want && tt.x.Hash() != tt.y.Hash() {
+				t.Errorf("%v and %v are equal but have different hashes", tt.x, tt.y)
+			}
+		})
+	}
+}
+
+func TestHashEmpty(t *testing.T) {
+	for _, s := range []*SExp{{Type: ListType}, {Type: VectorType}, NewList(), NewVector()} {
+		s.Hash()
+	}
+}
+
+func TestHashNaN(t *testing.T) {
+	x := NewFloat(math.NaN())
+	y := NewFloat(math.Float64frombits(math.Float64bits(math.NaN()) | 1))
+	if x.Hash() != y.Hash() {
+		t.Errorf("NaNs with different payloads have different hashes")
+	}
+}
+
+func TestMapNegativeZero(t *testing.T) {
+	m := NewMap()
+	m.Set(NewFloat(0), NewInt(1))
+	if v, ok := m.Get(NewFloat(math.Copysign(0, -1))); !ok || !v.Equal(NewInt(1)) {
+		t.Errorf("Get(-0.0) = %v, %v, want 1, true", v, ok)
+	}
+}
@@ -0,0 +1,173 @@
+package sexpressions
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+)
+
+// ToJSON converts s to a value that encoding/json can marshal. Lists and
+// vectors become arrays, and maps and association lists such as
+// ((a . 1) (b . 2)) become objects whose keys are strings, symbols or
+// keywords. Symbols, keywords and characters become strings. The empty list
+// becomes an empty array.
+func ToJSON(s *SExp) (interface{}, error) {
+	switch s.Type {
+	case IntType, FloatType, StringType, BoolType:
+		if f, ok := s.AsFloat(); ok && (math.IsInf(f, 0) || math.IsNaN(f)) {
+			return nil, fmt.Errorf("%v can't be represented in JSON", s)
+		}
+		return s.Value, nil
+	case BigIntType:
+		return s.Value.(*big.Int), nil
+	case SymbolType, KeywordType, CharType:
+		key, _ := jsonKey(s)
+		return key, nil
+	case ListType, VectorType:
+		elems := s.elems()
+		if s.Type == ListType && isAlist(elems) {
+			object := make(map[string]interface{}, len(elems))
+			for _, elem := range elems {
+				pair, _ := elem.AsPair()
+				if err := setJSONField(object, pair.Car, pair.Cdr); err != nil {
+					return nil, err
+				}
+			}
+			return object, nil
+		}
+		array := make([]interface{}, len(elems))
+		for i, elem := range elems {
+			v, err := ToJSON(elem)
+			if err != nil {
+				return nil, err
+			}
+			array[i] = v
+		}
+		return array, nil
+	case MapType:
+		m := s.Value.(*Map)
+		object := make(map[string]interface{}, m.Len())
+		for _, key := range m.Keys() {
+			value, _ := m.Get(key)
+			if err := setJSONField(object, key, value); err != nil {
+				return nil, err
+			}
+		}
+		return object, nil
+	}
+	return nil, fmt.Errorf("%v can't be represented in JSON", s)
+}
+
+// FromJSON converts v, a value decoded by encoding/json such as with
+// json.Unmarshal into an interface{}, to an s-expression. Arrays become lists,
+// objects become maps with string keys, and null becomes the empty list.
+// Numbers without fraction become integers, so decoding with
+// json.Decoder.UseNumber keeps large integers exact.
+func FromJSON(v interface{}) (*SExp, error) {
+	switch v := v.(type) {
+	case nil:
+		return NewList(), nil
+	case bool:
+		return NewBool(v), nil
+	case string:
+		return NewString(v), nil
+	case int:
+		return NewInt(v), nil
+	case *big.Int:
+		return NewBigInt(new(big.Int).Set(v)), nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) <= 1<<53 {
+			return NewInt(int(v)), nil
+		}
+		return NewFloat(v), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil && int64(int(i)) == i {
+			return NewInt(int(i)), nil
+		}
+		if b, ok := new(big.Int).SetString(v.String(), 10); ok {
+			return NewBigInt(b), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON number %v: %v", v, err)
+		}
+		return NewFloat(f), nil
+	case []interface{}:
+		list := make([]*SExp, len(v))
+		for i := range v {
+			elem, err := FromJSON(v[i])
+			if err != nil {
+				return nil, err
+			}
+			list[i] = elem
+		}
+		return NewList(list...), nil
+	case map[string]interface{}:
+		// Go maps are unordered, so sort keys to make the result deterministic.
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		m := NewMap()
+		for _, key := range keys {
+			value, err := FromJSON(v[key])
+			if err != nil {
+				return nil, err
+			}
+			m.Set(NewString(key), value)
+		}
+		return NewMapSExp(m), nil
+	}
+	return nil, fmt.Errorf("unsupported JSON value %v of type %T", v, v)
+}
+
+// isAlist reports whether elems are the entries of an association list, that
+// is, pairs whose cars can be object keys.
+func isAlist(elems []*SExp) bool {
+	if len(elems) == 0 {
+		return false
+	}
+	for _, elem := range elems {
+		pair, ok := elem.AsPair()
+		if !ok {
+			return false
+		}
+		if _, ok := jsonKey(pair.Car); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonKey returns the object key that s represents.
+func jsonKey(s *SExp) (string, bool) {
+	if str, ok := s.AsString(); ok {
+		return str, true
+	}
+	if symbol, ok := s.AsSymbol(); ok {
+		return symbol, true
+	}
+	if keyword, ok := s.AsKeyword(); ok {
+		return keyword, true
+	}
+	if c, ok := s.AsChar(); ok {
+		return string(c), true
+	}
+	return "", false
+}
+
+func setJSONField(object map[string]interface{}, key, value *SExp) error {
+	k, ok := jsonKey(key)
+	if !ok {
+		return fmt.Errorf("map key %v can't be a JSON object key", key)
+	}
+	v, err := ToJSON(value)
+	if err != nil {
+		return err
+	}
+	object[k] = v
+	return nil
+}
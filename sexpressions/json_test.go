@@ -0,0 +1,36 @@
+package sexpressions
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestToJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		s    *SExp
+		want string
+	}{
+		{"nil", &SExp{Type: ListType}, "[]"},
+		{"empty list", NewList(), "[]"},
+		{"empty vector", &SExp{Type: VectorType}, "[]"},
+		{"nested nil", NewList(&SExp{Type: ListType}, NewInt(1)), "[[],1]"},
+		{"list", NewList(NewInt(1), NewString("a"), NewBool(true)), `[1,"a",true]`},
+		{"alist", NewList(Cons(NewSymbol("a"), NewInt(1))), `{"a":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := ToJSON(tt.s)
+			if err != nil {
+				t.Fatalf("ToJSON(%v) failed: %v", tt.s, err)
+			}
+			b, err := json.Marshal(v)
+			if err != nil {
+				t.Fatalf("json.Marshal(%v) failed: %v", v, err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("ToJSON(%v) = %s, want %s", tt.s, b, tt.want)
+			}
+		})
+	}
+}
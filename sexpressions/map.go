@@ -0,0 +1,69 @@
+package sexpressions
+
+import "sync"
+
+// Map is a hash table whose keys and values are s-expressions. Keys are
+// compared by Equal, and iteration follows insertion order. It is safe for
+// concurrent use, since tasks may share maps.
+type Map struct {
+	mu sync.RWMutex
+	// index maps hashes of keys to their indices in keys.
+	index map[uint64][]int
+	keys  []*SExp
+	vals  []*SExp
+}
+
+// NewMap returns an empty map.
+func NewMap() *Map {
+	return &Map{index: make(map[uint64][]int)}
+}
+
+// find returns the index of key in m.keys. m.mu must be held.
+func (m *Map) find(key *SExp, hash uint64) (int, bool) {
+	for _, i := range m.index[hash] {
+		if m.keys[i].Equal(key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Get returns the value for key.
+func (m *Map) Get(key *SExp) (value *SExp, ok bool) {
+	hash := key.Hash()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i, ok := m.find(key, hash)
+	if !ok {
+		return nil, false
+	}
+	return m.vals[i], true
+}
+
+// Set sets the value for key, replacing the existing one if any.
+func (m *Map) Set(key, value *SExp) {
+	hash := key.Hash()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i, ok := m.find(key, hash); ok {
+		m.vals[i] = value
+		return
+	}
+	m.index[hash] = append(m.index[hash], len(m.keys))
+	m.keys = append(m.keys, key)
+	m.vals = append(m.vals, value)
+}
+
+// Keys returns the keys in insertion order.
+func (m *Map) Keys() []*SExp {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]*SExp{}, m.keys...)
+}
+
+// Len returns the number of entries.
+func (m *Map) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.keys)
+}
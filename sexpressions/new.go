@@ -0,0 +1,100 @@
+package sexpressions
+
+import (
+	"fmt"
+	"math/big"
+	"unicode/utf8"
+)
+
+// NewInt returns an integer.
+func NewInt(i int) *SExp {
+	return &SExp{Type: IntType, Value: i}
+}
+
+// NewBigInt returns an integer with the value of b, which is an IntType if it
+// fits in int. It panics if b is nil.
+func NewBigInt(b *big.Int) *SExp {
+	if b == nil {
+		panic("sexpressions: NewBigInt with nil")
+	}
+	if b.IsInt64() && int64(int(b.Int64())) == b.Int64() {
+		return NewInt(int(b.Int64()))
+	}
+	return &SExp{Type: BigIntType, Value: b}
+}
+
+// NewFloat returns a float.
+func NewFloat(f float64) *SExp {
+	return &SExp{Type: FloatType, Value: f}
+}
+
+// NewString returns a string.
+func NewString(s string) *SExp {
+	return &SExp{Type: StringType, Value: s}
+}
+
+// NewBool returns #t or #f.
+func NewBool(b bool) *SExp {
+	return &SExp{Type: BoolType, Value: b}
+}
+
+// NewChar returns a character. It panics if r is not a valid rune.
+func NewChar(r rune) *SExp {
+	if !utf8.ValidRune(r) {
+		panic(fmt.Sprintf("sexpressions: NewChar with invalid rune %U", r))
+	}
+	return &SExp{Type: CharType, Value: r}
+}
+
+// NewSymbol returns the interned symbol named name. It panics if name is
+// empty.
+func NewSymbol(name string) *SExp {
+	if name == "" {
+		panic("sexpressions: NewSymbol with empty name")
+	}
+	return &SExp{Type: SymbolType, Value: Intern(name)}
+}
+
+// NewKeyword returns the keyword :name. It panics if name is empty.
+func NewKeyword(name string) *SExp {
+	if name == "" {
+		panic("sexpressions: NewKeyword with empty name")
+	}
+	return &SExp{Type: KeywordType, Value: name}
+}
+
+// NewList returns a list of items. It panics if any of them is nil. A slice
+// passed as items... is used as is without copying.
+func NewList(items ...*SExp) *SExp {
+	checkItems("NewList", items)
+	if items == nil {
+		items = []*SExp{}
+	}
+	return &SExp{Type: ListType, Value: items}
+}
+
+// NewVector returns a vector of items. It panics if any of them is nil. A
+// slice passed as items... is used as is without copying.
+func NewVector(items ...*SExp) *SExp {
+	checkItems("NewVector", items)
+	if items == nil {
+		items = []*SExp{}
+	}
+	return &SExp{Type: VectorType, Value: items}
+}
+
+// NewMapSExp returns an s-expression holding m.
+func NewMapSExp(m *Map) *SExp {
+	if m == nil {
+		panic("sexpressions: NewMapSExp with nil")
+	}
+	return &SExp{Type: MapType, Value: m}
+}
+
+func checkItems(name string, items []*SExp) {
+	for i, item := range items {
+		if item == nil {
+			panic(fmt.Sprintf("sexpressions: %s with nil item %d", name, i))
+		}
+	}
+}
@@ -3,25 +3,146 @@ package sexpressions
 
 import (
 	"fmt"
+	"math/big"
+	"strconv"
 	"strings"
+	"sync/atomic"
 )
 
 type Type int
 
 const (
 	ListType = iota
+	// SymbolType represents symbols. Value is a *Symbol. A string Value, which
+	// earlier versions used, is still accepted as the interned symbol of that
+	// name.
 	SymbolType
+	// KeywordType represents symbols prefixed by ':' such as :name, which
+	// evaluate to themselves. Value is the name without ':'.
+	KeywordType
 	IntType
+	FloatType
+	// BigIntType represents integers that don't fit in int. Value is a *big.Int.
+	BigIntType
 	StringType
+	BoolType
+	// CharType represents characters. Value is a rune.
+	CharType
+	// PairType represents a cons cell whose cdr is not a list, such as (a . b).
+	// Value is a *Pair. Cons cells whose cdr is a list are represented as lists.
+	PairType
+	// VectorType represents vectors such as #(1 2 3). Value is a []*SExp.
+	VectorType
+	// MapType represents hash tables. Value is a *Map.
+	MapType
+	// ObjectType holds a value that has no textual representation, such as a
+	// function. Value is a fmt.Stringer.
+	ObjectType
 )
 
+// CharNames maps names of characters that can be written as #\name to the
+// characters.
+var CharNames = map[string]rune{
+	"nul":     0,
+	"tab":     '\t',
+	"newline": '\n',
+	"return":  '\r',
+	"space":   ' ',
+}
+
 type SExp struct {
 	Type  Type
 	Value interface{}
+	// Pos is where the expression starts in the source it was parsed from.
+	// It is the zero Pos for expressions made at run time.
+	Pos Pos
+	// cells is the array the elements of a list made by Cons are in, or nil.
+	cells *listCells
+}
+
+// Pos is a position in a source.
+type Pos struct {
+	// Line and Column are 1-based. Column counts bytes.
+	Line, Column int
+}
+
+// IsValid reports whether p is a known position.
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Pos) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Pair is a cons cell.
+type Pair struct {
+	Car, Cdr *SExp
+}
+
+// listCells is the array lists made by Cons keep their elements in. The
+// elements are at the end, and the room at the front lets Cons prepend to the
+// list without copying it, so that the list shares the tail.
+type listCells struct {
+	cells []*SExp
+	// front is the index of the first cell in use. Cons claims the cell
+	// before it atomically, so that lists sharing a tail never overwrite each
+	// other's elements.
+	front int64
+}
+
+// Cons returns a cons cell of car and cdr. If cdr is a list, the result is a
+// list whose first element is car.
+//
+// The result shares cdr instead of copying it if cdr is the newest list made
+// by Cons on its array, so that building a list by consing takes amortized
+// constant time per element.
+func Cons(car, cdr *SExp) *SExp {
+	s, _ := ConsAlloc(car, cdr, nil)
+	return s
+}
+
+// ConsAlloc is like Cons, but calls alloc, unless it is nil, with the number
+// of cells it is about to allocate, and fails with the error alloc returns.
+// It allocates no cells if it shares cdr.
+func ConsAlloc(car, cdr *SExp, alloc func(cells int) error) (*SExp, error) {
+	list, ok := cdr.AsList()
+	if !ok {
+		if alloc != nil {
+			if err := alloc(1); err != nil {
+				return nil, err
+			}
+		}
+		return &SExp{
+			Type:  PairType,
+			Value: &Pair{Car: car, Cdr: cdr},
+		}, nil
+	}
+	if c := cdr.cells; c != nil && len(list) > 0 {
+		start := len(c.cells) - len(list)
+		if start > 0 && &c.cells[start] == &list[0] && atomic.CompareAndSwapInt64(&c.front, int64(start), int64(start-1)) {
+			c.cells[start-1] = car
+			return &SExp{Type: ListType, Value: c.cells[start-1:], cells: c}, nil
+		}
+	}
+	// Double the room each time the list is copied, like append does.
+	n := len(list) + 1
+	if alloc != nil {
+		if err := alloc(2 * n); err != nil {
+			return nil, err
+		}
+	}
+	c := &listCells{cells: make([]*SExp, 2*n), front: int64(n)}
+	c.cells[n] = car
+	copy(c.cells[n+1:], list)
+	return &SExp{Type: ListType, Value: c.cells[n:], cells: c}, nil
 }
 
 func (s *SExp) AsList() (value []*SExp, ok bool) {
-	if s.Type != ListType {
+	if s == nil || s.Type != ListType {
 		return nil, false
 	}
 	if s.Value == nil {
@@ -31,26 +152,114 @@ func (s *SExp) AsList() (value []*SExp, ok bool) {
 }
 
 func (s *SExp) AsSymbol() (value string, ok bool) {
-	if s.Type != SymbolType {
+	if s == nil || s.Type != SymbolType {
+		return "", false
+	}
+	if name, ok := s.Value.(string); ok {
+		return name, true
+	}
+	return s.Value.(*Symbol).Name, true
+}
+
+// AsSymbolObject returns the symbol object, which is the same pointer for
+// symbols with the same name unless they are uninterned.
+func (s *SExp) AsSymbolObject() (value *Symbol, ok bool) {
+	if s == nil || s.Type != SymbolType {
+		return nil, false
+	}
+	if name, ok := s.Value.(string); ok {
+		return Intern(name), true
+	}
+	return s.Value.(*Symbol), true
+}
+
+func (s *SExp) AsKeyword() (value string, ok bool) {
+	if s == nil || s.Type != KeywordType {
 		return "", false
 	}
 	return s.Value.(string), true
 }
 
 func (s *SExp) AsInt() (value int, ok bool) {
-	if s.Type != IntType {
+	if s == nil || s.Type != IntType {
 		return 0, false
 	}
 	return s.Value.(int), true
 }
 
+func (s *SExp) AsFloat() (value float64, ok bool) {
+	if s == nil || s.Type != FloatType {
+		return 0, false
+	}
+	return s.Value.(float64), true
+}
+
+func (s *SExp) AsBigInt() (value *big.Int, ok bool) {
+	if s == nil || s.Type != BigIntType {
+		return nil, false
+	}
+	return s.Value.(*big.Int), true
+}
+
 func (s *SExp) AsString() (value string, ok bool) {
-	if s.Type != StringType {
+	if s == nil || s.Type != StringType {
 		return "", false
 	}
 	return s.Value.(string), true
 }
 
+func (s *SExp) AsBool() (value bool, ok bool) {
+	if s == nil || s.Type != BoolType {
+		return false, false
+	}
+	return s.Value.(bool), true
+}
+
+func (s *SExp) AsChar() (value rune, ok bool) {
+	if s == nil || s.Type != CharType {
+		return 0, false
+	}
+	return s.Value.(rune), true
+}
+
+func (s *SExp) AsPair() (value *Pair, ok bool) {
+	if s == nil || s.Type != PairType {
+		return nil, false
+	}
+	return s.Value.(*Pair), true
+}
+
+// AsVector returns a copy of the elements of the vector s, so that they can
+// be read while other tasks set them with VectorSet.
+func (s *SExp) AsVector() (value []*SExp, ok bool) {
+	if s == nil || s.Type != VectorType {
+		return nil, false
+	}
+	if s.Value == nil {
+		return nil, true
+	}
+	vectorMu.RLock()
+	defer vectorMu.RUnlock()
+	return append([]*SExp{}, s.Value.([]*SExp)...), true
+}
+
+// elems returns the elements of a list or a vector. Unlike asserting Value
+// directly, it works for the empty list, whose Value may be nil.
+func (s *SExp) elems() []*SExp {
+	if list, ok := s.AsList(); ok {
+		return list
+	}
+	elems, _ := s.AsVector()
+	return elems
+}
+
+func (s *SExp) AsMap() (value *Map, ok bool) {
+	if s == nil || s.Type != MapType {
+		return nil, false
+	}
+	return s.Value.(*Map), true
+}
+
 func (s *SExp) IsNil() bool {
 	list, ok := s.AsList()
 	return ok && len(list) == 0
@@ -63,13 +272,74 @@ func (s *SExp) String() string {
 			strs = append(strs, list[i].String())
 		}
 		return fmt.Sprintf("(%s)", strings.Join(strs, " "))
+	} else if pair, ok := s.AsPair(); ok {
+		strs := []string{pair.Car.String()}
+		cdr := pair.Cdr
+		for {
+			if next, ok := cdr.AsPair(); ok {
+				strs = append(strs, next.Car.String())
+				cdr = next.Cdr
+				continue
+			}
+			if list, ok := cdr.AsList(); ok {
+				for i := range list {
+					strs = append(strs, list[i].String())
+				}
+			} else {
+				strs = append(strs, ".", cdr.String())
+			}
+			break
+		}
+		return fmt.Sprintf("(%s)", strings.Join(strs, " "))
 	} else if value, ok := s.AsInt(); ok {
 		return fmt.Sprintf("%v", value)
+	} else if value, ok := s.AsFloat(); ok {
+		return formatFloat(value)
+	} else if value, ok := s.AsBigInt(); ok {
+		return value.String()
+	} else if value, ok := s.AsKeyword(); ok {
+		return ":" + value
 	} else if value, ok := s.AsSymbol(); ok {
 		return fmt.Sprintf("%v", value)
 	} else if value, ok := s.AsString(); ok {
-		return fmt.Sprintf("%q", value)
+		return quoteString(value)
+	} else if value, ok := s.AsBool(); ok {
+		if value {
+			return "#t"
+		}
+		return "#f"
+	} else if value, ok := s.AsChar(); ok {
+		for name, r := range CharNames {
+			if r == value {
+				return `#\` + name
+			}
+		}
+		return `#\` + string(value)
+	} else if vector, ok := s.AsVector(); ok {
+		var strs []string
+		for i := range vector {
+			strs = append(strs, vector[i].String())
+		}
+		return fmt.Sprintf("#(%s)", strings.Join(strs, " "))
+	} else if m, ok := s.AsMap(); ok {
+		strs := []string{"#<map"}
+		for _, key := range m.Keys() {
+			value, _ := m.Get(key)
+			strs = append(strs, Cons(key, value).String())
+		}
+		return strings.Join(strs, " ") + ">"
+	} else if s.Type == ObjectType {
+		return fmt.Sprintf("%v", s.Value)
 	} else {
 		return fmt.Sprintf("%+v", value)
 	}
 }
+
+// formatFloat formats f so that it is read back as a float, not an int.
+func formatFloat(f float64) string {
+	str := strconv.FormatFloat(f, 'g', -1, 64)
+	if strings.ContainsAny(str, ".eIN") {
+		return str
+	}
+	return str + ".0"
+}
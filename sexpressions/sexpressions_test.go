@@ -0,0 +1,47 @@
+package sexpressions
+
+import "testing"
+
+func TestConsSharesTail(t *testing.T) {
+	list := NewList()
+	for i := 3; i > 0; i-- {
+		list = Cons(NewInt(i), list)
+	}
+	tail := list
+	list = Cons(NewInt(0), list)
+	if got, _ := list.AsList(); &got[1] != &tail.Value.([]*SExp)[0] {
+		t.Errorf("Cons(0, %v) copied the tail", tail)
+	}
+	// Another list consed onto the same tail must not overwrite the 1st one.
+	other := Cons(NewInt(-1), tail)
+	if want := "(0 1 2 3)"; list.String() != want {
+		t.Errorf("list = %v, want %v", list, want)
+	}
+	if want := "(-1 1 2 3)"; other.String() != want {
+		t.Errorf("other = %v, want %v", other, want)
+	}
+	if want := "(1 2 3)"; tail.String() != want {
+		t.Errorf("tail = %v, want %v", tail, want)
+	}
+}
+
+func TestConsAlloc(t *testing.T) {
+	var cells int
+	alloc := func(n int) error {
+		cells += n
+		return nil
+	}
+	list := NewList()
+	for i := 0; i < 1000; i++ {
+		var err error
+		if list, err = ConsAlloc(NewInt(i), list, alloc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cells > 4000 {
+		t.Errorf("consing 1000 elements allocated %v cells, want at most 4000", cells)
+	}
+	if _, err := ConsAlloc(NewInt(0), NewInt(1), alloc); err != nil {
+		t.Fatal(err)
+	}
+}
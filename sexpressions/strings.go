@@ -0,0 +1,70 @@
+package sexpressions
+
+import (
+	"fmt"
+	"strings"
+)
+
+// stringEscapes maps characters following '\\' in string literals to the
+// characters they represent.
+var stringEscapes = map[byte]byte{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'"':  '"',
+	'\\': '\\',
+}
+
+// UnquoteString interprets s, a double-quoted string literal, and returns the
+// string it represents.
+func UnquoteString(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("string literal must be double-quoted: %v", s)
+	}
+	s = s[1 : len(s)-1]
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 >= len(s) {
+			return "", fmt.Errorf("string literal ends with \\")
+		}
+		c, ok := stringEscapes[s[i+1]]
+		if !ok {
+			return "", fmt.Errorf("unknown escape sequence \\%c", s[i+1])
+		}
+		b.WriteByte(c)
+		i++
+	}
+	return b.String(), nil
+}
+
+// quoteString returns a double-quoted string literal that UnquoteString turns
+// back into s.
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		case 0:
+			b.WriteString(`\0`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,52 @@
+package sexpressions
+
+import "sync"
+
+// Symbol is the canonical object for a symbol. Interned symbols with the same
+// name are the same pointer, so they can be compared and hashed by pointer.
+type Symbol struct {
+	Name string
+}
+
+func (s *Symbol) String() string {
+	return s.Name
+}
+
+var symbolTable = struct {
+	sync.Mutex
+	symbols map[string]*Symbol
+}{
+	symbols: make(map[string]*Symbol),
+}
+
+// Intern returns the interned symbol named name.
+//
+// Interned symbols are never freed, so the table grows with every distinct
+// name interned during the life of the process, such as names read from
+// input. Use Interned to look up a symbol by name without adding it, and
+// NewUninternedSymbol for symbols that don't need to be shared.
+func Intern(name string) *Symbol {
+	symbolTable.Lock()
+	defer symbolTable.Unlock()
+	if symbol, ok := symbolTable.symbols[name]; ok {
+		return symbol
+	}
+	symbol := &Symbol{Name: name}
+	symbolTable.symbols[name] = symbol
+	return symbol
+}
+
+// Interned returns the interned symbol named name if there is one, without
+// interning it otherwise.
+func Interned(name string) (*Symbol, bool) {
+	symbolTable.Lock()
+	defer symbolTable.Unlock()
+	symbol, ok := symbolTable.symbols[name]
+	return symbol, ok
+}
+
+// NewUninternedSymbol returns a symbol that is different from any other
+// symbol, including interned symbols with the same name.
+func NewUninternedSymbol(name string) *Symbol {
+	return &Symbol{Name: name}
+}
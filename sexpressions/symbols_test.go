@@ -0,0 +1,47 @@
+package sexpressions
+
+import "testing"
+
+func TestIntern(t *testing.T) {
+	if Intern("intern-test") != Intern("intern-test") {
+		t.Error("Intern returned different symbols for the same name")
+	}
+	if NewUninternedSymbol("intern-test") == Intern("intern-test") {
+		t.Error("NewUninternedSymbol returned the interned symbol")
+	}
+	if _, ok := Interned("interned-test-never-interned"); ok {
+		t.Error("Interned found a name that was never interned")
+	}
+	if _, ok := Interned("interned-test-never-interned"); ok {
+		t.Error("Interned interned the name it looked up")
+	}
+	if s, ok := Interned("intern-test"); !ok || s != Intern("intern-test") {
+		t.Errorf("Interned = %v, %v, want the interned symbol", s, ok)
+	}
+}
+
+// TestStringSymbol checks that symbols whose Value is the name as a string
+// work like the interned symbols.
+func TestStringSymbol(t *testing.T) {
+	old := &SExp{Type: SymbolType, Value: "foo"}
+	if name, ok := old.AsSymbol(); !ok || name != "foo" {
+		t.Errorf("AsSymbol = %v, %v, want foo", name, ok)
+	}
+	if s, ok := old.AsSymbolObject(); !ok || s != Intern("foo") {
+		t.Errorf("AsSymbolObject = %v, %v, want the interned foo", s, ok)
+	}
+	if !old.Equal(NewSymbol("foo")) || !NewSymbol("foo").Equal(old) {
+		t.Error("symbol with a string value is not equal to the interned one")
+	}
+	if old.Equal(NewSymbol("bar")) {
+		t.Error("symbol with a string value is equal to another symbol")
+	}
+	if old.String() != "foo" {
+		t.Errorf("String = %v, want foo", old.String())
+	}
+	m := NewMap()
+	m.Set(NewSymbol("foo"), NewInt(1))
+	if v, ok := m.Get(old); !ok || !v.Equal(NewInt(1)) {
+		t.Errorf("Get = %v, %v, want 1", v, ok)
+	}
+}
@@ -0,0 +1,43 @@
+package sexpressions
+
+import "sync"
+
+// vectorMu guards the elements of all vectors, which tasks may share. Vectors
+// are plain slices, so they have no lock of their own.
+var vectorMu sync.RWMutex
+
+// vectorElems returns the elements of s without copying them, or nil if s
+// isn't a vector.
+func (s *SExp) vectorElems() []*SExp {
+	if s == nil || s.Type != VectorType || s.Value == nil {
+		return nil
+	}
+	return s.Value.([]*SExp)
+}
+
+// VectorLen returns the length of the vector s, or 0 if s isn't a vector.
+func (s *SExp) VectorLen() int {
+	// The length never changes, so it needs no lock.
+	return len(s.vectorElems())
+}
+
+// VectorRef returns the i-th element of the vector s. It panics if s isn't a
+// vector or i is out of range.
+func (s *SExp) VectorRef(i int) *SExp {
+	elems := s.vectorElems()
+	vectorMu.RLock()
+	defer vectorMu.RUnlock()
+	return elems[i]
+}
+
+// VectorSet sets the i-th element of the vector s to value. It panics if s
+// isn't a vector or i is out of range.
+func (s *SExp) VectorSet(i int, value *SExp) {
+	if value == nil {
+		panic("sexpressions: VectorSet with nil")
+	}
+	elems := s.vectorElems()
+	vectorMu.Lock()
+	defer vectorMu.Unlock()
+	elems[i] = value
+}
@@ -0,0 +1,19 @@
+package sexpressions
+
+import "testing"
+
+func TestVectorSet(t *testing.T) {
+	v := NewVector(NewInt(1), NewInt(2))
+	elems, _ := v.AsVector()
+	v.VectorSet(1, NewInt(3))
+	if got := v.VectorRef(1); got.String() != "3" {
+		t.Errorf("VectorRef(1) = %v, want 3", got)
+	}
+	// AsVector returns a copy, which VectorSet doesn't change.
+	if elems[1].String() != "2" {
+		t.Errorf("element returned by AsVector = %v, want 2", elems[1])
+	}
+	if n := v.VectorLen(); n != 2 {
+		t.Errorf("VectorLen() = %v, want 2", n)
+	}
+}
@@ -0,0 +1,79 @@
+package sexpressions
+
+// WalkFunc is called by Walk for each node. It returns the node to put in
+// place of s, which is s itself to keep it, and whether to walk the children
+// of the returned node. It must not return nil.
+type WalkFunc func(s *SExp) (replacement *SExp, walkChildren bool)
+
+// Walk traverses s in depth-first order, calling fn for each node before its
+// children, and returns the tree with the nodes replaced by fn. The children
+// of lists, vectors, pairs and maps are walked, including keys of maps.
+//
+// s is never modified. Containers with replaced descendants are copied, and
+// everything else is shared with s.
+func Walk(s *SExp, fn WalkFunc) *SExp {
+	s, walkChildren := fn(s)
+	if s == nil {
+		panic("sexpressions: WalkFunc returned nil")
+	}
+	if !walkChildren {
+		return s
+	}
+	switch s.Type {
+	case ListType, VectorType:
+		elems := s.elems()
+		if newElems, changed := walkSlice(elems, fn); changed {
+			return &SExp{Type: s.Type, Value: newElems, Pos: s.Pos}
+		}
+	case PairType:
+		pair := s.Value.(*Pair)
+		car, cdr := Walk(pair.Car, fn), Walk(pair.Cdr, fn)
+		if car != pair.Car || cdr != pair.Cdr {
+			result := Cons(car, cdr)
+			result.Pos = s.Pos
+			return result
+		}
+	case MapType:
+		m := s.Value.(*Map)
+		changed := false
+		newMap := NewMap()
+		for _, key := range m.Keys() {
+			value, _ := m.Get(key)
+			newKey, newValue := Walk(key, fn), Walk(value, fn)
+			changed = changed || newKey != key || newValue != value
+			newMap.Set(newKey, newValue)
+		}
+		if changed {
+			return &SExp{Type: MapType, Value: newMap, Pos: s.Pos}
+		}
+	}
+	return s
+}
+
+// walkSlice walks each of elems, and returns the results and whether any of
+// them was replaced. elems is returned as is if none was.
+func walkSlice(elems []*SExp, fn WalkFunc) ([]*SExp, bool) {
+	var result []*SExp
+	for i, elem := range elems {
+		newElem := Walk(elem, fn)
+		if newElem != elem && result == nil {
+			result = make([]*SExp, len(elems))
+			copy(result, elems[:i])
+		}
+		if result != nil {
+			result[i] = newElem
+		}
+	}
+	if result == nil {
+		return elems, false
+	}
+	return result, true
+}
+
+// Inspect traverses s in depth-first order, calling fn for each node before
+// its children. If fn returns false, the children of the node are skipped.
+func Inspect(s *SExp, fn func(s *SExp) bool) {
+	Walk(s, func(s *SExp) (*SExp, bool) {
+		return s, fn(s)
+	})
+}
@@ -0,0 +1,39 @@
+package sexpressions
+
+import "testing"
+
+func TestWalkEmptyList(t *testing.T) {
+	for _, s := range []*SExp{{Type: ListType}, {Type: VectorType}, NewList(&SExp{Type: ListType})} {
+		if got := Walk(s, func(s *SExp) (*SExp, bool) { return s, true }); got != s {
+			t.Errorf("Walk(%v) = %v, want the same tree", s, got)
+		}
+	}
+}
+
+func TestWalkReplace(t *testing.T) {
+	s := NewList(NewInt(1), NewList(NewInt(2), &SExp{Type: ListType}), NewVector(NewInt(3)))
+	got := Walk(s, func(s *SExp) (*SExp, bool) {
+		if i, ok := s.AsInt(); ok {
+			return NewInt(i * 10), false
+		}
+		return s, true
+	})
+	want := NewList(NewInt(10), NewList(NewInt(20), NewList()), NewVector(NewInt(30)))
+	if !got.Equal(want) {
+		t.Errorf("Walk replaced %v with %v, want %v", s, got, want)
+	}
+	if s.Equal(want) {
+		t.Errorf("Walk modified %v", s)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	var n int
+	Inspect(NewList(NewSymbol("a"), &SExp{Type: ListType}, NewList(NewInt(1))), func(s *SExp) bool {
+		n++
+		return true
+	})
+	if n != 5 {
+		t.Errorf("Inspect visited %v nodes, want 5", n)
+	}
+}
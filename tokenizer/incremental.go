@@ -0,0 +1,166 @@
+package tokenizer
+
+import "strings"
+
+// Tokenizer splits input fed in chunks, such as lines, into tokens. Tokens
+// may span chunks, for example strings and block comments containing
+// newlines.
+type Tokenizer struct {
+	// pending is the input that may continue in the next chunk, and p is its
+	// position.
+	pending strings.Builder
+	p       position
+	// incomplete is true if pending ends in a string or a block comment.
+	incomplete bool
+	// unterminated tracks the string or block comment pending is if
+	// incomplete, so that each chunk is scanned once until it is terminated.
+	unterminated unterminated
+}
+
+// unterminated is the state of scanning a string or a block comment at the
+// start of pending.
+type unterminated struct {
+	// scanned is the length of pending scanned so far.
+	scanned int
+	// escaped is true if the string ends in an unescaped backslash.
+	escaped bool
+	// depth is the nesting depth of the block comment.
+	depth int
+}
+
+// NewTokenizer returns a Tokenizer at the start of input.
+func NewTokenizer() *Tokenizer {
+	return &Tokenizer{p: position{line: 1, column: 1}}
+}
+
+// Feed appends s to the input and returns the tokens that are complete.
+// Tokens at the end of the input are kept until the next delimiter such as a
+// space or a paren since more input may continue them. Positions of the
+// tokens are relative to the start of the whole input.
+func (t *Tokenizer) Feed(s string) ([]*Token, error) {
+	t.pending.WriteString(s)
+	input, start := t.pending.String(), t.p
+	if t.incomplete && !t.unterminated.terminated(input) {
+		return nil, nil
+	}
+	tokens, rest, p, err := scan(input, start)
+	if err != nil {
+		return nil, err
+	}
+	t.incomplete = rest != "" && continuable(rest)
+	if rest != "" && !t.incomplete {
+		return nil, p.error(rest)
+	}
+	// Tokens may be continued by the next chunk unless a delimiter follows
+	// them, such as 1 and . followed by 5. The last space is kept as well so
+	// that spaces split across chunks make one token.
+	keep := len(tokens)
+	if rest == "" {
+		keep = 0
+		for i := len(tokens) - 1; i >= 0; i-- {
+			if tokens[i].Type == Space {
+				keep = i
+				break
+			}
+			if isDelimiter(tokens[i].Type) {
+				keep = i + 1
+				break
+			}
+		}
+	}
+	if keep < len(tokens) {
+		first := tokens[keep]
+		t.p = position{offset: first.Offset, line: first.Line, column: first.Column}
+	} else {
+		t.p = p
+	}
+	pending := input[t.p.offset-start.offset:]
+	t.pending.Reset()
+	t.pending.WriteString(pending)
+	t.unterminated = unterminated{}
+	return tokens[:keep], nil
+}
+
+// isDelimiter reports whether tokens of type tt end where they do however
+// the input continues.
+func isDelimiter(tt Type) bool {
+	switch tt {
+	case Space, OpenParen, CloseParen, OpenVector, Quote, StringLiteral:
+		return true
+	}
+	return false
+}
+
+// terminated scans the part of input added since the last call, and reports
+// whether it may terminate the string or block comment input starts with.
+// Other continuable input such as # is short, so it is always scanned again.
+func (u *unterminated) terminated(input string) bool {
+	switch {
+	case strings.HasPrefix(input, `"`):
+		if u.scanned == 0 {
+			u.scanned++
+		}
+		for ; u.scanned < len(input); u.scanned++ {
+			switch {
+			case u.escaped:
+				u.escaped = false
+			case input[u.scanned] == '\\':
+				u.escaped = true
+			case input[u.scanned] == '"':
+				return true
+			}
+		}
+		return false
+	case strings.HasPrefix(input, "#|"):
+		// The last byte is left for the next call since it may start #| or
+		// |# with the next chunk.
+		for ; u.scanned+1 < len(input); u.scanned++ {
+			switch input[u.scanned : u.scanned+2] {
+			case "#|":
+				u.depth++
+				u.scanned++
+			case "|#":
+				u.depth--
+				u.scanned++
+				if u.depth == 0 {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Incomplete reports whether the input fed so far ends in an unterminated
+// string or block comment, which needs more input.
+func (t *Tokenizer) Incomplete() bool {
+	return t.incomplete
+}
+
+// Close ends the input and returns the remaining tokens. It fails if the
+// input ends in the middle of a token.
+func (t *Tokenizer) Close() ([]*Token, error) {
+	tokens, rest, p, err := scan(t.pending.String(), t.p)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, p.error(rest)
+	}
+	t.pending.Reset()
+	t.p, t.incomplete = p, false
+	return tokens, nil
+}
+
+// continuable reports whether rest, which couldn't be tokenized, may become a
+// token with more input.
+func continuable(rest string) bool {
+	switch {
+	case strings.HasPrefix(rest, `"`), strings.HasPrefix(rest, "#|"):
+		return true
+	case rest == "#", rest == `#\`:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package tokenizer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// feed feeds the chunks to a new Tokenizer and returns all tokens.
+func feed(chunks []string) ([]*Token, error) {
+	t := NewTokenizer()
+	var tokens []*Token
+	for _, chunk := range chunks {
+		fed, err := t.Feed(chunk)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, fed...)
+	}
+	rest, err := t.Close()
+	if err != nil {
+		return nil, err
+	}
+	return append(tokens, rest...), nil
+}
+
+// TestFeed checks that feeding input split at every offset gives the same
+// tokens as tokenizing it at once.
+func TestFeed(t *testing.T) {
+	srcs := []string{
+		"(add 1 2)",
+		"(f 1.5 .5 -.5)",
+		"(a . b)",
+		"1.5e3",
+		"0xFF",
+		"'(a \"b\\\" c\" #\\a)",
+		"#(1 2) #t",
+		"; comment\n(x)",
+		"#| a #| b |# c |# d",
+		"(a)(b)(c)",
+		"x  \n\n  y",
+	}
+	for _, src := range srcs {
+		want, err := Tokenize(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i <= len(src); i++ {
+			got, err := feed([]string{src[:i], src[i:]})
+			if err != nil {
+				t.Errorf("feed(%q, %q) failed: %v", src[:i], src[i:], err)
+				continue
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("feed(%q, %q) = %v, want %v", src[:i], src[i:], got, want)
+			}
+		}
+		got, err := feed(strings.Split(src, ""))
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("feed of %q byte by byte = %v, %v, want %v", src, got, err, want)
+		}
+	}
+}
+
+func TestFeedIncomplete(t *testing.T) {
+	tests := []struct {
+		chunks     []string
+		incomplete bool
+	}{
+		{chunks: []string{"\"abc"}, incomplete: true},
+		{chunks: []string{"\"abc", "def\""}, incomplete: false},
+		{chunks: []string{"\"abc\\", "\""}, incomplete: true},
+		{chunks: []string{"#| a", " #| b |#"}, incomplete: true},
+		{chunks: []string{"#| a", " #| b |#", "|", "#"}, incomplete: false},
+		{chunks: []string{"(a", ")"}, incomplete: false},
+	}
+	for _, tt := range tests {
+		tokenizer := NewTokenizer()
+		for _, chunk := range tt.chunks {
+			if _, err := tokenizer.Feed(chunk); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := tokenizer.Incomplete(); got != tt.incomplete {
+			t.Errorf("Incomplete() after %q = %v, want %v", tt.chunks, got, tt.incomplete)
+		}
+	}
+}
+
+// TestFeedLong checks that tokens are returned as soon as they are complete
+// and that each chunk is scanned once, which would take quadratic time
+// otherwise.
+func TestFeedLong(t *testing.T) {
+	const n = 200000
+	tokenizer := NewTokenizer()
+	count := 0
+	for i := 0; i < n; i++ {
+		tokens, err := tokenizer.Feed("(a)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		count += len(tokens)
+	}
+	if count != 3*n {
+		t.Errorf("got %v tokens before Close, want %v", count, 3*n)
+	}
+
+	tokenizer = NewTokenizer()
+	if _, err := tokenizer.Feed(`"`); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if _, err := tokenizer.Feed("x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tokens, err := tokenizer.Feed(`"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || len(tokens[0].Str) != n+2 {
+		t.Errorf("got %v tokens, want a string of %v bytes", len(tokens), n+2)
+	}
+}
@@ -4,6 +4,7 @@ package tokenizer
 import (
 	"fmt"
 	"regexp"
+	"strings"
 )
 
 // Type represents type of token (for example, space, or open paren).
@@ -18,18 +19,60 @@ const (
 	CloseParen
 	// Symbol represents unquoted identifiers.
 	Symbol
+	// Keyword represents symbols prefixed by ':' such as :name.
+	Keyword
 	// StringLiteral represents quoted strings.
 	StringLiteral
-	// NumberLiteral represents numbers (currently only supports decimal integers).
+	// NumberLiteral represents decimal integers and floats such as -1, 3.14 and 1e-3,
+	// and integers prefixed by radix such as 0xFF, 0o17 and 0b1010.
 	NumberLiteral
+	// BoolLiteral represents #t and #f.
+	BoolLiteral
+	// CharLiteral represents characters such as #\a and #\newline.
+	CharLiteral
+	// OpenVector represents '#(', which starts a vector.
+	OpenVector
+	// Comment represents comments from ';' to the end of the line, and block
+	// comments between '#|' and '|#', which may be nested.
+	Comment
+	// Quote represents '\'', which quotes the following expression.
+	Quote
 )
 
+var typeNames = map[Type]string{
+	Space:         "Space",
+	OpenParen:     "OpenParen",
+	CloseParen:    "CloseParen",
+	Symbol:        "Symbol",
+	Keyword:       "Keyword",
+	StringLiteral: "StringLiteral",
+	NumberLiteral: "NumberLiteral",
+	BoolLiteral:   "BoolLiteral",
+	CharLiteral:   "CharLiteral",
+	OpenVector:    "OpenVector",
+	Comment:       "Comment",
+	Quote:         "Quote",
+}
+
+func (t Type) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("Type(%d)", int(t))
+}
+
 // Token is one meaningful chunk of substring.
 type Token struct {
 	// Type tells which type this token is.
 	Type Type
 	// Str is the original substring that corresponds to this token.
 	Str string
+	// Offset is the byte offset in the tokenized string where the token
+	// starts.
+	Offset int
+	// Line and Column are the 1-based position where the token starts.
+	// Column counts bytes.
+	Line, Column int
 }
 
 // String returns a description string of a token for debugging.
@@ -37,22 +80,72 @@ func (t *Token) String() string {
 	return fmt.Sprintf("<%s>", t.Str)
 }
 
+// Error is returned by Tokenize when s can't be split into tokens.
+type Error struct {
+	// Offset is the byte offset in s where tokenizing failed.
+	Offset int
+	// Line and Column are the 1-based position of Offset.
+	Line, Column int
+	rest         string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("tokenize failed at %s", e.rest)
+}
+
 // Tokenize splits s into tokens.
 func Tokenize(s string) ([]*Token, error) {
-	res := []*Token{}
-	rest := s
+	tokens, rest, p, err := scan(s, position{line: 1, column: 1})
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, p.error(rest)
+	}
+	return tokens, nil
+}
+
+// position is a position in the input with the same meaning as the fields of
+// Token.
+type position struct {
+	offset, line, column int
+}
+
+// advance moves p past s.
+func (p *position) advance(s string) {
+	p.offset += len(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		p.line += strings.Count(s, "\n")
+		p.column = len(s) - i
+	} else {
+		p.column += len(s)
+	}
+}
+
+func (p position) error(rest string) *Error {
+	return &Error{Offset: p.offset, Line: p.line, Column: p.column, rest: rest}
+}
+
+// scan splits s, which starts at p, into tokens until it fails. It returns
+// the tokens, the rest of s that couldn't be split, and the position of the
+// rest.
+func scan(s string, p position) (tokens []*Token, rest string, next position, err error) {
+	tokens = []*Token{}
+	rest = s
 	for len(rest) > 0 {
 		t, nextRest, ok := tokenize1(rest)
 		if !ok {
-			return nil, fmt.Errorf("tokenize failed at %s", rest)
+			break
 		}
 		if len(nextRest) >= len(rest) {
-			return nil, fmt.Errorf("tokenizers must consume at least 1 character: current head: %s", rest)
+			return nil, "", p, fmt.Errorf("tokenizers must consume at least 1 character: current head: %s", rest)
 		}
-		res = append(res, t)
+		t.Offset, t.Line, t.Column = p.offset, p.line, p.column
+		p.advance(t.Str)
+		tokens = append(tokens, t)
 		rest = nextRest
 	}
-	return res, nil
+	return tokens, rest, p, nil
 }
 
 type tokenizer interface {
@@ -69,12 +162,21 @@ func tokenize1(s string) (t *Token, rest string, ok bool) {
 }
 
 var subTokenizers = []tokenizer{
-	newRegexpTokenizer(Space, regexp.MustCompile(`\s+`)),
-	newRegexpTokenizer(OpenParen, regexp.MustCompile(`\(`)),
-	newRegexpTokenizer(CloseParen, regexp.MustCompile(`\)`)),
-	newRegexpTokenizer(Symbol, regexp.MustCompile(`[a-zA-Z][a-zA-Z_]*`)),
-	newRegexpTokenizer(StringLiteral, regexp.MustCompile(`"([^"\\]|\\"|\\\\)*"`)),
-	newRegexpTokenizer(NumberLiteral, regexp.MustCompile(`[1-9][0-9]*`)),
+	newRegexpTokenizer(Space, `\s+`),
+	newRegexpTokenizer(Comment, `;[^\n]*`),
+	&blockCommentTokenizer{},
+	newRegexpTokenizer(OpenParen, `\(`),
+	newRegexpTokenizer(CloseParen, `\)`),
+	newRegexpTokenizer(OpenVector, `#\(`),
+	newRegexpTokenizer(Quote, `'`),
+	// NumberLiteral must come before Symbol so that -1 and .5 are numbers
+	// while -, -x and . are symbols.
+	newRegexpTokenizer(NumberLiteral, `[+-]?(0[xX][0-9a-fA-F]+|0[oO][0-7]+|0[bB][01]+|([0-9]+(\.[0-9]+)?|\.[0-9]+)([eE][+-]?[0-9]+)?)`),
+	newRegexpTokenizer(Keyword, `:[a-zA-Z0-9!$%&*/:<=>?^_~+\-.]+`),
+	newRegexpTokenizer(Symbol, `[a-zA-Z!$%&*/<=>?^_~+\-][a-zA-Z0-9!$%&*/:<=>?^_~+\-.]*|\.`),
+	newRegexpTokenizer(StringLiteral, `(?s)"([^"\\]|\\.)*"`),
+	newRegexpTokenizer(BoolLiteral, `#[tf]`),
+	newRegexpTokenizer(CharLiteral, `#\\([a-z]+|.)`),
 }
 
 type regexpTokenizer struct {
@@ -82,25 +184,53 @@ type regexpTokenizer struct {
 	re        *regexp.Regexp
 }
 
-func newRegexpTokenizer(tokenType Type, re *regexp.Regexp) *regexpTokenizer {
+// newRegexpTokenizer returns a tokenizer of tokens that match pattern at the
+// start of the input. The pattern is anchored so that matching doesn't search
+// the rest of the input.
+func newRegexpTokenizer(tokenType Type, pattern string) *regexpTokenizer {
 	return &regexpTokenizer{
 		tokenType: tokenType,
-		re:        re,
+		re:        regexp.MustCompile(`\A(?:` + pattern + `)`),
 	}
 }
 
 func (rt *regexpTokenizer) Tokenize(s string) (t *Token, rest string, ok bool) {
-	match := rt.re.FindIndex([]byte(s))
-	if len(match) < 2 {
-		return nil, s, false
-	}
-	start, end := match[0], match[1]
-	if start != 0 {
+	match := rt.re.FindStringIndex(s)
+	if match == nil || match[1] == 0 {
 		return nil, s, false
 	}
 	tok := &Token{
 		Type: rt.tokenType,
-		Str:  s[start:end],
+		Str:  s[:match[1]],
 	}
-	return tok, s[end:], true
+	return tok, s[match[1]:], true
+}
+
+// blockCommentTokenizer tokenizes nested block comments, which can't be
+// expressed by regexp.
+type blockCommentTokenizer struct{}
+
+func (bt *blockCommentTokenizer) Tokenize(s string) (t *Token, rest string, ok bool) {
+	if !strings.HasPrefix(s, "#|") {
+		return nil, s, false
+	}
+	depth := 0
+	for i := 0; i+1 < len(s); i++ {
+		switch s[i : i+2] {
+		case "#|":
+			depth++
+			i++
+		case "|#":
+			depth--
+			i++
+			if depth == 0 {
+				tok := &Token{
+					Type: Comment,
+					Str:  s[:i+1],
+				}
+				return tok, s[i+1:], true
+			}
+		}
+	}
+	return nil, s, false
 }
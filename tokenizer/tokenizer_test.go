@@ -0,0 +1,75 @@
+package tokenizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		src   string
+		types []Type
+		strs  []string
+	}{
+		{src: "(add 1 2)", types: []Type{OpenParen, Symbol, Space, NumberLiteral, Space, NumberLiteral, CloseParen}},
+		{src: ".5", types: []Type{NumberLiteral}},
+		{src: "-.5e3", types: []Type{NumberLiteral}},
+		{src: "(a . b)", types: []Type{OpenParen, Symbol, Space, Symbol, Space, Symbol, CloseParen}},
+		{src: "1.", types: []Type{NumberLiteral, Symbol}, strs: []string{"1", "."}},
+		{src: "0xFF 1e-3 3.14", types: []Type{NumberLiteral, Space, NumberLiteral, Space, NumberLiteral}},
+		{src: "'x", types: []Type{Quote, Symbol}},
+		{src: "#(#t #\\a)", types: []Type{OpenVector, BoolLiteral, Space, CharLiteral, CloseParen}},
+		{src: ":key \"a\\\"b\"", types: []Type{Keyword, Space, StringLiteral}},
+		{src: "; c\n#| a #| b |# |#", types: []Type{Comment, Space, Comment}},
+		{src: "(a ; b (c\n d)", types: []Type{OpenParen, Symbol, Space, Comment, Space, Symbol, CloseParen}, strs: []string{"(", "a", " ", "; b (c", "\n ", "d", ")"}},
+		{src: ";; no newline", types: []Type{Comment}},
+		{src: "a;b", types: []Type{Symbol, Comment}, strs: []string{"a", ";b"}},
+		{src: "\"a ; b\"", types: []Type{StringLiteral}},
+		{src: "#| a #| b |# c |#x", types: []Type{Comment, Symbol}, strs: []string{"#| a #| b |# c |#", "x"}},
+		{src: "#| a |##| b |#", types: []Type{Comment, Comment}},
+		{src: "(a #| ; |# b)", types: []Type{OpenParen, Symbol, Space, Comment, Space, Symbol, CloseParen}},
+		{src: "; #| a\nb", types: []Type{Comment, Space, Symbol}},
+	}
+	for _, tt := range tests {
+		tokens, err := Tokenize(tt.src)
+		if err != nil {
+			t.Errorf("Tokenize(%q) failed: %v", tt.src, err)
+			continue
+		}
+		var types []Type
+		var strs []string
+		for _, token := range tokens {
+			types = append(types, token.Type)
+			strs = append(strs, token.Str)
+		}
+		if !reflect.DeepEqual(types, tt.types) {
+			t.Errorf("Tokenize(%q) = %v, want %v", tt.src, types, tt.types)
+		}
+		if tt.strs != nil && !reflect.DeepEqual(strs, tt.strs) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.src, strs, tt.strs)
+		}
+	}
+}
+
+func TestTokenizeError(t *testing.T) {
+	tests := []struct {
+		src                  string
+		offset, line, column int
+	}{
+		{src: "(a\n  \"b", offset: 5, line: 2, column: 3},
+		{src: "a\n #| b", offset: 3, line: 2, column: 2},
+		{src: "#| a #| b |# c", offset: 0, line: 1, column: 1},
+		{src: "x |# y", offset: 2, line: 1, column: 3},
+	}
+	for _, tt := range tests {
+		_, err := Tokenize(tt.src)
+		e, ok := err.(*Error)
+		if !ok {
+			t.Errorf("Tokenize(%q): got %v, want *Error", tt.src, err)
+			continue
+		}
+		if e.Offset != tt.offset || e.Line != tt.line || e.Column != tt.column {
+			t.Errorf("Tokenize(%q): error at %v, %v:%v, want %v, %v:%v", tt.src, e.Offset, e.Line, e.Column, tt.offset, tt.line, tt.column)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// Package toylisp runs toylisp programs embedded in Go programs. It wires the
+// tokenizer, parser and evaluator packages together.
+package toylisp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/soishi1/toylisp/evaluator"
+	"github.com/soishi1/toylisp/sexpressions"
+)
+
+// Value is a value of a toylisp program.
+type Value = evaluator.Value
+
+// Option customizes interpreters created by New.
+type Option = evaluator.Option
+
+// WithoutPrelude makes New skip loading the prelude, so that only the builtin
+// primitives are defined.
+func WithoutPrelude() Option {
+	return evaluator.WithoutPrelude()
+}
+
+// WithConstantFolding makes the interpreter evaluate arithmetic on constants
+// when forms are compiled. See evaluator.WithConstantFolding.
+func WithConstantFolding() Option {
+	return evaluator.WithConstantFolding()
+}
+
+// Capability names a group of primitives that reach outside of the
+// interpreter.
+type Capability = evaluator.Capability
+
+// Capabilities that can be passed to WithCapabilities.
+const (
+	CapIO   = evaluator.CapIO
+	CapOS   = evaluator.CapOS
+	CapTime = evaluator.CapTime
+)
+
+// WithCapabilities makes New define only the primitives that require no
+// capability or one of caps, so that untrusted programs can be limited to
+// pure computation.
+func WithCapabilities(caps ...Capability) Option {
+	return evaluator.WithCapabilities(caps...)
+}
+
+// Hooks are callbacks invoked during evaluation. See evaluator.Hooks.
+type Hooks = evaluator.Hooks
+
+// Interpreter evaluates toylisp programs in its own top-level environment.
+// Definitions made by one evaluation are visible to later ones.
+type Interpreter struct {
+	env *evaluator.Env
+}
+
+// New returns an interpreter with the builtin primitives and the prelude.
+func New(opts ...Option) *Interpreter {
+	return &Interpreter{env: evaluator.NewEnv(opts...)}
+}
+
+// Env returns the top-level environment of the interpreter, for lower level
+// control such as binding variables.
+func (in *Interpreter) Env() *evaluator.Env {
+	return in.env
+}
+
+// SetInput sets the reader that input primitives such as read-line read from,
+// so that programs can be fed input. It is os.Stdin by default.
+func (in *Interpreter) SetInput(r io.Reader) {
+	in.env.SetInput(r)
+}
+
+// SetOutput sets the writer that output primitives such as print write to,
+// so that program output can be captured. It is os.Stdout by default.
+func (in *Interpreter) SetOutput(w io.Writer) {
+	in.env.SetOutput(w)
+}
+
+// SetErrorOutput sets the writer that error output primitives such as eprint
+// write to. It is os.Stderr by default.
+func (in *Interpreter) SetErrorOutput(w io.Writer) {
+	in.env.SetErrorOutput(w)
+}
+
+// SetCommandLine sets the program name and arguments returned by the
+// command-line primitive. They are os.Args by default.
+func (in *Interpreter) SetCommandLine(args []string) {
+	in.env.SetCommandLine(args)
+}
+
+// Clock is the source of time of the time primitives. See evaluator.Clock.
+type Clock = evaluator.Clock
+
+// SetClock sets the clock the time primitives read and sleep with, so that
+// programs can be run with fake time. It is the system clock by default.
+func (in *Interpreter) SetClock(c Clock) {
+	in.env.SetClock(c)
+}
+
+// SetHooks sets the callbacks invoked during evaluation, or removes them if
+// hooks is nil.
+func (in *Interpreter) SetHooks(hooks *Hooks) {
+	in.env.SetHooks(hooks)
+}
+
+// Error is returned when evaluating a source fails. Err is a *LispError if
+// the program signaled a condition it didn't catch.
+type Error struct {
+	// Source is the name of the source being evaluated, such as a file path.
+	// It is empty for EvalString.
+	Source string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	var positioned *evaluator.PositionError
+	if e.Source == "" || errors.As(e.Err, &positioned) && positioned.File == e.Source {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", e.Source, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// newError returns the error for err returned by evaluating source.
+func newError(source string, err error) *Error {
+	var c *evaluator.ConditionValue
+	if errors.As(err, &c) {
+		le := &LispError{Type: c.Type, Message: c.Message, Data: c.Data, Err: err}
+		var positioned *evaluator.PositionError
+		if errors.As(err, &positioned) {
+			le.File, le.Pos = positioned.File, positioned.Pos
+		}
+		err = le
+	}
+	return &Error{Source: source, Err: err}
+}
+
+// LispError is a condition signaled by a program and not caught, which can
+// be retrieved from the errors returned by Interpreter with errors.As. The Go
+// error a condition was made from, such as one returned by a function
+// registered with evaluator.Env.RegisterFunc, can be retrieved from it too.
+type LispError struct {
+	// Type is the name of the condition type, such as type-error.
+	Type    string
+	Message string
+	// Data is the list of additional values passed to error.
+	Data *Value
+	// File and Pos are the location of the innermost expression whose
+	// evaluation failed. Pos is invalid if it isn't known.
+	File string
+	Pos  sexpressions.Pos
+	// Err is the error returned by the evaluator, which wraps the
+	// *evaluator.ConditionValue.
+	Err error
+}
+
+func (e *LispError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LispError) Unwrap() error {
+	return e.Err
+}
+
+// ExitError is returned when a program calls exit. Interpreters never exit
+// the process themselves; the caller decides what to do with the code.
+type ExitError = evaluator.ExitError
+
+// Frame is a function application that was pending when an error occurred.
+type Frame = evaluator.Frame
+
+// Backtrace returns the function applications that were pending when err
+// occurred, from the innermost one, or nil if err has no backtrace.
+func Backtrace(err error) []Frame {
+	return evaluator.Backtrace(err)
+}
+
+// EvalString evaluates all s-expressions in src in order, and returns the
+// value of the last one.
+func (in *Interpreter) EvalString(src string) (*Value, error) {
+	return in.eval("", src)
+}
+
+// EvalStringContext is like EvalString, but stops with an error matching
+// ctx.Err() when ctx is done, so that the run time of scripts can be bounded.
+func (in *Interpreter) EvalStringContext(ctx context.Context, src string) (*Value, error) {
+	value, err := in.env.EvalStringContext(ctx, src)
+	if err != nil {
+		return nil, newError("", err)
+	}
+	return value, nil
+}
+
+// EvalFile evaluates the file at path. Like EvalReader, it evaluates each
+// s-expression as soon as it is read.
+func (in *Interpreter) EvalFile(path string) (*Value, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return in.evalReader(path, f)
+}
+
+// EvalReader evaluates the s-expressions read from r in order, each as soon
+// as it is read, so that r isn't read into memory all at once. The ones
+// before a syntax error are evaluated.
+func (in *Interpreter) EvalReader(r io.Reader) (*Value, error) {
+	return in.evalReader("", r)
+}
+
+// Call calls the function bound to name with args, which are converted to
+// Lisp values as by evaluator.Env.RegisterFunc.
+func (in *Interpreter) Call(name string, args ...interface{}) (*Value, error) {
+	fn, ok := in.env.Lookup(name)
+	if !ok {
+		return nil, &Error{Err: fmt.Errorf("undefined function %v", name)}
+	}
+	value, err := fn.Call(args...)
+	if err != nil {
+		return nil, newError("", err)
+	}
+	return value, nil
+}
+
+func (in *Interpreter) evalReader(source string, r io.Reader) (*Value, error) {
+	value, err := in.env.EvalReader(source, r)
+	if err != nil {
+		return nil, newError(source, err)
+	}
+	return value, nil
+}
+
+func (in *Interpreter) eval(source, src string) (*Value, error) {
+	value, err := in.env.EvalSource(source, src)
+	if err != nil {
+		return nil, newError(source, err)
+	}
+	return value, nil
+}
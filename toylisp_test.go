@@ -0,0 +1,125 @@
+package toylisp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCall(t *testing.T) {
+	in := New()
+	if _, err := in.EvalString("(define add3 (lambda (a b c) (add a b c)))"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := in.Call("add3", 1, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != "6" {
+		t.Errorf("got %v, want 6", got)
+	}
+	if _, err := in.Call("undefined-function"); err == nil {
+		t.Error("calling an undefined function succeeded")
+	}
+	var le *LispError
+	if _, err := in.Call("add3", 1); !errors.As(err, &le) || le.Type != "arity-error" {
+		t.Errorf("got error %v, want arity-error", err)
+	}
+}
+
+func TestEvalStringContext(t *testing.T) {
+	in := New()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := in.EvalStringContext(ctx, "(dotimes (i 1000000000000) i)")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	// The interpreter is usable after the evaluation is cancelled.
+	got, err := in.EvalString("(add 1 2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != "3" {
+		t.Errorf("got %v, want 3", got)
+	}
+}
+
+func TestLispError(t *testing.T) {
+	in := New()
+	_, err := in.EvalString("\n(error 'my-error \"boom\" 1 2)")
+	var le *LispError
+	if !errors.As(err, &le) {
+		t.Fatalf("got error %v, want *LispError", err)
+	}
+	if le.Type != "my-error" || le.Message != "boom" || le.Data.String() != "(1 2)" {
+		t.Errorf("got %v %q %v, want my-error \"boom\" (1 2)", le.Type, le.Message, le.Data)
+	}
+	if le.Pos.Line != 2 {
+		t.Errorf("got line %v, want 2", le.Pos.Line)
+	}
+}
+
+// signalWriter closes written on the first write.
+type signalWriter struct {
+	once    sync.Once
+	written chan struct{}
+}
+
+func (w *signalWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.written) })
+	return len(p), nil
+}
+
+func TestEvalReader(t *testing.T) {
+	pr, pw := io.Pipe()
+	in := New()
+	out := &signalWriter{written: make(chan struct{})}
+	in.SetOutput(out)
+	done := make(chan error, 1)
+	go func() {
+		_, err := in.EvalReader(pr)
+		done <- err
+	}()
+	if _, err := io.WriteString(pw, "(print 1)\n(set x\n"); err != nil {
+		t.Fatal(err)
+	}
+	// The 1st form is evaluated before the rest of the input is written.
+	select {
+	case <-out.written:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the 1st form wasn't evaluated before the input ended")
+	}
+	if _, err := io.WriteString(pw, "  2)\nx\n"); err != nil {
+		t.Fatal(err)
+	}
+	pw.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEvalFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.lisp")
+	if err := os.WriteFile(path, []byte("(set x 1)\n(car x)"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := New().EvalFile(path)
+	var le *LispError
+	if !errors.As(err, &le) || le.File != path || le.Pos.Line != 2 {
+		t.Errorf("got error %v, want one at %v:2", err, path)
+	}
+}
+
+func TestExitError(t *testing.T) {
+	_, err := New().EvalString("(exit 3)")
+	var exit *ExitError
+	if !errors.As(err, &exit) || exit.Code != 3 {
+		t.Errorf("got error %v, want exit code 3", err)
+	}
+}